@@ -3,15 +3,14 @@ package admin
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/sessions"
 	"github.com/juanfont/juango/auth"
+	"github.com/juanfont/juango/httpjson"
 	"github.com/juanfont/juango/types"
 	"github.com/rs/zerolog/log"
 )
@@ -23,6 +22,16 @@ type Handlers struct {
 	userStore        auth.UserStore
 	auditLogger      auth.AuditLogger
 	adminModeTimeout time.Duration
+	metrics          auth.Metrics
+
+	// The JSON adapters are created once, so that invalid validate tags
+	// fail in NewHandlers.
+	adminModeStatusJSON     http.HandlerFunc
+	enableAdminModeJSON     http.HandlerFunc
+	disableAdminModeJSON    http.HandlerFunc
+	startImpersonationJSON  http.HandlerFunc
+	stopImpersonationJSON   http.HandlerFunc
+	impersonationStatusJSON http.HandlerFunc
 }
 
 // NewHandlers creates new admin handlers.
@@ -33,17 +42,33 @@ func NewHandlers(
 	auditLogger auth.AuditLogger,
 	adminModeTimeout time.Duration,
 ) *Handlers {
-	return &Handlers{
+	h := &Handlers{
 		sessionStore:     sessionStore,
 		cookieName:       cookieName,
 		userStore:        userStore,
 		auditLogger:      auditLogger,
 		adminModeTimeout: adminModeTimeout,
 	}
+	h.adminModeStatusJSON = httpjson.Handle(h.adminModeStatus)
+	h.enableAdminModeJSON = httpjson.Handle(h.enableAdminMode)
+	h.disableAdminModeJSON = httpjson.Handle(h.disableAdminMode)
+	h.startImpersonationJSON = httpjson.Handle(h.startImpersonation)
+	h.stopImpersonationJSON = httpjson.Handle(h.stopImpersonation)
+	h.impersonationStatusJSON = httpjson.Handle(h.impersonationStatus)
+	return h
+}
+
+// SetMetrics sets where admin mode and impersonation events are reported.
+func (h *Handlers) SetMetrics(m auth.Metrics) {
+	h.metrics = m
 }
 
 // AdminModeStatusHandler handles GET /api/admin/mode/status.
 func (h *Handlers) AdminModeStatusHandler(w http.ResponseWriter, r *http.Request) {
+	h.adminModeStatusJSON(w, r)
+}
+
+func (h *Handlers) adminModeStatus(w http.ResponseWriter, r *http.Request, _ httpjson.Empty) (types.AdminModeStatusResponse, error) {
 	user := auth.GetUserFromContext(r.Context())
 
 	response := types.AdminModeStatusResponse{
@@ -57,6 +82,9 @@ func (h *Handlers) AdminModeStatusHandler(w http.ResponseWriter, r *http.Request
 				if adminState.IsExpired(h.adminModeTimeout) {
 					delete(session.Values, "admin_mode")
 					session.Save(r, w)
+					if h.metrics != nil {
+						h.metrics.AdminModeEnded(user.ID)
+					}
 				} else {
 					response.AdminMode = &adminState
 				}
@@ -64,43 +92,37 @@ func (h *Handlers) AdminModeStatusHandler(w http.ResponseWriter, r *http.Request
 		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	return response, nil
 }
 
 // AdminModeEnableHandler handles POST /api/admin/mode/enable.
 func (h *Handlers) AdminModeEnableHandler(w http.ResponseWriter, r *http.Request) {
+	h.enableAdminModeJSON(w, r)
+}
+
+func (h *Handlers) enableAdminMode(w http.ResponseWriter, r *http.Request, req types.AdminModeRequest) (types.AdminModeEnableResponse, error) {
 	ctx := r.Context()
 	user := auth.GetUserFromContext(ctx)
 
-	var req types.AdminModeRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusBadRequest, "Invalid request body", err))
-		return
-	}
-
-	if strings.TrimSpace(req.Reason) == "" {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusBadRequest, "Reason is required for admin mode", nil))
-		return
-	}
-
 	session, err := h.sessionStore.Get(r, h.cookieName)
 	if err != nil {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusInternalServerError, "Failed to get session", err))
-		return
+		return types.AdminModeEnableResponse{}, types.NewHTTPError(http.StatusInternalServerError, "Failed to get session", err)
 	}
 
 	adminState := types.AdminModeState{
 		Enabled:   true,
 		Since:     time.Now(),
-		Reason:    strings.TrimSpace(req.Reason),
+		Reason:    req.Reason,
 		IPAddress: auth.GetClientIP(r),
 	}
 
 	session.Values["admin_mode"] = adminState
 	if err := session.Save(r, w); err != nil {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusInternalServerError, "Failed to save session", err))
-		return
+		return types.AdminModeEnableResponse{}, types.NewHTTPError(http.StatusInternalServerError, "Failed to save session", err)
+	}
+
+	if h.metrics != nil {
+		h.metrics.AdminModeStarted(user.ID, adminState.Since.Add(h.adminModeTimeout))
 	}
 
 	log.Info().
@@ -111,46 +133,50 @@ func (h *Handlers) AdminModeEnableHandler(w http.ResponseWriter, r *http.Request
 		Msg("Admin mode enabled")
 
 	if h.auditLogger != nil {
-		auditLog := auth.NewAuditLogWithContext(
+		auditLog, err := auth.NewAuditLogWithContext(
 			ctx,
 			types.ActionAdminModeEnabled,
 			types.ResourceTypeUser,
 			user.ID.String(),
-		).WithChanges(map[string]interface{}{
-			"reason":     adminState.Reason,
-			"ip_address": adminState.IPAddress,
-			"timeout":    h.adminModeTimeout.String(),
-		}).WithIPAddress(adminState.IPAddress).WithUserAgent(r.UserAgent())
+		).WithPayload(types.AdminModeEnabledChanges{
+			Reason:  adminState.Reason,
+			Timeout: h.adminModeTimeout.String(),
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to encode audit payload for admin mode enable")
+		}
+		auditLog.WithIPAddress(adminState.IPAddress).WithUserAgent(r.UserAgent())
 
 		if err := h.auditLogger.CreateAuditLog(ctx, auditLog); err != nil {
 			log.Error().Err(err).Msg("Failed to create audit log for admin mode enable")
 		}
 	}
 
-	response := types.AdminModeEnableResponse{
+	return types.AdminModeEnableResponse{
 		Message: "Admin mode enabled",
 		State:   &adminState,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	}, nil
 }
 
 // AdminModeDisableHandler handles POST /api/admin/mode/disable.
 func (h *Handlers) AdminModeDisableHandler(w http.ResponseWriter, r *http.Request) {
+	h.disableAdminModeJSON(w, r)
+}
+
+func (h *Handlers) disableAdminMode(w http.ResponseWriter, r *http.Request, _ httpjson.Empty) (types.AdminModeDisableResponse, error) {
 	ctx := r.Context()
 	user := auth.GetUserFromContext(ctx)
 
 	session, err := h.sessionStore.Get(r, h.cookieName)
 	if err != nil {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusInternalServerError, "Failed to get session", err))
-		return
+		return types.AdminModeDisableResponse{}, types.NewHTTPError(http.StatusInternalServerError, "Failed to get session", err)
 	}
 
 	var previousState types.AdminModeState
 	if adminState, ok := session.Values["admin_mode"].(types.AdminModeState); ok {
 		previousState = adminState
 	}
+	_, wasImpersonating := session.Values["impersonation_state"].(types.ImpersonationState)
 
 	// Also stop any active impersonation
 	delete(session.Values, "admin_mode")
@@ -158,8 +184,14 @@ func (h *Handlers) AdminModeDisableHandler(w http.ResponseWriter, r *http.Reques
 	delete(session.Values, "original_user_id")
 
 	if err := session.Save(r, w); err != nil {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusInternalServerError, "Failed to save session", err))
-		return
+		return types.AdminModeDisableResponse{}, types.NewHTTPError(http.StatusInternalServerError, "Failed to save session", err)
+	}
+
+	if h.metrics != nil {
+		h.metrics.AdminModeEnded(user.ID)
+		if wasImpersonating {
+			h.metrics.ImpersonationEnded(user.ID)
+		}
 	}
 
 	log.Info().
@@ -171,77 +203,76 @@ func (h *Handlers) AdminModeDisableHandler(w http.ResponseWriter, r *http.Reques
 		Msg("Admin mode disabled")
 
 	if h.auditLogger != nil {
-		auditLog := auth.NewAuditLogWithContext(
+		auditLog, err := auth.NewAuditLogWithContext(
 			ctx,
 			types.ActionAdminModeDisabled,
 			types.ResourceTypeUser,
 			user.ID.String(),
-		).WithChanges(map[string]interface{}{
-			"previous_reason": previousState.Reason,
-			"duration":        previousState.Duration().String(),
-			"ip_address":      auth.GetClientIP(r),
-		}).WithIPAddress(auth.GetClientIP(r)).WithUserAgent(r.UserAgent())
+		).WithPayload(types.AdminModeEndedChanges{
+			PreviousReason: previousState.Reason,
+			Duration:       previousState.Duration().String(),
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to encode audit payload for admin mode disable")
+		}
+		auditLog.WithIPAddress(auth.GetClientIP(r)).WithUserAgent(r.UserAgent())
 
 		if err := h.auditLogger.CreateAuditLog(ctx, auditLog); err != nil {
 			log.Error().Err(err).Msg("Failed to create audit log for admin mode disable")
 		}
 	}
 
-	response := types.AdminModeDisableResponse{
+	return types.AdminModeDisableResponse{
 		Message: "Admin mode disabled",
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	}, nil
 }
 
 // ImpersonationStartHandler handles POST /api/admin/impersonate/start.
 func (h *Handlers) ImpersonationStartHandler(w http.ResponseWriter, r *http.Request) {
+	h.startImpersonationJSON(w, r)
+}
+
+func (h *Handlers) startImpersonation(w http.ResponseWriter, r *http.Request, req types.ImpersonationStartRequest) (types.ImpersonationStartResponse, error) {
 	ctx := r.Context()
 	adminUser := auth.GetUserFromContext(ctx)
 
-	var req types.ImpersonationStartRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusBadRequest, "Invalid request body", err))
-		return
-	}
-
-	if strings.TrimSpace(req.Reason) == "" {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusBadRequest, "Reason is required for impersonation", nil))
-		return
-	}
-
 	targetUserID, err := uuid.Parse(req.TargetUserID)
-	if err != nil || targetUserID == uuid.Nil {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusBadRequest, "Target user ID is required", err))
-		return
+	if err != nil {
+		return types.ImpersonationStartResponse{}, types.NewValidationError(types.FieldError{
+			Field:   "target_user_id",
+			Message: "must be a valid UUID",
+		})
+	}
+	if targetUserID == uuid.Nil {
+		return types.ImpersonationStartResponse{}, types.NewValidationError(types.FieldError{
+			Field:   "target_user_id",
+			Message: "is required",
+		})
 	}
 
 	if targetUserID == adminUser.ID {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusBadRequest, "Cannot impersonate yourself", nil))
-		return
+		return types.ImpersonationStartResponse{}, types.NewHTTPError(http.StatusBadRequest, "Cannot impersonate yourself", nil)
 	}
 
 	session, err := h.sessionStore.Get(r, h.cookieName)
 	if err != nil {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusInternalServerError, "Failed to get session", err))
-		return
+		return types.ImpersonationStartResponse{}, types.NewHTTPError(http.StatusInternalServerError, "Failed to get session", err)
 	}
 
 	if existingState, ok := session.Values["impersonation_state"].(types.ImpersonationState); ok && existingState.Enabled {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusBadRequest, "Already impersonating another user. Stop current impersonation first.", nil))
-		return
+		return types.ImpersonationStartResponse{}, types.NewHTTPError(http.StatusBadRequest, "Already impersonating another user. Stop current impersonation first.", nil)
 	}
 
 	targetUser, err := h.userStore.GetUserByID(ctx, targetUserID)
 	if err != nil {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusNotFound, "Target user not found", err))
-		return
+		return types.ImpersonationStartResponse{}, types.NewHTTPError(http.StatusNotFound, "Target user not found", err)
 	}
 
 	if targetUser.IsAdmin {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusForbidden, "Cannot impersonate admin users", nil))
-		return
+		return types.ImpersonationStartResponse{}, types.NewHTTPError(http.StatusForbidden, "Cannot impersonate admin users", nil)
+	}
+	if !targetUser.IsActive() {
+		return types.ImpersonationStartResponse{}, types.NewHTTPError(http.StatusBadRequest, "Cannot impersonate deactivated users", nil)
 	}
 
 	originalAdminID := adminUser.ID
@@ -249,7 +280,7 @@ func (h *Handlers) ImpersonationStartHandler(w http.ResponseWriter, r *http.Requ
 	impersonationState := types.ImpersonationState{
 		Enabled:         true,
 		Since:           time.Now(),
-		Reason:          strings.TrimSpace(req.Reason),
+		Reason:          req.Reason,
 		TargetUserID:    targetUser.ID,
 		TargetUserEmail: targetUser.Email,
 		TargetUserName:  targetUser.DisplayName,
@@ -262,8 +293,11 @@ func (h *Handlers) ImpersonationStartHandler(w http.ResponseWriter, r *http.Requ
 	session.Values["user_id"] = targetUser.ID.String()
 
 	if err := session.Save(r, w); err != nil {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusInternalServerError, "Failed to save session", err))
-		return
+		return types.ImpersonationStartResponse{}, types.NewHTTPError(http.StatusInternalServerError, "Failed to save session", err)
+	}
+
+	if h.metrics != nil {
+		h.metrics.ImpersonationStarted(originalAdminID, impersonationState.Since.Add(h.adminModeTimeout))
 	}
 
 	log.Info().
@@ -276,62 +310,62 @@ func (h *Handlers) ImpersonationStartHandler(w http.ResponseWriter, r *http.Requ
 		Msg("Impersonation started")
 
 	if h.auditLogger != nil {
-		auditLog := auth.NewAuditLogWithContext(
+		auditLog, err := auth.NewAuditLogWithContext(
 			ctx,
 			types.ActionImpersonationStarted,
 			types.ResourceTypeUser,
 			targetUser.ID.String(),
-		).WithChanges(map[string]interface{}{
-			"admin_id":          originalAdminID.String(),
-			"admin_email":       adminUser.Email,
-			"target_user_id":    targetUser.ID.String(),
-			"target_user_email": targetUser.Email,
-			"target_user_name":  targetUser.DisplayName,
-			"reason":            impersonationState.Reason,
-			"ip_address":        impersonationState.IPAddress,
-			"timeout":           h.adminModeTimeout.String(),
-		}).WithIPAddress(impersonationState.IPAddress).WithUserAgent(r.UserAgent())
+		).WithPayload(types.ImpersonationStartedChanges{
+			AdminID:         originalAdminID.String(),
+			AdminEmail:      adminUser.Email,
+			TargetUserID:    targetUser.ID.String(),
+			TargetUserEmail: targetUser.Email,
+			TargetUserName:  targetUser.DisplayName,
+			Reason:          impersonationState.Reason,
+			Timeout:         h.adminModeTimeout.String(),
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to encode audit payload for impersonation start")
+		}
+		auditLog.WithIPAddress(impersonationState.IPAddress).WithUserAgent(r.UserAgent())
 
 		if err := h.auditLogger.CreateAuditLog(ctx, auditLog); err != nil {
 			log.Error().Err(err).Msg("Failed to create audit log for impersonation start")
 		}
 	}
 
-	response := types.ImpersonationStartResponse{
+	return types.ImpersonationStartResponse{
 		Message:       fmt.Sprintf("Now impersonating %s", targetUser.Email),
 		Impersonation: &impersonationState,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	}, nil
 }
 
 // ImpersonationStopHandler handles POST /api/admin/impersonate/stop.
 func (h *Handlers) ImpersonationStopHandler(w http.ResponseWriter, r *http.Request) {
+	h.stopImpersonationJSON(w, r)
+}
+
+func (h *Handlers) stopImpersonation(w http.ResponseWriter, r *http.Request, _ httpjson.Empty) (types.ImpersonationStopResponse, error) {
 	ctx := r.Context()
 
 	session, err := h.sessionStore.Get(r, h.cookieName)
 	if err != nil {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusInternalServerError, "Failed to get session", err))
-		return
+		return types.ImpersonationStopResponse{}, types.NewHTTPError(http.StatusInternalServerError, "Failed to get session", err)
 	}
 
 	impersonationState, ok := session.Values["impersonation_state"].(types.ImpersonationState)
 	if !ok || !impersonationState.Enabled {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusBadRequest, "Not currently impersonating", nil))
-		return
+		return types.ImpersonationStopResponse{}, types.NewHTTPError(http.StatusBadRequest, "Not currently impersonating", nil)
 	}
 
 	originalAdminIDStr, ok := session.Values["original_user_id"].(string)
 	if !ok {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusInternalServerError, "Original user ID not found in session", nil))
-		return
+		return types.ImpersonationStopResponse{}, types.NewHTTPError(http.StatusInternalServerError, "Original user ID not found in session", nil)
 	}
 
 	originalAdminID, err := uuid.Parse(originalAdminIDStr)
 	if err != nil {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusInternalServerError, "Invalid original user ID in session", nil))
-		return
+		return types.ImpersonationStopResponse{}, types.NewHTTPError(http.StatusInternalServerError, "Invalid original user ID in session", nil)
 	}
 
 	adminUser, err := h.userStore.GetUserByID(ctx, originalAdminID)
@@ -346,8 +380,11 @@ func (h *Handlers) ImpersonationStopHandler(w http.ResponseWriter, r *http.Reque
 	delete(session.Values, "original_user_id")
 
 	if err := session.Save(r, w); err != nil {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusInternalServerError, "Failed to save session", err))
-		return
+		return types.ImpersonationStopResponse{}, types.NewHTTPError(http.StatusInternalServerError, "Failed to save session", err)
+	}
+
+	if h.metrics != nil {
+		h.metrics.ImpersonationEnded(originalAdminID)
 	}
 
 	log.Info().
@@ -365,39 +402,42 @@ func (h *Handlers) ImpersonationStopHandler(w http.ResponseWriter, r *http.Reque
 		Msg("Impersonation stopped")
 
 	if h.auditLogger != nil {
-		auditLog := auth.NewAuditLogWithContext(
+		auditLog, err := auth.NewAuditLogWithContext(
 			ctx,
 			types.ActionImpersonationStopped,
 			types.ResourceTypeUser,
 			impersonationState.TargetUserID.String(),
-		).WithChanges(map[string]interface{}{
-			"admin_id":          originalAdminID.String(),
-			"target_user_id":    impersonationState.TargetUserID.String(),
-			"target_user_email": impersonationState.TargetUserEmail,
-			"reason":            impersonationState.Reason,
-			"duration":          duration.String(),
-			"ip_address":        auth.GetClientIP(r),
-		}).WithIPAddress(auth.GetClientIP(r)).WithUserAgent(r.UserAgent())
+		).WithPayload(types.ImpersonationEndedChanges{
+			AdminID:         originalAdminID.String(),
+			TargetUserID:    impersonationState.TargetUserID.String(),
+			TargetUserEmail: impersonationState.TargetUserEmail,
+			Reason:          impersonationState.Reason,
+			Duration:        duration.String(),
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to encode audit payload for impersonation stop")
+		}
+		auditLog.WithIPAddress(auth.GetClientIP(r)).WithUserAgent(r.UserAgent())
 
 		if err := h.auditLogger.CreateAuditLog(ctx, auditLog); err != nil {
 			log.Error().Err(err).Msg("Failed to create audit log for impersonation stop")
 		}
 	}
 
-	response := types.ImpersonationStopResponse{
+	return types.ImpersonationStopResponse{
 		Message: "Impersonation stopped successfully",
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	}, nil
 }
 
 // ImpersonationStatusHandler handles GET /api/admin/impersonate/status.
 func (h *Handlers) ImpersonationStatusHandler(w http.ResponseWriter, r *http.Request) {
+	h.impersonationStatusJSON(w, r)
+}
+
+func (h *Handlers) impersonationStatus(w http.ResponseWriter, r *http.Request, _ httpjson.Empty) (types.ImpersonationStatusResponse, error) {
 	session, err := h.sessionStore.Get(r, h.cookieName)
 	if err != nil {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusInternalServerError, "Failed to get session", err))
-		return
+		return types.ImpersonationStatusResponse{}, types.NewHTTPError(http.StatusInternalServerError, "Failed to get session", err)
 	}
 
 	response := types.ImpersonationStatusResponse{
@@ -413,8 +453,7 @@ func (h *Handlers) ImpersonationStatusHandler(w http.ResponseWriter, r *http.Req
 		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	return response, nil
 }
 
 // stopExpiredImpersonation cleans up an expired impersonation session.
@@ -438,6 +477,10 @@ func (h *Handlers) stopExpiredImpersonation(w http.ResponseWriter, r *http.Reque
 	delete(session.Values, "original_user_id")
 	session.Save(r, w)
 
+	if h.metrics != nil {
+		h.metrics.ImpersonationEnded(originalAdminID)
+	}
+
 	log.Warn().
 		Str("admin_id", originalAdminID.String()).
 		Str("target_user_id", state.TargetUserID.String()).
@@ -446,19 +489,22 @@ func (h *Handlers) stopExpiredImpersonation(w http.ResponseWriter, r *http.Reque
 		Msg("Impersonation session expired")
 
 	if h.auditLogger != nil {
-		auditLog := auth.NewAuditLogWithContext(
+		auditLog, err := auth.NewAuditLogWithContext(
 			ctx,
 			types.ActionImpersonationExpired,
 			types.ResourceTypeUser,
 			state.TargetUserID.String(),
-		).WithChanges(map[string]interface{}{
-			"admin_id":          originalAdminID.String(),
-			"target_user_id":    state.TargetUserID.String(),
-			"target_user_email": state.TargetUserEmail,
-			"reason":            state.Reason,
-			"duration":          state.Duration().String(),
-			"ip_address":        auth.GetClientIP(r),
-		}).WithIPAddress(auth.GetClientIP(r)).WithUserAgent(r.UserAgent())
+		).WithPayload(types.ImpersonationEndedChanges{
+			AdminID:         originalAdminID.String(),
+			TargetUserID:    state.TargetUserID.String(),
+			TargetUserEmail: state.TargetUserEmail,
+			Reason:          state.Reason,
+			Duration:        state.Duration().String(),
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to encode audit payload for impersonation expiration")
+		}
+		auditLog.WithIPAddress(auth.GetClientIP(r)).WithUserAgent(r.UserAgent())
 
 		if err := h.auditLogger.CreateAuditLog(context.Background(), auditLog); err != nil {
 			log.Error().Err(err).Msg("Failed to create audit log for impersonation expiration")
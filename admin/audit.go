@@ -0,0 +1,110 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/juanfont/juango/httpjson"
+	"github.com/juanfont/juango/types"
+)
+
+// AuditLogReader lists audit log entries. It is implemented by
+// database.Database.
+type AuditLogReader interface {
+	ListAuditLogs(ctx context.Context, filter types.AuditLogFilter) ([]types.AuditLogEntry, error)
+}
+
+// AuditLogsHandler handles GET /api/admin/audit-logs.
+//
+// Supported query parameters are actor_user_id, impersonated_user_id,
+// action, resource_type, resource_id, session_id, request_id, trace_id,
+// start_time and end_time (RFC 3339), limit and offset.
+func AuditLogsHandler(reader AuditLogReader) http.HandlerFunc {
+	return httpjson.Handle(func(w http.ResponseWriter, r *http.Request, _ httpjson.Empty) ([]types.AuditLogEntry, error) {
+		filter, err := ParseAuditLogFilter(r.URL.Query())
+		if err != nil {
+			return nil, types.NewHTTPError(http.StatusBadRequest, err.Error(), err)
+		}
+
+		entries, err := reader.ListAuditLogs(r.Context(), filter)
+		if err != nil {
+			return nil, types.NewHTTPError(http.StatusInternalServerError, "Failed to list audit logs", err)
+		}
+		return entries, nil
+	})
+}
+
+// ParseAuditLogFilter builds an audit log filter from URL query parameters.
+func ParseAuditLogFilter(q url.Values) (types.AuditLogFilter, error) {
+	filter := types.AuditLogFilter{
+		Action:       q.Get("action"),
+		ResourceType: q.Get("resource_type"),
+		ResourceID:   q.Get("resource_id"),
+		SessionID:    q.Get("session_id"),
+		RequestID:    q.Get("request_id"),
+		TraceID:      q.Get("trace_id"),
+	}
+
+	var err error
+	if filter.ActorUserID, err = parseUUIDParam(q, "actor_user_id"); err != nil {
+		return filter, err
+	}
+	if filter.ImpersonatedUserID, err = parseUUIDParam(q, "impersonated_user_id"); err != nil {
+		return filter, err
+	}
+	if filter.StartTime, err = parseTimeParam(q, "start_time"); err != nil {
+		return filter, err
+	}
+	if filter.EndTime, err = parseTimeParam(q, "end_time"); err != nil {
+		return filter, err
+	}
+	if filter.Limit, err = parseIntParam(q, "limit"); err != nil {
+		return filter, err
+	}
+	if filter.Offset, err = parseIntParam(q, "offset"); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+func parseUUIDParam(q url.Values, name string) (uuid.UUID, error) {
+	v := q.Get(name)
+	if v == "" {
+		return uuid.Nil, nil
+	}
+	id, err := uuid.Parse(v)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return id, nil
+}
+
+func parseTimeParam(q url.Values, name string) (time.Time, error) {
+	v := q.Get(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return t, nil
+}
+
+func parseIntParam(q url.Values, name string) (int, error) {
+	v := q.Get(name)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s: %q", name, v)
+	}
+	return n, nil
+}
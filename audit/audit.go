@@ -0,0 +1,287 @@
+// Package audit streams audit log entries to external sinks such as syslog,
+// webhooks and JSON-lines files, in addition to the primary database store.
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/juanfont/juango/config"
+	"github.com/juanfont/juango/types"
+	"github.com/rs/zerolog/log"
+)
+
+// Default delivery settings.
+const (
+	DefaultQueueSize      = 1000
+	DefaultMaxRetries     = 5
+	DefaultInitialBackoff = time.Second
+	DefaultMaxBackoff     = time.Minute
+	DefaultSendTimeout    = 10 * time.Second
+)
+
+// Store is the primary, synchronous audit log store (usually SQLite).
+// It has the same shape as auth.AuditLogger. A Store may validate entries
+// like FanOut does, returning the validation error after storing them.
+type Store interface {
+	CreateAuditLog(ctx context.Context, log *types.AuditLog) error
+}
+
+// Sink is an external destination for audit events.
+type Sink interface {
+	// Name identifies the sink in logs.
+	Name() string
+
+	// Send delivers a single event. Returning an error wrapped with
+	// Permanent stops any further retries for that event.
+	Send(ctx context.Context, event *Event) error
+
+	// Close releases any resources held by the sink.
+	Close() error
+}
+
+// Event is the representation of an audit log entry sent to sinks.
+type Event struct {
+	Timestamp    time.Time      `json:"timestamp"`
+	ActorUserID  string         `json:"actor_user_id,omitempty"`
+	Action       string         `json:"action"`
+	ResourceType string         `json:"resource_type"`
+	ResourceID   string         `json:"resource_id"`
+	Changes      map[string]any `json:"changes,omitempty"`
+	IPAddress    string         `json:"ip_address,omitempty"`
+	UserAgent    string         `json:"user_agent,omitempty"`
+
+	ImpersonatedUserID string `json:"impersonated_user_id,omitempty"`
+	SessionID          string `json:"session_id,omitempty"`
+	RequestID          string `json:"request_id,omitempty"`
+	TraceID            string `json:"trace_id,omitempty"`
+}
+
+// NewEvent converts an audit log entry into a sink event.
+func NewEvent(l *types.AuditLog) *Event {
+	event := &Event{
+		Timestamp:    l.Timestamp.UTC(),
+		Action:       l.Action,
+		ResourceType: l.ResourceType,
+		ResourceID:   l.ResourceID,
+		IPAddress:    l.IPAddress.String,
+		UserAgent:    l.UserAgent.String,
+		SessionID:    l.SessionID.String,
+		RequestID:    l.RequestID.String,
+		TraceID:      l.TraceID.String,
+	}
+	if l.ActorUserID.Valid {
+		event.ActorUserID = l.ActorUserID.UUID.String()
+	}
+	if l.ImpersonatedUserID.Valid {
+		event.ImpersonatedUserID = l.ImpersonatedUserID.UUID.String()
+	}
+	if len(l.Changes) > 0 {
+		// Copy so later mutations by the caller don't race with delivery.
+		event.Changes = make(map[string]any, len(l.Changes))
+		for k, v := range l.Changes {
+			event.Changes[k] = v
+		}
+	}
+	return event
+}
+
+// permanentError marks an error that should not be retried.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so that delivery of the event is not retried.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether err was wrapped with Permanent.
+func IsPermanent(err error) bool {
+	var perr permanentError
+	return errors.As(err, &perr)
+}
+
+// Options configures queueing and retry behaviour of a FanOut logger.
+type Options struct {
+	// QueueSize is the number of events buffered per sink. When a sink's
+	// queue is full, new events for that sink are dropped.
+	QueueSize int
+
+	// MaxRetries is the number of times a failed delivery is retried
+	// (default: DefaultMaxRetries). A negative value disables retries.
+	MaxRetries int
+
+	// InitialBackoff is the delay before the first retry. It doubles on
+	// each attempt up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// SendTimeout bounds a single delivery attempt.
+	SendTimeout time.Duration
+}
+
+func (o *Options) withDefaults() Options {
+	opts := Options{}
+	if o != nil {
+		opts = *o
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultQueueSize
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = DefaultMaxRetries
+	} else if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = DefaultInitialBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+	if opts.SendTimeout <= 0 {
+		opts.SendTimeout = DefaultSendTimeout
+	}
+	return opts
+}
+
+// FanOut is an audit logger that writes synchronously to a primary store
+// and then asynchronously to any number of sinks. Sink delivery never
+// blocks the caller: each sink has its own bounded queue and worker.
+type FanOut struct {
+	primary Store
+	workers []*worker
+
+	closeOnce sync.Once
+}
+
+// NewFanOut creates a FanOut logger. primary may be nil, in which case
+// events are only sent to sinks.
+func NewFanOut(primary Store, opts *Options, sinks ...Sink) *FanOut {
+	o := opts.withDefaults()
+
+	f := &FanOut{primary: primary}
+	for _, sink := range sinks {
+		f.workers = append(f.workers, startWorker(sink, o))
+	}
+	return f
+}
+
+// NewFromConfig creates a FanOut logger with the sinks enabled in cfg.
+func NewFromConfig(primary Store, cfg config.AuditConfig) (*FanOut, error) {
+	var sinks []Sink
+
+	if cfg.Syslog.Enabled {
+		sink, err := NewSyslogSink(SyslogConfig{
+			Network:  cfg.Syslog.Network,
+			Addr:     cfg.Syslog.Addr,
+			AppName:  cfg.Syslog.AppName,
+			Facility: cfg.Syslog.Facility,
+		})
+		if err != nil {
+			closeSinks(sinks)
+			return nil, fmt.Errorf("creating syslog audit sink: %w", err)
+		}
+		sinks = append(sinks, sink)
+	}
+
+	if cfg.Webhook.Enabled {
+		sink, err := NewWebhookSink(WebhookConfig{
+			URL:     cfg.Webhook.URL,
+			Secret:  cfg.Webhook.Secret,
+			Timeout: cfg.Webhook.Timeout,
+		})
+		if err != nil {
+			closeSinks(sinks)
+			return nil, fmt.Errorf("creating webhook audit sink: %w", err)
+		}
+		sinks = append(sinks, sink)
+	}
+
+	if cfg.File.Enabled {
+		sink, err := NewFileSink(FileConfig{
+			Path:       cfg.File.Path,
+			MaxSize:    int64(cfg.File.MaxSizeMB) * 1024 * 1024,
+			MaxBackups: cfg.File.MaxBackups,
+		})
+		if err != nil {
+			closeSinks(sinks)
+			return nil, fmt.Errorf("creating file audit sink: %w", err)
+		}
+		sinks = append(sinks, sink)
+	}
+
+	return NewFanOut(primary, &Options{
+		QueueSize:      cfg.QueueSize,
+		MaxRetries:     cfg.MaxRetries,
+		InitialBackoff: cfg.InitialBackoff,
+		MaxBackoff:     cfg.MaxBackoff,
+		SendTimeout:    cfg.SendTimeout,
+	}, sinks...), nil
+}
+
+// CreateAuditLog writes the entry to the primary store and queues it for
+// delivery to every sink. Entries that don't match their registered
+// payload schema are still written and delivered; the validation error is
+// returned afterwards (see types.IsAuditValidationError).
+// Implements auth.AuditLogger.
+func (f *FanOut) CreateAuditLog(ctx context.Context, l *types.AuditLog) error {
+	validationErr := l.Validate()
+
+	if f.primary != nil {
+		// The primary store may validate too; its validation error means
+		// the entry was stored.
+		if err := f.primary.CreateAuditLog(ctx, l); err != nil && !types.IsAuditValidationError(err) {
+			return err
+		}
+	}
+
+	if len(f.workers) == 0 {
+		return validationErr
+	}
+
+	event := NewEvent(l)
+	for _, w := range f.workers {
+		w.enqueue(event)
+	}
+	return validationErr
+}
+
+// Close stops accepting events, waits for queued events to be delivered
+// and closes all sinks. When ctx expires, pending retries are abandoned
+// and in-flight sends are cancelled.
+func (f *FanOut) Close(ctx context.Context) error {
+	var errs []error
+	f.closeOnce.Do(func() {
+		for _, w := range f.workers {
+			w.stop()
+		}
+		for _, w := range f.workers {
+			if err := w.wait(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", w.sink.Name(), err))
+			}
+			if err := w.sink.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("closing %s: %w", w.sink.Name(), err))
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+func closeSinks(sinks []Sink) {
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			log.Warn().Err(err).Str("sink", sink.Name()).Msg("Failed to close audit sink")
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/juanfont/juango/database"
+	"github.com/juanfont/juango/types"
+)
+
+// chanSink delivers events to a channel.
+type chanSink chan *Event
+
+func (s chanSink) Name() string { return "chan" }
+
+func (s chanSink) Send(ctx context.Context, event *Event) error {
+	s <- event
+	return nil
+}
+
+func (s chanSink) Close() error { return nil }
+
+func TestFanOutKeepsInvalidEntries(t *testing.T) {
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"), database.BaseSchema())
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	store, err := database.NewStore(context.Background(), db)
+	if err != nil {
+		t.Fatalf("creating store: %v", err)
+	}
+	defer store.Close()
+
+	sink := make(chanSink, 1)
+	fanOut := NewFanOut(store, nil, sink)
+	defer fanOut.Close(context.Background())
+
+	tests := []struct {
+		name  string
+		entry *types.AuditLog
+		want  error
+	}{
+		{
+			name:  "unregistered action",
+			entry: types.NewAuditLog(nil, "item.unregistered", "item", "1"),
+			want:  types.ErrUnknownAuditAction,
+		},
+		{
+			name: "payload mismatch",
+			entry: types.NewAuditLog(nil, types.ActionUserLoggedIn, types.ResourceTypeUser, "2").
+				AddDetail("unexpected", true),
+			want: types.ErrInvalidAuditChange,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := fanOut.CreateAuditLog(context.Background(), tt.entry)
+			if !errors.Is(err, tt.want) || !types.IsAuditValidationError(err) {
+				t.Fatalf("CreateAuditLog error = %v, want %v", err, tt.want)
+			}
+
+			entries, err := store.ListAuditLogs(context.Background(), types.AuditLogFilter{
+				ResourceID: tt.entry.ResourceID,
+			})
+			if err != nil {
+				t.Fatalf("listing audit logs: %v", err)
+			}
+			if len(entries) != 1 || entries[0].Action != tt.entry.Action {
+				t.Errorf("stored entries = %+v, want the %s entry", entries, tt.entry.Action)
+			}
+
+			select {
+			case event := <-sink:
+				if event.Action != tt.entry.Action {
+					t.Errorf("delivered action %q, want %q", event.Action, tt.entry.Action)
+				}
+			case <-time.After(5 * time.Second):
+				t.Errorf("entry not delivered to sink")
+			}
+		})
+	}
+}
+
+func TestWithPayloadError(t *testing.T) {
+	entry, err := types.NewAuditLog(nil, types.ActionTaskFailed, "task", "1").
+		WithPayload(map[string]any{"bad": make(chan int)})
+	if !errors.Is(err, types.ErrInvalidAuditChange) {
+		t.Fatalf("WithPayload error = %v, want ErrInvalidAuditChange", err)
+	}
+	if entry == nil || len(entry.Changes) != 0 {
+		t.Errorf("WithPayload changed the entry on error: %+v", entry)
+	}
+}
@@ -0,0 +1,181 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default file sink settings.
+const (
+	DefaultFileMaxSize    = 100 * 1024 * 1024
+	DefaultFileMaxBackups = 5
+)
+
+// FileConfig holds configuration for a FileSink.
+type FileConfig struct {
+	// Path is the file events are appended to.
+	Path string
+
+	// MaxSize is the size in bytes at which the file is rotated (default: 100MB).
+	MaxSize int64
+
+	// MaxBackups is the number of rotated files to keep (default: 5).
+	MaxBackups int
+}
+
+// backupTimeLayout is the timestamp suffix of rotated files.
+const backupTimeLayout = "20060102T150405.000000000"
+
+// FileSink appends events as JSON lines to a file, rotating it when it
+// grows beyond MaxSize. Rotated files are renamed to <path>.<timestamp>.
+type FileSink struct {
+	cfg FileConfig
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewFileSink creates a new file sink, opening (or creating) the file.
+func NewFileSink(cfg FileConfig) (*FileSink, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("audit file path is required")
+	}
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = DefaultFileMaxSize
+	}
+	if cfg.MaxBackups <= 0 {
+		cfg.MaxBackups = DefaultFileMaxBackups
+	}
+
+	s := &FileSink{cfg: cfg}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Name implements Sink.
+func (s *FileSink) Name() string {
+	return "file"
+}
+
+// Send implements Sink.
+func (s *FileSink) Send(ctx context.Context, event *Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return Permanent(fmt.Errorf("encoding audit event: %w", err))
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+
+	if s.size > 0 && s.size+int64(len(line)) > s.cfg.MaxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("writing audit file: %w", err)
+	}
+	return nil
+}
+
+// Close implements Sink.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *FileSink) open() error {
+	if err := os.MkdirAll(filepath.Dir(s.cfg.Path), 0o750); err != nil {
+		return fmt.Errorf("creating audit file directory: %w", err)
+	}
+
+	f, err := os.OpenFile(s.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("opening audit file: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("stat audit file: %w", err)
+	}
+
+	s.file = f
+	s.size = info.Size()
+	return nil
+}
+
+// rotate renames the current file and opens a fresh one. Must be called
+// with s.mu held.
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("closing audit file: %w", err)
+	}
+	s.file = nil
+
+	backup := s.cfg.Path + "." + time.Now().UTC().Format(backupTimeLayout)
+	if err := os.Rename(s.cfg.Path, backup); err != nil {
+		return fmt.Errorf("rotating audit file: %w", err)
+	}
+
+	s.pruneBackups()
+	return s.open()
+}
+
+// pruneBackups removes the oldest rotated files beyond MaxBackups.
+func (s *FileSink) pruneBackups() {
+	matches, err := filepath.Glob(s.cfg.Path + ".*")
+	if err != nil {
+		return
+	}
+
+	// Only touch files named like our backups, not e.g. <path>.lock.
+	prefix := s.cfg.Path + "."
+	backups := matches[:0]
+	for _, m := range matches {
+		suffix, ok := strings.CutPrefix(m, prefix)
+		if !ok || len(suffix) != len(backupTimeLayout) {
+			continue
+		}
+		if _, err := time.Parse(backupTimeLayout, suffix); err == nil {
+			backups = append(backups, m)
+		}
+	}
+	if len(backups) <= s.cfg.MaxBackups {
+		return
+	}
+
+	// Timestamp suffixes sort chronologically.
+	sort.Strings(backups)
+	for _, old := range backups[:len(backups)-s.cfg.MaxBackups] {
+		os.Remove(old)
+	}
+}
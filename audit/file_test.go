@@ -0,0 +1,64 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFileSinkRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+
+	// Files next to the log that aren't backups must survive pruning.
+	unrelated := []string{path + ".lock", path + ".old", path + ".20260101"}
+	for _, name := range unrelated {
+		if err := os.WriteFile(name, nil, 0o600); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+
+	sink, err := NewFileSink(FileConfig{Path: path, MaxSize: 1, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("creating sink: %v", err)
+	}
+	defer sink.Close()
+
+	// MaxSize 1 rotates before every write after the first.
+	for range 5 {
+		if err := sink.Send(context.Background(), testEvent); err != nil {
+			t.Fatalf("sending: %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("reading dir: %v", err)
+	}
+	var backups []string
+	for _, e := range entries {
+		name := filepath.Join(dir, e.Name())
+		if name != path && !slices.Contains(unrelated, name) {
+			backups = append(backups, name)
+		}
+	}
+	if len(backups) != 2 {
+		t.Errorf("backups = %v, want 2", backups)
+	}
+	for _, name := range unrelated {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("unrelated file %s was removed", name)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading log: %v", err)
+	}
+	if n := bytes.Count(data, []byte("\n")); n != 1 {
+		t.Errorf("current file has %d lines, want 1", n)
+	}
+}
@@ -0,0 +1,190 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSyslogFacility is the "log audit" facility (13) from RFC 5424.
+	DefaultSyslogFacility = 13
+
+	// syslogSeverityInfo is the informational severity level.
+	syslogSeverityInfo = 6
+
+	// utf8BOM marks the MSG part as UTF-8, as recommended by RFC 5424.
+	utf8BOM = "\xEF\xBB\xBF"
+)
+
+// SyslogConfig holds configuration for a SyslogSink.
+type SyslogConfig struct {
+	// Network is "udp" or "tcp" (default: "udp").
+	Network string
+
+	// Addr is the host:port of the syslog receiver.
+	Addr string
+
+	// AppName is the APP-NAME field (default: "juango").
+	AppName string
+
+	// Facility is the syslog facility code, 0 (kern) to 23 (default: 13,
+	// log audit).
+	Facility *int
+
+	// DialTimeout bounds connection establishment (default: 5s).
+	DialTimeout time.Duration
+}
+
+// SyslogSink sends events as RFC 5424 messages over UDP or TCP.
+// TCP messages use octet-counting framing from RFC 6587.
+type SyslogSink struct {
+	cfg      SyslogConfig
+	facility int
+	hostname string
+	procID   string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogSink creates a new syslog sink. The connection is established
+// lazily on the first event.
+func NewSyslogSink(cfg SyslogConfig) (*SyslogSink, error) {
+	if cfg.Addr == "" {
+		return nil, errors.New("syslog address is required")
+	}
+	if cfg.Network == "" {
+		cfg.Network = "udp"
+	}
+	if cfg.Network != "udp" && cfg.Network != "tcp" {
+		return nil, fmt.Errorf("unsupported syslog network %q", cfg.Network)
+	}
+	if cfg.AppName == "" {
+		cfg.AppName = "juango"
+	}
+	facility := DefaultSyslogFacility
+	if cfg.Facility != nil {
+		facility = *cfg.Facility
+	}
+	if facility < 0 || facility > 23 {
+		return nil, fmt.Errorf("invalid syslog facility %d", facility)
+	}
+	if cfg.DialTimeout == 0 {
+		cfg.DialTimeout = 5 * time.Second
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	return &SyslogSink{
+		cfg:      cfg,
+		facility: facility,
+		hostname: syslogField(hostname, 255),
+		procID:   strconv.Itoa(os.Getpid()),
+	}, nil
+}
+
+// Name implements Sink.
+func (s *SyslogSink) Name() string {
+	return "syslog"
+}
+
+// Send implements Sink.
+func (s *SyslogSink) Send(ctx context.Context, event *Event) error {
+	msg, err := s.format(event)
+	if err != nil {
+		return Permanent(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		dialer := net.Dialer{Timeout: s.cfg.DialTimeout}
+		conn, err := dialer.DialContext(ctx, s.cfg.Network, s.cfg.Addr)
+		if err != nil {
+			return fmt.Errorf("connecting to syslog: %w", err)
+		}
+		s.conn = conn
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetWriteDeadline(deadline)
+	}
+
+	frame := msg
+	if s.cfg.Network == "tcp" {
+		frame = strconv.Itoa(len(msg)) + " " + msg
+	}
+
+	if _, err := s.conn.Write([]byte(frame)); err != nil {
+		// Drop the connection so the next attempt reconnects.
+		s.conn.Close()
+		s.conn = nil
+		return fmt.Errorf("writing to syslog: %w", err)
+	}
+	return nil
+}
+
+// Close implements Sink.
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// format renders an event as an RFC 5424 message. The event JSON is used
+// as the MSG part; the action is used as MSGID.
+func (s *SyslogSink) format(event *Event) (string, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return "", fmt.Errorf("encoding audit event: %w", err)
+	}
+
+	pri := s.facility*8 + syslogSeverityInfo
+
+	return fmt.Sprintf("<%d>1 %s %s %s %s %s - %s%s",
+		pri,
+		event.Timestamp.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		s.hostname,
+		syslogField(s.cfg.AppName, 48),
+		syslogField(s.procID, 128),
+		syslogField(event.Action, 32),
+		utf8BOM,
+		body,
+	), nil
+}
+
+// syslogField sanitizes a header field to printable US-ASCII without
+// spaces and truncates it to max characters, as required by RFC 5424.
+func syslogField(v string, max int) string {
+	v = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, v)
+	if v == "" {
+		return "-"
+	}
+	if len(v) > max {
+		v = v[:max]
+	}
+	return v
+}
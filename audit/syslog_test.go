@@ -0,0 +1,138 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testEvent = &Event{
+	Timestamp:    time.Date(2026, 1, 2, 3, 4, 5, 6000, time.UTC),
+	ActorUserID:  "11111111-1111-1111-1111-111111111111",
+	Action:       "user.logged_in",
+	ResourceType: "user",
+	ResourceID:   "1",
+}
+
+// rfc5424Re matches the header of the messages SyslogSink sends.
+var rfc5424Re = regexp.MustCompile(`^<(\d+)>1 (\S+) (\S+) (\S+) (\d+) (\S+) - \x{FEFF}(\{.*\})$`)
+
+func TestSyslogSinkUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	defer conn.Close()
+
+	kern := 0
+	sink, err := NewSyslogSink(SyslogConfig{Addr: conn.LocalAddr().String(), AppName: "my app", Facility: &kern})
+	if err != nil {
+		t.Fatalf("creating sink: %v", err)
+	}
+	defer sink.Close()
+
+	if err := sink.Send(context.Background(), testEvent); err != nil {
+		t.Fatalf("sending: %v", err)
+	}
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("reading: %v", err)
+	}
+
+	m := rfc5424Re.FindStringSubmatch(string(buf[:n]))
+	if m == nil {
+		t.Fatalf("message is not RFC 5424: %q", buf[:n])
+	}
+	if m[1] != "6" { // kern.info
+		t.Errorf("PRI = %s, want 6", m[1])
+	}
+	if m[2] != "2026-01-02T03:04:05.000006Z" {
+		t.Errorf("TIMESTAMP = %s", m[2])
+	}
+	if m[4] != "my_app" {
+		t.Errorf("APP-NAME = %s, want my_app", m[4])
+	}
+	if m[6] != "user.logged_in" {
+		t.Errorf("MSGID = %s, want user.logged_in", m[6])
+	}
+	if !strings.Contains(m[7], `"resource_id":"1"`) {
+		t.Errorf("MSG = %s", m[7])
+	}
+}
+
+func TestSyslogSinkTCPOctetCounting(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	defer ln.Close()
+
+	frames := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			length, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+			if err != nil {
+				frames <- "bad length " + length
+				return
+			}
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(r, msg); err != nil {
+				return
+			}
+			frames <- string(msg)
+		}
+	}()
+
+	sink, err := NewSyslogSink(SyslogConfig{Network: "tcp", Addr: ln.Addr().String()})
+	if err != nil {
+		t.Fatalf("creating sink: %v", err)
+	}
+	defer sink.Close()
+
+	for range 2 {
+		if err := sink.Send(context.Background(), testEvent); err != nil {
+			t.Fatalf("sending: %v", err)
+		}
+	}
+	for range 2 {
+		select {
+		case frame := <-frames:
+			m := rfc5424Re.FindStringSubmatch(frame)
+			if m == nil {
+				t.Fatalf("frame is not RFC 5424: %q", frame)
+			}
+			if m[1] != "110" { // log audit (13) * 8 + info (6)
+				t.Errorf("PRI = %s, want 110", m[1])
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for frame")
+		}
+	}
+}
+
+func TestNewSyslogSinkInvalidFacility(t *testing.T) {
+	for _, facility := range []int{-1, 24} {
+		if _, err := NewSyslogSink(SyslogConfig{Addr: "127.0.0.1:514", Facility: &facility}); err == nil {
+			t.Errorf("facility %d accepted", facility)
+		}
+	}
+}
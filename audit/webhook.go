@@ -0,0 +1,147 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Webhook signature headers.
+const (
+	WebhookSignatureHeader = "X-Juango-Signature"
+	WebhookTimestampHeader = "X-Juango-Timestamp"
+)
+
+// WebhookConfig holds configuration for a WebhookSink.
+type WebhookConfig struct {
+	// URL is the HTTPS endpoint events are POSTed to.
+	URL string
+
+	// Secret is the HMAC-SHA256 key used to sign requests.
+	Secret string
+
+	// Timeout bounds a single request (default: 10s).
+	Timeout time.Duration
+
+	// AllowInsecure permits plain HTTP URLs. Only use it for local testing.
+	AllowInsecure bool
+
+	// Client is the HTTP client to use (default: a client with Timeout).
+	Client *http.Client
+}
+
+// WebhookSink POSTs each event as JSON to an HTTPS endpoint.
+//
+// Requests carry an X-Juango-Timestamp header with the Unix time and an
+// X-Juango-Signature header of the form "sha256=<hex>", where the digest is
+// HMAC-SHA256(secret, timestamp + "." + body). Receivers should recompute the
+// signature and reject stale timestamps.
+type WebhookSink struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+// NewWebhookSink creates a new webhook sink.
+func NewWebhookSink(cfg WebhookConfig) (*WebhookSink, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid webhook URL %q", cfg.URL)
+	}
+	if u.Scheme != "https" && !(cfg.AllowInsecure && u.Scheme == "http") {
+		return nil, fmt.Errorf("webhook URL must use https, got %q", u.Scheme)
+	}
+	if cfg.Secret == "" {
+		return nil, errors.New("webhook secret is required")
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultSendTimeout
+	}
+
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: cfg.Timeout}
+	}
+
+	return &WebhookSink{
+		url:    cfg.URL,
+		secret: []byte(cfg.Secret),
+		client: client,
+	}, nil
+}
+
+// Name implements Sink.
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+// Send implements Sink.
+func (s *WebhookSink) Send(ctx context.Context, event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return Permanent(fmt.Errorf("encoding audit event: %w", err))
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(s.secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	err = fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	// Client errors other than timeouts and rate limiting won't succeed on retry.
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout &&
+		resp.StatusCode != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+	return err
+}
+
+// Close implements Sink.
+func (s *WebhookSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// SignWebhook computes the hex-encoded HMAC-SHA256 signature of a webhook
+// request body for the given timestamp.
+func SignWebhook(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks a signature header value ("sha256=<hex>") against
+// the request body and timestamp.
+func VerifyWebhook(secret []byte, timestamp string, body []byte, signature string) bool {
+	expected := "sha256=" + SignWebhook(secret, timestamp, body)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
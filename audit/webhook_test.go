@@ -0,0 +1,95 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWebhookSinkSignsRequests(t *testing.T) {
+	secret := []byte("s3cret")
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer srv.Close()
+
+	sink, err := NewWebhookSink(WebhookConfig{URL: srv.URL, Secret: string(secret), AllowInsecure: true})
+	if err != nil {
+		t.Fatalf("creating sink: %v", err)
+	}
+	defer sink.Close()
+
+	if err := sink.Send(context.Background(), testEvent); err != nil {
+		t.Fatalf("sending: %v", err)
+	}
+
+	r, body := <-received, <-bodies
+	timestamp := r.Header.Get(WebhookTimestampHeader)
+	if ts, err := strconv.ParseInt(timestamp, 10, 64); err != nil || time.Since(time.Unix(ts, 0)) > time.Minute {
+		t.Errorf("%s = %q, want the current Unix time", WebhookTimestampHeader, timestamp)
+	}
+	signature := r.Header.Get(WebhookSignatureHeader)
+	if !VerifyWebhook(secret, timestamp, body, signature) {
+		t.Errorf("signature %q does not verify", signature)
+	}
+	if VerifyWebhook([]byte("other"), timestamp, body, signature) {
+		t.Errorf("signature verifies with the wrong secret")
+	}
+	if VerifyWebhook(secret, timestamp, append(body, ' '), signature) {
+		t.Errorf("signature verifies a modified body")
+	}
+
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil || event.Action != testEvent.Action {
+		t.Errorf("body = %s (%v), want the event", body, err)
+	}
+}
+
+func TestWebhookSinkRetryableStatus(t *testing.T) {
+	tests := []struct {
+		status    int
+		wantErr   bool
+		permanent bool
+	}{
+		{status: http.StatusNoContent},
+		{status: http.StatusBadRequest, wantErr: true, permanent: true},
+		{status: http.StatusTooManyRequests, wantErr: true},
+		{status: http.StatusRequestTimeout, wantErr: true},
+		{status: http.StatusBadGateway, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.status), func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			sink, err := NewWebhookSink(WebhookConfig{URL: srv.URL, Secret: "s", AllowInsecure: true})
+			if err != nil {
+				t.Fatalf("creating sink: %v", err)
+			}
+			err = sink.Send(context.Background(), testEvent)
+			if (err != nil) != tt.wantErr || IsPermanent(err) != tt.permanent {
+				t.Errorf("Send error = %v, want error %v, permanent %v", err, tt.wantErr, tt.permanent)
+			}
+		})
+	}
+}
+
+func TestNewWebhookSinkRequiresHTTPS(t *testing.T) {
+	if _, err := NewWebhookSink(WebhookConfig{URL: "http://example.com/hook", Secret: "s"}); err == nil {
+		t.Errorf("plain HTTP URL accepted without AllowInsecure")
+	}
+	if _, err := NewWebhookSink(WebhookConfig{URL: "https://example.com/hook"}); err == nil {
+		t.Errorf("missing secret accepted")
+	}
+}
@@ -0,0 +1,143 @@
+package audit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// worker delivers events to a single sink from a bounded queue.
+type worker struct {
+	sink  Sink
+	opts  Options
+	queue chan *Event
+
+	// ctx is cancelled when Close gives up waiting, to abort pending
+	// backoffs and in-flight sends.
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu      sync.RWMutex
+	stopped bool
+
+	dropped atomic.Uint64
+}
+
+func startWorker(sink Sink, opts Options) *worker {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &worker{
+		sink:   sink,
+		opts:   opts,
+		queue:  make(chan *Event, opts.QueueSize),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// enqueue queues an event without blocking. Events are dropped when the
+// queue is full or the worker has been stopped.
+func (w *worker) enqueue(event *Event) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.stopped {
+		return
+	}
+
+	select {
+	case w.queue <- event:
+	default:
+		dropped := w.dropped.Add(1)
+		log.Warn().
+			Str("sink", w.sink.Name()).
+			Str("action", event.Action).
+			Uint64("dropped_total", dropped).
+			Msg("Audit sink queue full, dropping event")
+	}
+}
+
+// stop closes the queue; the worker exits once it has been drained.
+func (w *worker) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.stopped {
+		w.stopped = true
+		close(w.queue)
+	}
+}
+
+// wait blocks until the queue is drained or ctx expires.
+func (w *worker) wait(ctx context.Context) error {
+	select {
+	case <-w.done:
+		w.cancel()
+		return nil
+	case <-ctx.Done():
+		w.cancel()
+		<-w.done
+		return ctx.Err()
+	}
+}
+
+func (w *worker) run() {
+	defer close(w.done)
+
+	for event := range w.queue {
+		if w.ctx.Err() != nil {
+			continue
+		}
+		w.deliver(event)
+	}
+}
+
+// deliver sends an event, retrying with exponential backoff.
+func (w *worker) deliver(event *Event) {
+	backoff := w.opts.InitialBackoff
+
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(w.ctx, w.opts.SendTimeout)
+		err := w.sink.Send(ctx, event)
+		cancel()
+		if err == nil {
+			return
+		}
+
+		if IsPermanent(err) || attempt >= w.opts.MaxRetries || w.ctx.Err() != nil {
+			log.Error().
+				Err(err).
+				Str("sink", w.sink.Name()).
+				Str("action", event.Action).
+				Int("attempts", attempt+1).
+				Msg("Failed to deliver audit event")
+			return
+		}
+
+		log.Debug().
+			Err(err).
+			Str("sink", w.sink.Name()).
+			Int("attempt", attempt+1).
+			Dur("backoff", backoff).
+			Msg("Audit event delivery failed, retrying")
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-w.ctx.Done():
+			timer.Stop()
+			return
+		}
+
+		backoff *= 2
+		if backoff > w.opts.MaxBackoff {
+			backoff = w.opts.MaxBackoff
+		}
+	}
+}
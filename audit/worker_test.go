@@ -0,0 +1,95 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/juanfont/juango/types"
+)
+
+// funcSink calls send for every event.
+type funcSink func(ctx context.Context, event *Event) error
+
+func (s funcSink) Name() string { return "func" }
+
+func (s funcSink) Send(ctx context.Context, event *Event) error { return s(ctx, event) }
+
+func (s funcSink) Close() error { return nil }
+
+func TestOptionsDefaults(t *testing.T) {
+	tests := []struct {
+		maxRetries int
+		want       int
+	}{
+		{maxRetries: 0, want: DefaultMaxRetries},
+		{maxRetries: 2, want: 2},
+		{maxRetries: -1, want: 0},
+	}
+	for _, tt := range tests {
+		opts := (&Options{MaxRetries: tt.maxRetries}).withDefaults()
+		if opts.MaxRetries != tt.want {
+			t.Errorf("MaxRetries %d: got %d, want %d", tt.maxRetries, opts.MaxRetries, tt.want)
+		}
+	}
+	if opts := (*Options)(nil).withDefaults(); opts.MaxRetries != DefaultMaxRetries {
+		t.Errorf("nil Options: MaxRetries = %d, want %d", opts.MaxRetries, DefaultMaxRetries)
+	}
+}
+
+func TestFanOutRetries(t *testing.T) {
+	var attempts atomic.Int32
+	sink := funcSink(func(ctx context.Context, event *Event) error {
+		if attempts.Add(1) < 3 {
+			return errors.New("unavailable")
+		}
+		return nil
+	})
+
+	fanOut := NewFanOut(nil, &Options{InitialBackoff: time.Millisecond}, sink)
+	entry := types.NewAuditLog(nil, types.ActionUserLoggedOut, types.ResourceTypeUser, "1")
+	if err := fanOut.CreateAuditLog(context.Background(), entry); err != nil {
+		t.Fatalf("CreateAuditLog: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := fanOut.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("attempts = %d, want 3", got)
+	}
+}
+
+func TestFanOutCloseCancelsInFlightSend(t *testing.T) {
+	started := make(chan struct{})
+	sink := funcSink(func(ctx context.Context, event *Event) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	fanOut := NewFanOut(nil, &Options{SendTimeout: time.Hour}, sink)
+	entry := types.NewAuditLog(nil, types.ActionUserLoggedOut, types.ResourceTypeUser, "1")
+	if err := fanOut.CreateAuditLog(context.Background(), entry); err != nil {
+		t.Fatalf("CreateAuditLog: %v", err)
+	}
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- fanOut.Close(ctx) }()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Close error = %v, want DeadlineExceeded", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not cancel the in-flight send")
+	}
+}
@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ClientIPResolver determines the client IP of requests that pass through
+// reverse proxies. Forwarding headers are only honoured when the peer is a
+// trusted proxy, and are read right to left, stopping at the first hop that
+// is not trusted, so clients can't spoof their address by sending the
+// headers themselves.
+type ClientIPResolver struct {
+	trusted []netip.Prefix
+}
+
+// NewClientIPResolver creates a resolver trusting the given proxies, as
+// CIDRs ("10.0.0.0/8") or single addresses ("127.0.0.1"). With no trusted
+// proxies the client IP is always the peer address.
+func NewClientIPResolver(trustedProxies []string) (*ClientIPResolver, error) {
+	r := &ClientIPResolver{}
+	for _, s := range trustedProxies {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", s, err)
+			}
+			addr = addr.Unmap()
+			r.trusted = append(r.trusted, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", s, err)
+		}
+		if prefix.Addr().Is4In6() {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		r.trusted = append(r.trusted, prefix.Masked())
+	}
+	return r, nil
+}
+
+// ClientIP resolves the client IP of a request. If the peer is a trusted
+// proxy, the RFC 7239 Forwarded header is used, or X-Forwarded-For if there
+// is none, falling back to X-Real-IP when neither is present.
+func (c *ClientIPResolver) ClientIP(r *http.Request) string {
+	addr, ok := parseHop(r.RemoteAddr)
+	if !ok {
+		return remoteHost(r.RemoteAddr)
+	}
+	if !c.isTrusted(addr) {
+		return addr.String()
+	}
+
+	hops := forwardedFor(r.Header)
+	if hops == nil {
+		hops = xForwardedFor(r.Header)
+	}
+	if hops == nil {
+		if real, ok := parseHop(r.Header.Get("X-Real-IP")); ok {
+			return real.String()
+		}
+		return addr.String()
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseHop(hops[i])
+		if !ok {
+			// The trusted proxy at addr reported an unknown or obfuscated
+			// client; addr is the last address we can vouch for.
+			return addr.String()
+		}
+		addr = hop
+		if !c.isTrusted(addr) {
+			break
+		}
+	}
+	return addr.String()
+}
+
+// Middleware stores the resolved client IP in the request context, where
+// GetClientIP and GetClientIPFromContext find it. Register it before any
+// middleware or handler that uses the client IP.
+func (c *ClientIPResolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), ContextKeyClientIP, c.ClientIP(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (c *ClientIPResolver) isTrusted(addr netip.Addr) bool {
+	for _, p := range c.trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetClientIP returns the client IP stored by ClientIPResolver.Middleware,
+// or the peer address if the middleware is not installed. Forwarding
+// headers are never trusted without a resolver.
+func GetClientIP(r *http.Request) string {
+	if ip := GetClientIPFromContext(r.Context()); ip != "" {
+		return ip
+	}
+	return remoteHost(r.RemoteAddr)
+}
+
+// GetClientIPFromContext returns the client IP stored by
+// ClientIPResolver.Middleware, or "".
+func GetClientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(ContextKeyClientIP).(string)
+	return ip
+}
+
+func remoteHost(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+	return remoteAddr
+}
+
+// parseHop parses an address with an optional port, as found in
+// RemoteAddr, X-Forwarded-For and Forwarded "for" parameters.
+func parseHop(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return netip.Addr{}, false
+	}
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr().Unmap().WithZone(""), true
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap().WithZone(""), true
+}
+
+// xForwardedFor returns the X-Forwarded-For hops, left to right, across
+// all header lines.
+func xForwardedFor(h http.Header) []string {
+	var hops []string
+	for _, line := range h.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(line, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	return hops
+}
+
+// forwardedFor returns the "for" parameters of the RFC 7239 Forwarded
+// header, left to right. Elements without one yield "", which parseHop
+// rejects.
+func forwardedFor(h http.Header) []string {
+	var hops []string
+	for _, line := range h.Values("Forwarded") {
+		for _, element := range splitQuoted(line, ',') {
+			if strings.TrimSpace(element) == "" {
+				continue
+			}
+			var hop string
+			for _, pair := range splitQuoted(element, ';') {
+				name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(name, "for") {
+					hop = unquote(value)
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+// splitQuoted splits s at sep outside of quoted strings.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	inQuotes, escaped, start := false, false, 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case inQuotes && s[i] == '\\':
+			escaped = true
+		case s[i] == '"':
+			inQuotes = !inQuotes
+		case !inQuotes && s[i] == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	s = s[1 : len(s)-1]
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
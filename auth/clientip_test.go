@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	resolver, err := NewClientIPResolver([]string{"10.0.0.0/8", "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("creating resolver: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{
+			name:       "forged X-Forwarded-For from untrusted peer",
+			remoteAddr: "203.0.113.7:4000",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "forged Forwarded from untrusted peer",
+			remoteAddr: "203.0.113.7:4000",
+			headers:    map[string]string{"Forwarded": "for=198.51.100.1"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "forged X-Real-IP from untrusted peer",
+			remoteAddr: "203.0.113.7:4000",
+			headers:    map[string]string{"X-Real-IP": "198.51.100.1"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "trusted proxy",
+			remoteAddr: "10.0.0.2:4000",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.7"},
+			want:       "203.0.113.7",
+		},
+		{
+			// The client prepended a forged hop; the trusted proxy appended
+			// the address it saw.
+			name:       "forged hop behind trusted proxy",
+			remoteAddr: "10.0.0.2:4000",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1, 203.0.113.7"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "chain of trusted proxies",
+			remoteAddr: "127.0.0.1:4000",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.7, 10.1.1.1, 10.0.0.2"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "Forwarded takes precedence",
+			remoteAddr: "10.0.0.2:4000",
+			headers: map[string]string{
+				"Forwarded":       `for="[2001:db8::1]:443";proto=https`,
+				"X-Forwarded-For": "198.51.100.1",
+			},
+			want: "2001:db8::1",
+		},
+		{
+			name:       "obfuscated client behind trusted proxy",
+			remoteAddr: "10.0.0.2:4000",
+			headers:    map[string]string{"Forwarded": "for=_hidden"},
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "X-Real-IP from trusted proxy",
+			remoteAddr: "10.0.0.2:4000",
+			headers:    map[string]string{"X-Real-IP": "203.0.113.7"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "IPv4-mapped peer",
+			remoteAddr: "[::ffff:10.0.0.2]:4000",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.7"},
+			want:       "203.0.113.7",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if got := resolver.ClientIP(req); got != tt.want {
+				t.Errorf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetClientIPIgnoresHeadersWithoutResolver(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.7:4000"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	req.Header.Set("X-Real-IP", "198.51.100.1")
+	if got := GetClientIP(req); got != "203.0.113.7" {
+		t.Errorf("GetClientIP = %q, want the peer address", got)
+	}
+}
+
+func TestClientIPResolverMiddleware(t *testing.T) {
+	resolver, err := NewClientIPResolver([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("creating resolver: %v", err)
+	}
+
+	var got string
+	handler := resolver.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = GetClientIP(r)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.2:4000"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if got != "203.0.113.7" {
+		t.Errorf("GetClientIP in handler = %q, want %q", got, "203.0.113.7")
+	}
+}
+
+func TestNewClientIPResolverInvalid(t *testing.T) {
+	for _, proxy := range []string{"not-an-ip", "10.0.0.0/33"} {
+		if _, err := NewClientIPResolver([]string{proxy}); err == nil {
+			t.Errorf("NewClientIPResolver(%q) succeeded, want error", proxy)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/sessions"
+	"github.com/juanfont/juango/types"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// CSRFHeader is the request header carrying the CSRF token.
+	CSRFHeader = "X-CSRF-Token"
+	// CSRFFormField is the form field carrying the CSRF token, for
+	// requests that can't set headers.
+	CSRFFormField = "csrf_token"
+
+	csrfSessionKey  = "csrf_token"
+	csrfTokenLength = 32
+)
+
+var (
+	// ErrCSRFOrigin is returned for cross-origin state-changing requests.
+	ErrCSRFOrigin = errors.New("cross-origin request rejected")
+	// ErrCSRFToken is returned when the CSRF token is missing or wrong.
+	ErrCSRFToken = errors.New("missing or invalid CSRF token")
+)
+
+// CSRFProtection protects state-changing requests with a synchronizer
+// token stored in the gorilla session, plus Sec-Fetch-Site and Origin
+// checks. Tokens handed out are masked with a fresh one-time pad on every
+// call, so they don't leak the secret through compressed responses.
+type CSRFProtection struct {
+	sessionStore   sessions.Store
+	cookieName     string
+	trustedOrigins map[string]bool
+	exemptPaths    map[string]bool
+	exemptPrefixes []string
+}
+
+// NewCSRFProtection creates CSRF protection using the session cookie
+// cookieName. Requests are accepted from the request's own origin and from
+// trustedOrigins (e.g. "https://app.example.com").
+func NewCSRFProtection(sessionStore sessions.Store, cookieName string, trustedOrigins ...string) *CSRFProtection {
+	c := &CSRFProtection{
+		sessionStore:   sessionStore,
+		cookieName:     cookieName,
+		trustedOrigins: make(map[string]bool),
+		exemptPaths:    make(map[string]bool),
+	}
+	for _, o := range trustedOrigins {
+		if u, err := url.Parse(o); err == nil && u.Host != "" {
+			c.trustedOrigins[u.Scheme+"://"+u.Host] = true
+		}
+	}
+	return c
+}
+
+// Exempt skips CSRF checks for endpoints that can't carry a token and are
+// protected by other means, e.g. the OIDC callback (state and nonce) or
+// signed webhooks. Paths ending in "/" exempt every path below them; other
+// paths must match exactly.
+func (c *CSRFProtection) Exempt(paths ...string) {
+	for _, p := range paths {
+		if strings.HasSuffix(p, "/") {
+			c.exemptPrefixes = append(c.exemptPrefixes, p)
+		} else {
+			c.exemptPaths[p] = true
+		}
+	}
+}
+
+// Middleware rejects POST, PUT, PATCH and DELETE requests that come from
+// another origin or don't carry the session's CSRF token in the
+// X-CSRF-Token header or csrf_token form field.
+func (c *CSRFProtection) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isSafeMethod(r.Method) || c.isExempt(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err := c.verify(r); err != nil {
+			zerolog.Ctx(r.Context()).Warn().
+				Err(err).
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Str("origin", r.Header.Get("Origin")).
+				Str("sec_fetch_site", r.Header.Get("Sec-Fetch-Site")).
+				Msg("CSRF check failed")
+			types.WriteError(w, r, types.NewHTTPError(http.StatusForbidden, "CSRF check failed", err))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// TokenHandler handles GET /api/auth/csrf, returning {"token": "..."} for
+// the SPA to send in the X-CSRF-Token header.
+func (c *CSRFProtection) TokenHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := c.Token(w, r)
+	if err != nil {
+		types.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(types.CSRFTokenResponse{Token: token})
+}
+
+// Token returns a masked CSRF token for the request's session, creating
+// and saving the session secret if needed. Use it to embed the token in
+// server-rendered forms.
+func (c *CSRFProtection) Token(w http.ResponseWriter, r *http.Request) (string, error) {
+	session, err := c.sessionStore.Get(r, c.cookieName)
+	if err != nil {
+		return "", err
+	}
+
+	secret, ok := sessionCSRFSecret(session)
+	if !ok {
+		secret = make([]byte, csrfTokenLength)
+		if _, err := rand.Read(secret); err != nil {
+			return "", err
+		}
+		session.Values[csrfSessionKey] = base64.RawURLEncoding.EncodeToString(secret)
+		if err := session.Save(r, w); err != nil {
+			return "", err
+		}
+	}
+
+	return maskCSRFToken(secret), nil
+}
+
+func (c *CSRFProtection) verify(r *http.Request) error {
+	if err := c.verifyOrigin(r); err != nil {
+		return err
+	}
+
+	session, err := c.sessionStore.Get(r, c.cookieName)
+	if err != nil {
+		return ErrCSRFToken
+	}
+	secret, ok := sessionCSRFSecret(session)
+	if !ok {
+		return ErrCSRFToken
+	}
+
+	sent := r.Header.Get(CSRFHeader)
+	if sent == "" && isFormRequest(r) {
+		sent = r.PostFormValue(CSRFFormField)
+	}
+	got, ok := unmaskCSRFToken(sent)
+	if !ok || subtle.ConstantTimeCompare(got, secret) != 1 {
+		return ErrCSRFToken
+	}
+	return nil
+}
+
+// verifyOrigin rejects cross-origin requests. Browsers send Sec-Fetch-Site
+// on every request, so it is checked first; older browsers only send
+// Origin. Requests with neither are not from a browser and are left to the
+// token check.
+func (c *CSRFProtection) verifyOrigin(r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if c.trustedOrigins[origin] {
+		return nil
+	}
+
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return nil
+	case "":
+	default:
+		return ErrCSRFOrigin
+	}
+
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host != r.Host {
+		return ErrCSRFOrigin
+	}
+	return nil
+}
+
+func (c *CSRFProtection) isExempt(path string) bool {
+	if c.exemptPaths[path] {
+		return true
+	}
+	for _, prefix := range c.exemptPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func isFormRequest(r *http.Request) bool {
+	ct := r.Header.Get("Content-Type")
+	return strings.HasPrefix(ct, "application/x-www-form-urlencoded") ||
+		strings.HasPrefix(ct, "multipart/form-data")
+}
+
+func sessionCSRFSecret(session *sessions.Session) ([]byte, bool) {
+	encoded, ok := session.Values[csrfSessionKey].(string)
+	if !ok {
+		return nil, false
+	}
+	secret, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(secret) != csrfTokenLength {
+		return nil, false
+	}
+	return secret, true
+}
+
+// maskCSRFToken returns base64(pad || pad XOR secret) for a random pad.
+func maskCSRFToken(secret []byte) string {
+	token := make([]byte, 2*csrfTokenLength)
+	pad, masked := token[:csrfTokenLength], token[csrfTokenLength:]
+	rand.Read(pad)
+	subtle.XORBytes(masked, pad, secret)
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+func unmaskCSRFToken(token string) ([]byte, bool) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(b) != 2*csrfTokenLength {
+		return nil, false
+	}
+	secret := make([]byte, csrfTokenLength)
+	subtle.XORBytes(secret, b[:csrfTokenLength], b[csrfTokenLength:])
+	return secret, true
+}
@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+// csrfSession fetches a CSRF token from TokenHandler and returns it with
+// the session cookie it was issued for.
+func csrfSession(t *testing.T, csrf *CSRFProtection) (string, *http.Cookie) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	csrf.TokenHandler(rec, httptest.NewRequest(http.MethodGet, "http://app.example.com/api/auth/csrf", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fetching CSRF token: status %d", rec.Code)
+	}
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding CSRF token: %v", err)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("fetching CSRF token set %d cookies, want 1", len(cookies))
+	}
+	return resp.Token, cookies[0]
+}
+
+func TestCSRFMiddleware(t *testing.T) {
+	store := sessions.NewCookieStore([]byte("0123456789abcdef0123456789abcdef"))
+	csrf := NewCSRFProtection(store, "session", "https://admin.example.com")
+	csrf.Exempt("/api/webhooks/", "/api/oidc/callback")
+	handler := csrf.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	token, cookie := csrfSession(t, csrf)
+	otherToken, _ := csrfSession(t, csrf)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		headers  map[string]string
+		form     url.Values
+		noCookie bool
+		want     int
+	}{
+		{
+			name:    "same-origin with token",
+			headers: map[string]string{"Sec-Fetch-Site": "same-origin", CSRFHeader: token},
+			want:    http.StatusNoContent,
+		},
+		{
+			name:    "same-origin Origin with token",
+			headers: map[string]string{"Origin": "http://app.example.com", CSRFHeader: token},
+			want:    http.StatusNoContent,
+		},
+		{
+			name: "token in form field",
+			headers: map[string]string{
+				"Sec-Fetch-Site": "same-origin",
+				"Content-Type":   "application/x-www-form-urlencoded",
+			},
+			form: url.Values{CSRFFormField: {token}},
+			want: http.StatusNoContent,
+		},
+		{
+			name:    "trusted origin with token",
+			headers: map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "https://admin.example.com", CSRFHeader: token},
+			want:    http.StatusNoContent,
+		},
+		{
+			name:    "cross-site without token",
+			headers: map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "https://evil.example"},
+			want:    http.StatusForbidden,
+		},
+		{
+			name:    "cross-site with token",
+			headers: map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "https://evil.example", CSRFHeader: token},
+			want:    http.StatusForbidden,
+		},
+		{
+			name:    "same-site subdomain with token",
+			headers: map[string]string{"Sec-Fetch-Site": "same-site", "Origin": "https://evil.app.example.com", CSRFHeader: token},
+			want:    http.StatusForbidden,
+		},
+		{
+			name:    "bad Origin without Sec-Fetch-Site",
+			headers: map[string]string{"Origin": "https://evil.example", CSRFHeader: token},
+			want:    http.StatusForbidden,
+		},
+		{
+			name:    "null Origin",
+			headers: map[string]string{"Origin": "null", CSRFHeader: token},
+			want:    http.StatusForbidden,
+		},
+		{
+			name:    "same-origin without token",
+			headers: map[string]string{"Sec-Fetch-Site": "same-origin"},
+			want:    http.StatusForbidden,
+		},
+		{
+			name:    "malformed token",
+			headers: map[string]string{"Sec-Fetch-Site": "same-origin", CSRFHeader: "not-a-token"},
+			want:    http.StatusForbidden,
+		},
+		{
+			name:    "token of another session",
+			headers: map[string]string{"Sec-Fetch-Site": "same-origin", CSRFHeader: otherToken},
+			want:    http.StatusForbidden,
+		},
+		{
+			name:     "token without session",
+			headers:  map[string]string{"Sec-Fetch-Site": "same-origin", CSRFHeader: token},
+			noCookie: true,
+			want:     http.StatusForbidden,
+		},
+		{
+			name:    "DELETE without token",
+			method:  http.MethodDelete,
+			headers: map[string]string{"Sec-Fetch-Site": "same-origin"},
+			want:    http.StatusForbidden,
+		},
+		{
+			name:    "GET without token",
+			method:  http.MethodGet,
+			headers: map[string]string{"Sec-Fetch-Site": "cross-site"},
+			want:    http.StatusNoContent,
+		},
+		{
+			name:    "exempt path",
+			path:    "/api/webhooks/github",
+			headers: map[string]string{"Sec-Fetch-Site": "cross-site"},
+			want:    http.StatusNoContent,
+		},
+		{
+			name:     "exempt exact path",
+			path:     "/api/oidc/callback",
+			headers:  map[string]string{"Sec-Fetch-Site": "cross-site"},
+			noCookie: true,
+			want:     http.StatusNoContent,
+		},
+		{
+			name:    "path below exact exemption",
+			path:    "/api/oidc/callback/extra",
+			headers: map[string]string{"Sec-Fetch-Site": "cross-site"},
+			want:    http.StatusForbidden,
+		},
+		{
+			name:    "path extending exact exemption",
+			path:    "/api/oidc/callbacks",
+			headers: map[string]string{"Sec-Fetch-Site": "cross-site"},
+			want:    http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method, path := tt.method, tt.path
+			if method == "" {
+				method = http.MethodPost
+			}
+			if path == "" {
+				path = "/api/items"
+			}
+			var body *strings.Reader
+			if tt.form != nil {
+				body = strings.NewReader(tt.form.Encode())
+			} else {
+				body = strings.NewReader("{}")
+			}
+			req := httptest.NewRequest(method, "http://app.example.com"+path, body)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if !tt.noCookie {
+				req.AddCookie(cookie)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status %d, want %d (body %s)", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestCSRFTokensAreMasked(t *testing.T) {
+	store := sessions.NewCookieStore([]byte("0123456789abcdef0123456789abcdef"))
+	csrf := NewCSRFProtection(store, "session")
+	_, cookie := csrfSession(t, csrf)
+
+	req := httptest.NewRequest(http.MethodGet, "http://app.example.com/api/auth/csrf", nil)
+	req.AddCookie(cookie)
+	first, err := csrf.Token(httptest.NewRecorder(), req)
+	if err != nil {
+		t.Fatalf("getting token: %v", err)
+	}
+	second, err := csrf.Token(httptest.NewRecorder(), req)
+	if err != nil {
+		t.Fatalf("getting token: %v", err)
+	}
+	if first == second {
+		t.Errorf("Token returned the same masked token twice")
+	}
+
+	a, _ := unmaskCSRFToken(first)
+	b, _ := unmaskCSRFToken(second)
+	if string(a) != string(b) {
+		t.Errorf("masked tokens unmask to different secrets")
+	}
+}
@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Login failure reasons reported to Metrics.LoginFailed.
+const (
+	LoginFailureSession  = "session"
+	LoginFailureState    = "invalid_state"
+	LoginFailureNonce    = "missing_nonce"
+	LoginFailureExchange = "exchange"
+	LoginFailureVerify   = "verify"
+	LoginFailureUser     = "user_store"
+	LoginFailureInactive = "deactivated"
+)
+
+// Metrics receives authentication and privilege events for monitoring.
+// The juango/metrics package provides a Prometheus implementation.
+type Metrics interface {
+	LoginSucceeded()
+	LoginFailed(reason string)
+
+	// AdminModeStarted and ImpersonationStarted are keyed by the admin's
+	// user ID; expiresAt bounds how long the state counts as active if the
+	// matching Ended call never happens (e.g. the session is abandoned).
+	AdminModeStarted(adminID uuid.UUID, expiresAt time.Time)
+	AdminModeEnded(adminID uuid.UUID)
+	ImpersonationStarted(adminID uuid.UUID, expiresAt time.Time)
+	ImpersonationEnded(adminID uuid.UUID)
+}
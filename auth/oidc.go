@@ -11,18 +11,26 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/google/uuid"
 	"github.com/gorilla/sessions"
 	"github.com/juanfont/juango/types"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/oauth2"
 )
 
 const (
 	// OIDCCallbackPath is the default callback path for OIDC.
 	OIDCCallbackPath = "/api/oidc/callback"
+
+	// tracerName is the instrumentation scope of OIDC spans.
+	tracerName = "github.com/juanfont/juango/auth"
 )
 
 // OIDCProvider handles OIDC authentication.
@@ -34,6 +42,9 @@ type OIDCProvider struct {
 	verifier     *oidc.IDTokenVerifier
 	provider     *oidc.Provider
 	oauth2Config *oauth2.Config
+
+	discoveryMu  sync.Mutex
+	discoveredAt time.Time
 }
 
 // OIDCProviderConfig holds configuration for creating an OIDC provider.
@@ -79,9 +90,37 @@ func NewOIDCProvider(ctx context.Context, cfg OIDCProviderConfig) (*OIDCProvider
 		provider:     provider,
 		oauth2Config: oauth2Config,
 		verifier:     verifier,
+		discoveredAt: time.Now(),
 	}, nil
 }
 
+// RefreshDiscovery fetches the issuer's discovery document again to check
+// that the provider is reachable and still serves a valid configuration.
+// The endpoints in use are not changed.
+func (p *OIDCProvider) RefreshDiscovery(ctx context.Context) error {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "oidc.discovery", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	if _, err := oidc.NewProvider(ctx, p.config.Issuer); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("fetching OIDC discovery document: %w", err)
+	}
+
+	p.discoveryMu.Lock()
+	p.discoveredAt = time.Now()
+	p.discoveryMu.Unlock()
+	return nil
+}
+
+// DiscoveredAt returns when the discovery document was last fetched
+// successfully.
+func (p *OIDCProvider) DiscoveredAt() time.Time {
+	p.discoveryMu.Lock()
+	defer p.discoveryMu.Unlock()
+	return p.discoveredAt
+}
+
 // CallbackPath returns the OIDC callback path.
 func (p *OIDCProvider) CallbackPath() string {
 	return p.callbackPath
@@ -94,7 +133,15 @@ func (p *OIDCProvider) AuthCodeURL(state, nonce string) string {
 
 // Exchange exchanges an authorization code for tokens.
 func (p *OIDCProvider) Exchange(ctx context.Context, code string) (*oauth2.Token, error) {
-	return p.oauth2Config.Exchange(ctx, code)
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "oidc.exchange", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	token, err := p.oauth2Config.Exchange(ctx, code)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return token, err
 }
 
 // VerifyIDToken verifies an ID token and returns it.
@@ -104,7 +151,15 @@ func (p *OIDCProvider) VerifyIDToken(ctx context.Context, rawIDToken string) (*o
 
 // UserInfo fetches user info from the OIDC provider.
 func (p *OIDCProvider) UserInfo(ctx context.Context, token *oauth2.Token) (*oidc.UserInfo, error) {
-	return p.provider.UserInfo(ctx, oauth2.StaticTokenSource(token))
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "oidc.userinfo", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	userinfo, err := p.provider.UserInfo(ctx, oauth2.StaticTokenSource(token))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return userinfo, err
 }
 
 // ProcessCallback handles the OIDC callback and returns claims.
@@ -131,7 +186,7 @@ func (p *OIDCProvider) ProcessCallback(ctx context.Context, code, expectedNonce
 	}
 
 	// Fetch userinfo to supplement claims
-	userinfo, err := p.provider.UserInfo(ctx, oauth2.StaticTokenSource(token))
+	userinfo, err := p.UserInfo(ctx, token)
 	if err != nil {
 		log.Warn().Err(err).Msg("could not get userinfo; only checking claim")
 	}
@@ -220,11 +275,25 @@ func fetchMicrosoftGraphPhoto(ctx context.Context, accessToken, photoURL string)
 	return fmt.Sprintf("data:%s;base64,%s", contentType, base64Data)
 }
 
-// UserStore is the interface for user database operations.
+// UserStore is the interface for user database operations. Lookups return
+// an error wrapping types.ErrNotFound for unknown users, and return
+// deactivated users too (see types.User.IsActive). database.Store
+// provides a SQLite implementation.
 type UserStore interface {
-	CreateOrUpdateUserFromClaim(claims *types.OIDCClaims) (*types.User, error)
+	// CreateOrUpdateUserFromClaim creates the user identified by the claims'
+	// provider identifier, or updates its profile. Deactivated users are
+	// returned as is; the caller decides whether they may log in.
+	CreateOrUpdateUserFromClaim(ctx context.Context, claims *types.OIDCClaims) (*types.User, error)
 	UpdateLastLogin(ctx context.Context, userID uuid.UUID) error
 	GetUserByID(ctx context.Context, userID uuid.UUID) (*types.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*types.User, error)
+	GetUserByProviderIdentifier(ctx context.Context, identifier string) (*types.User, error)
+	ListUsers(ctx context.Context, filter types.UserFilter) ([]types.User, error)
+
+	// Deactivate soft-deletes the user, who can no longer log in or use
+	// existing sessions. Reactivate undoes it.
+	Deactivate(ctx context.Context, userID uuid.UUID) error
+	Reactivate(ctx context.Context, userID uuid.UUID) error
 }
 
 // AuditLogger is the interface for audit logging.
@@ -239,6 +308,7 @@ type OIDCHandlers struct {
 	cookieName   string
 	userStore    UserStore
 	auditLogger  AuditLogger
+	metrics      Metrics
 }
 
 // NewOIDCHandlers creates new OIDC handlers.
@@ -252,23 +322,34 @@ func NewOIDCHandlers(provider *OIDCProvider, sessionStore sessions.Store, cookie
 	}
 }
 
+// SetMetrics sets where login and privilege events are reported.
+func (h *OIDCHandlers) SetMetrics(m Metrics) {
+	h.metrics = m
+}
+
+func (h *OIDCHandlers) loginFailed(reason string) {
+	if h.metrics != nil {
+		h.metrics.LoginFailed(reason)
+	}
+}
+
 // LoginHandler redirects to the OIDC provider for authentication.
 func (h *OIDCHandlers) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	session, err := h.sessionStore.Get(r, h.cookieName)
 	if err != nil {
-		types.WriteHTTPError(w, err)
+		types.WriteError(w, r, err)
 		return
 	}
 
 	state, err := GenerateRandomState()
 	if err != nil {
-		types.WriteHTTPError(w, err)
+		types.WriteError(w, r, err)
 		return
 	}
 
 	nonce, err := GenerateRandomState()
 	if err != nil {
-		types.WriteHTTPError(w, err)
+		types.WriteError(w, r, err)
 		return
 	}
 
@@ -276,7 +357,7 @@ func (h *OIDCHandlers) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	session.Values["nonce"] = nonce
 
 	if err := session.Save(r, w); err != nil {
-		types.WriteHTTPError(w, err)
+		types.WriteError(w, r, err)
 		return
 	}
 
@@ -291,19 +372,22 @@ func (h *OIDCHandlers) CallbackHandler(w http.ResponseWriter, r *http.Request) {
 
 	session, err := h.sessionStore.Get(r, h.cookieName)
 	if err != nil {
-		types.WriteHTTPError(w, err)
+		h.loginFailed(LoginFailureSession)
+		types.WriteError(w, r, err)
 		return
 	}
 
 	expectedState, ok := session.Values["state"].(string)
 	if !ok || r.URL.Query().Get("state") != expectedState {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusBadRequest, "Invalid state parameter", nil))
+		h.loginFailed(LoginFailureState)
+		types.WriteError(w, r, types.NewHTTPError(http.StatusBadRequest, "Invalid state parameter", nil))
 		return
 	}
 
 	expectedNonce, ok := session.Values["nonce"].(string)
 	if !ok {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusBadRequest, "Nonce not found", nil))
+		h.loginFailed(LoginFailureNonce)
+		types.WriteError(w, r, types.NewHTTPError(http.StatusBadRequest, "Nonce not found", nil))
 		return
 	}
 
@@ -311,47 +395,64 @@ func (h *OIDCHandlers) CallbackHandler(w http.ResponseWriter, r *http.Request) {
 	delete(session.Values, "state")
 	delete(session.Values, "nonce")
 	if err := session.Save(r, w); err != nil {
-		types.WriteHTTPError(w, err)
+		h.loginFailed(LoginFailureSession)
+		types.WriteError(w, r, err)
 		return
 	}
 
 	// Exchange code for token
 	token, err := h.provider.Exchange(ctx, r.URL.Query().Get("code"))
 	if err != nil {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusInternalServerError, "Unable to exchange authorization code", err))
+		h.loginFailed(LoginFailureExchange)
+		types.WriteError(w, r, types.NewHTTPError(http.StatusInternalServerError, "Unable to exchange authorization code", err))
 		return
 	}
 
 	// Process callback and get claims
 	claims, err := h.provider.ProcessCallback(ctx, r.URL.Query().Get("code"), expectedNonce, token)
 	if err != nil {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusInternalServerError, "Failed to process OIDC callback", err))
+		h.loginFailed(LoginFailureVerify)
+		types.WriteError(w, r, types.NewHTTPError(http.StatusInternalServerError, "Failed to process OIDC callback", err))
 		return
 	}
 
 	// Create or update user
-	user, err := h.userStore.CreateOrUpdateUserFromClaim(claims)
+	user, err := h.userStore.CreateOrUpdateUserFromClaim(ctx, claims)
 	if err != nil {
-		types.WriteHTTPError(w, err)
+		h.loginFailed(LoginFailureUser)
+		types.WriteError(w, r, err)
+		return
+	}
+	if !user.IsActive() {
+		h.loginFailed(LoginFailureInactive)
+		types.WriteError(w, r, types.NewHTTPError(http.StatusForbidden, "Account is deactivated", nil))
 		return
 	}
 
 	if err := h.userStore.UpdateLastLogin(ctx, user.ID); err != nil {
-		types.WriteHTTPError(w, err)
+		h.loginFailed(LoginFailureUser)
+		types.WriteError(w, r, err)
 		return
 	}
 
 	// Create audit log
 	if h.auditLogger != nil {
-		auditLog := types.NewAuditLog(
+		auditLog, err := types.NewAuditLog(
 			&types.NullUUID{UUID: user.ID, Valid: true},
 			types.ActionUserLoggedIn,
 			types.ResourceTypeUser,
 			user.ID.String(),
-		).WithChanges(map[string]interface{}{
-			"email":        user.Email,
-			"display_name": user.DisplayName,
-		}).WithIPAddress(GetClientIP(r)).WithUserAgent(r.UserAgent())
+		).WithPayload(types.UserLoggedInChanges{
+			Email:       user.Email,
+			DisplayName: user.DisplayName,
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to encode audit payload for login")
+		}
+		auditLog.WithIPAddress(GetClientIP(r)).
+			WithUserAgent(r.UserAgent()).
+			WithSessionID(session.ID).
+			WithRequestContext(ctx)
 
 		if err := h.auditLogger.CreateAuditLog(ctx, auditLog); err != nil {
 			log.Error().Err(err).Msg("Failed to create audit log for login")
@@ -361,7 +462,8 @@ func (h *OIDCHandlers) CallbackHandler(w http.ResponseWriter, r *http.Request) {
 	// Save session
 	session, err = h.sessionStore.Get(r, h.cookieName)
 	if err != nil {
-		types.WriteHTTPError(w, err)
+		h.loginFailed(LoginFailureSession)
+		types.WriteError(w, r, err)
 		return
 	}
 
@@ -369,10 +471,15 @@ func (h *OIDCHandlers) CallbackHandler(w http.ResponseWriter, r *http.Request) {
 	session.Values["user_id"] = user.ID.String()
 
 	if err := session.Save(r, w); err != nil {
-		types.WriteHTTPError(w, err)
+		h.loginFailed(LoginFailureSession)
+		types.WriteError(w, r, err)
 		return
 	}
 
+	if h.metrics != nil {
+		h.metrics.LoginSucceeded()
+	}
+
 	http.Redirect(w, r, "/", http.StatusFound)
 }
 
@@ -382,7 +489,7 @@ func (h *OIDCHandlers) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 
 	session, err := h.sessionStore.Get(r, h.cookieName)
 	if err != nil {
-		types.WriteHTTPError(w, err)
+		types.WriteError(w, r, err)
 		return
 	}
 
@@ -399,13 +506,25 @@ func (h *OIDCHandlers) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 			types.ActionUserLoggedOut,
 			types.ResourceTypeUser,
 			userID.String(),
-		).WithIPAddress(GetClientIP(r)).WithUserAgent(r.UserAgent())
+		).WithIPAddress(GetClientIP(r)).
+			WithUserAgent(r.UserAgent()).
+			WithSessionID(session.ID).
+			WithRequestContext(ctx)
 
 		if err := h.auditLogger.CreateAuditLog(ctx, auditLog); err != nil {
 			log.Error().Err(err).Msg("Failed to create audit log for logout")
 		}
 	}
 
+	if h.metrics != nil {
+		if impState, ok := session.Values["impersonation_state"].(types.ImpersonationState); ok && impState.Enabled {
+			h.metrics.ImpersonationEnded(impState.OriginalAdminID)
+			h.metrics.AdminModeEnded(impState.OriginalAdminID)
+		} else if _, ok := session.Values["admin_mode"].(types.AdminModeState); ok {
+			h.metrics.AdminModeEnded(userID)
+		}
+	}
+
 	// Clear session
 	delete(session.Values, "logged")
 	delete(session.Values, "user_id")
@@ -414,7 +533,7 @@ func (h *OIDCHandlers) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	delete(session.Values, "original_user_id")
 
 	if err := session.Save(r, w); err != nil {
-		types.WriteHTTPError(w, err)
+		types.WriteError(w, r, err)
 		return
 	}
 
@@ -425,73 +544,66 @@ func (h *OIDCHandlers) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// SessionCheckHandler checks the current session status.
+// SessionCheckHandler checks the current session status. It responds with
+// 401 when the request isn't authenticated.
 func (h *OIDCHandlers) SessionCheckHandler(w http.ResponseWriter, r *http.Request) {
-	session, err := h.sessionStore.Get(r, h.cookieName)
+	response, err := h.SessionState(w, r)
 	if err != nil {
-		types.WriteHTTPError(w, types.NewHTTPError(http.StatusInternalServerError, "Failed to get session", err))
+		types.WriteError(w, r, types.NewHTTPError(http.StatusInternalServerError, "Failed to get session", err))
 		return
 	}
 
+	status := http.StatusOK
+	if !response.Authenticated {
+		status = http.StatusUnauthorized
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// SessionState returns the session status reported by SessionCheckHandler,
+// e.g. to embed it in the SPA's index.html. A corrupted session or one
+// whose user no longer exists is logged out, which writes its cookie to w.
+func (h *OIDCHandlers) SessionState(w http.ResponseWriter, r *http.Request) (*types.SessionResponse, error) {
+	session, err := h.sessionStore.Get(r, h.cookieName)
+	if err != nil {
+		return nil, err
+	}
+
 	logged, ok := session.Values["logged"].(bool)
 	if !ok || !logged {
 		reason := "not_authenticated"
 		if session.IsNew {
 			reason = "session_expired"
 		}
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(&types.SessionResponse{
-			Authenticated: false,
-			Reason:        reason,
-		})
-		return
+		return &types.SessionResponse{Authenticated: false, Reason: reason}, nil
 	}
 
-	userIDStr, ok := session.Values["user_id"].(string)
-	if !ok {
+	invalidate := func(reason string) (*types.SessionResponse, error) {
 		delete(session.Values, "logged")
 		delete(session.Values, "user_id")
 		session.Save(r, w)
+		return &types.SessionResponse{Authenticated: false, Reason: reason}, nil
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(&types.SessionResponse{
-			Authenticated: false,
-			Reason:        "session_corrupted",
-		})
-		return
+	userIDStr, ok := session.Values["user_id"].(string)
+	if !ok {
+		return invalidate("session_corrupted")
 	}
 
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		delete(session.Values, "logged")
-		delete(session.Values, "user_id")
-		session.Save(r, w)
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(&types.SessionResponse{
-			Authenticated: false,
-			Reason:        "session_corrupted",
-		})
-		return
+		return invalidate("session_corrupted")
 	}
 
 	user, err := h.userStore.GetUserByID(r.Context(), userID)
 	if err != nil {
-		delete(session.Values, "logged")
-		delete(session.Values, "user_id")
-		session.Save(r, w)
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(&types.SessionResponse{
-			Authenticated: false,
-			Reason:        "user_not_found",
-		})
-		return
+		return invalidate("user_not_found")
+	}
+	if !user.IsActive() {
+		return invalidate("user_deactivated")
 	}
 
 	response := &types.SessionResponse{
@@ -504,7 +616,5 @@ func (h *OIDCHandlers) SessionCheckHandler(w http.ResponseWriter, r *http.Reques
 		response.Impersonation = &impState
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	return response, nil
 }
@@ -2,9 +2,7 @@ package auth
 
 import (
 	"context"
-	"net"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -23,6 +21,18 @@ const (
 	ContextKeyImpersonationState ContextKey = "impersonation_state"
 	// ContextKeyOriginalAdminID is the context key for the original admin ID.
 	ContextKeyOriginalAdminID ContextKey = "original_admin_id"
+	// ContextKeySessionID is the context key for the session ID.
+	ContextKeySessionID ContextKey = "session_id"
+	// ContextKeyClientIP is the context key for the resolved client IP.
+	ContextKeyClientIP ContextKey = "client_ip"
+)
+
+// Auth states reported by SessionMiddleware.AuthState.
+const (
+	AuthStateAnonymous     = "anonymous"
+	AuthStateUser          = "user"
+	AuthStateAdminMode     = "admin_mode"
+	AuthStateImpersonating = "impersonating"
 )
 
 // SessionMiddleware provides session-based authentication middleware.
@@ -32,6 +42,7 @@ type SessionMiddleware struct {
 	userStore        UserStore
 	auditLogger      AuditLogger
 	adminModeTimeout time.Duration
+	metrics          Metrics
 }
 
 // NewSessionMiddleware creates a new session middleware.
@@ -51,6 +62,11 @@ func NewSessionMiddleware(
 	}
 }
 
+// SetMetrics sets where admin mode and impersonation expirations are reported.
+func (m *SessionMiddleware) SetMetrics(metrics Metrics) {
+	m.metrics = metrics
+}
+
 // Authenticate validates the session and returns the user, or an error.
 func (m *SessionMiddleware) Authenticate(r *http.Request) (*types.User, error) {
 	session, err := m.sessionStore.Get(r, m.cookieName)
@@ -80,21 +96,31 @@ func (m *SessionMiddleware) Authenticate(r *http.Request) (*types.User, error) {
 				delete(session.Values, "original_user_id")
 				session.Save(r, nil)
 
+				if m.metrics != nil {
+					m.metrics.ImpersonationEnded(impState.OriginalAdminID)
+				}
+
 				// Log expiration
 				if m.auditLogger != nil {
 					ctx := r.Context()
-					auditLog := types.NewAuditLog(
+					auditLog, err := types.NewAuditLog(
 						&types.NullUUID{UUID: impState.OriginalAdminID, Valid: true},
 						types.ActionImpersonationExpired,
 						types.ResourceTypeUser,
 						impState.TargetUserID.String(),
-					).WithChanges(map[string]interface{}{
-						"target_user_id":    impState.TargetUserID.String(),
-						"target_user_email": impState.TargetUserEmail,
-						"reason":            impState.Reason,
-						"duration":          impState.Duration().String(),
+					).WithPayload(types.ImpersonationEndedChanges{
+						AdminID:         impState.OriginalAdminID.String(),
+						TargetUserID:    impState.TargetUserID.String(),
+						TargetUserEmail: impState.TargetUserEmail,
+						Reason:          impState.Reason,
+						Duration:        impState.Duration().String(),
 					})
-					m.auditLogger.CreateAuditLog(ctx, auditLog)
+					if err != nil {
+						log.Error().Err(err).Msg("Failed to encode audit payload for impersonation expiry")
+					}
+					if err := m.auditLogger.CreateAuditLog(ctx, auditLog); err != nil {
+						log.Error().Err(err).Msg("Failed to create audit log for impersonation expiry")
+					}
 				}
 			}
 
@@ -116,16 +142,42 @@ func (m *SessionMiddleware) Authenticate(r *http.Request) (*types.User, error) {
 	if err != nil {
 		return nil, types.NewHTTPError(http.StatusUnauthorized, "User not found", err)
 	}
+	if !user.IsActive() {
+		return nil, types.NewHTTPError(http.StatusUnauthorized, "User is deactivated", nil)
+	}
 
 	return user, nil
 }
 
+// AuthState classifies the request's session as anonymous, user,
+// admin_mode or impersonating, without loading the user or modifying the
+// session. It is meant for low-cardinality metric labels, see
+// middleware.MetricsConfig.
+func (m *SessionMiddleware) AuthState(r *http.Request) string {
+	session, err := m.sessionStore.Get(r, m.cookieName)
+	if err != nil || session == nil {
+		return AuthStateAnonymous
+	}
+	if logged, _ := session.Values["logged"].(bool); !logged {
+		return AuthStateAnonymous
+	}
+	if impState, ok := session.Values["impersonation_state"].(types.ImpersonationState); ok &&
+		impState.Enabled && !impState.IsExpired(m.adminModeTimeout) {
+		return AuthStateImpersonating
+	}
+	if adminState, ok := session.Values["admin_mode"].(types.AdminModeState); ok &&
+		adminState.Enabled && !adminState.IsExpired(m.adminModeTimeout) {
+		return AuthStateAdminMode
+	}
+	return AuthStateUser
+}
+
 // RequireAuth returns middleware that requires authentication.
 func (m *SessionMiddleware) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		user, err := m.Authenticate(r)
 		if err != nil {
-			types.WriteHTTPError(w, err)
+			types.WriteError(w, r, err)
 			return
 		}
 
@@ -134,6 +186,9 @@ func (m *SessionMiddleware) RequireAuth(next http.HandlerFunc) http.HandlerFunc
 		// Add impersonation state to context if active
 		session, _ := m.sessionStore.Get(r, m.cookieName)
 		if session != nil {
+			if session.ID != "" {
+				ctx = context.WithValue(ctx, ContextKeySessionID, session.ID)
+			}
 			if impState, ok := session.Values["impersonation_state"].(types.ImpersonationState); ok && impState.Enabled {
 				if !impState.IsExpired(m.adminModeTimeout) {
 					ctx = context.WithValue(ctx, ContextKeyImpersonationState, impState)
@@ -160,6 +215,9 @@ func (m *SessionMiddleware) RequireAuthHandler(next http.Handler) http.Handler {
 
 		session, _ := m.sessionStore.Get(r, m.cookieName)
 		if session != nil {
+			if session.ID != "" {
+				ctx = context.WithValue(ctx, ContextKeySessionID, session.ID)
+			}
 			if impState, ok := session.Values["impersonation_state"].(types.ImpersonationState); ok && impState.Enabled {
 				if !impState.IsExpired(m.adminModeTimeout) {
 					ctx = context.WithValue(ctx, ContextKeyImpersonationState, impState)
@@ -182,7 +240,7 @@ func (m *SessionMiddleware) RequireAdmin(next http.HandlerFunc) http.HandlerFunc
 				Str("email", user.Email).
 				Str("path", r.URL.Path).
 				Msg("User is not an admin")
-			types.WriteHTTPError(w, types.NewHTTPError(http.StatusForbidden, "Admin privileges required", nil))
+			types.WriteError(w, r, types.NewHTTPError(http.StatusForbidden, "Admin privileges required", nil))
 			return
 		}
 		next.ServeHTTP(w, r)
@@ -199,13 +257,13 @@ func (m *SessionMiddleware) RequireAdminMode(next http.HandlerFunc) http.Handler
 				Str("email", user.Email).
 				Str("path", r.URL.Path).
 				Msg("User is not an admin")
-			types.WriteHTTPError(w, types.NewHTTPError(http.StatusForbidden, "Admin privileges required", nil))
+			types.WriteError(w, r, types.NewHTTPError(http.StatusForbidden, "Admin privileges required", nil))
 			return
 		}
 
 		session, err := m.sessionStore.Get(r, m.cookieName)
 		if err != nil {
-			types.WriteHTTPError(w, types.NewHTTPError(http.StatusInternalServerError, "Failed to get session", err))
+			types.WriteError(w, r, types.NewHTTPError(http.StatusInternalServerError, "Failed to get session", err))
 			return
 		}
 
@@ -216,7 +274,7 @@ func (m *SessionMiddleware) RequireAdminMode(next http.HandlerFunc) http.Handler
 				Str("email", user.Email).
 				Str("path", r.URL.Path).
 				Msg("Admin mode must be enabled to perform this action")
-			types.WriteHTTPError(w, types.NewHTTPError(http.StatusForbidden, "Admin mode must be enabled to perform this action", nil))
+			types.WriteError(w, r, types.NewHTTPError(http.StatusForbidden, "Admin mode must be enabled to perform this action", nil))
 			return
 		}
 
@@ -224,7 +282,10 @@ func (m *SessionMiddleware) RequireAdminMode(next http.HandlerFunc) http.Handler
 		if adminState.IsExpired(m.adminModeTimeout) {
 			delete(session.Values, "admin_mode")
 			session.Save(r, w)
-			types.WriteHTTPError(w, types.NewHTTPError(http.StatusForbidden, "Admin mode session expired. Please re-enable admin mode.", nil))
+			if m.metrics != nil {
+				m.metrics.AdminModeEnded(user.ID)
+			}
+			types.WriteError(w, r, types.NewHTTPError(http.StatusForbidden, "Admin mode session expired. Please re-enable admin mode.", nil))
 			return
 		}
 
@@ -241,6 +302,13 @@ func GetUserFromContext(ctx context.Context) *types.User {
 	return user
 }
 
+// GetSessionIDFromContext retrieves the session ID from the request context.
+// It is empty for session stores that don't assign IDs, such as cookie stores.
+func GetSessionIDFromContext(ctx context.Context) string {
+	sessionID, _ := ctx.Value(ContextKeySessionID).(string)
+	return sessionID
+}
+
 // GetActorIDForAudit returns the correct user ID for audit logging.
 // If impersonation is active, it returns the admin's ID.
 func GetActorIDForAudit(ctx context.Context) uuid.UUID {
@@ -264,6 +332,8 @@ func GetImpersonationContext(ctx context.Context) (uuid.UUID, string, bool) {
 }
 
 // NewAuditLogWithContext creates an audit log with automatic impersonation handling.
+// The actor is the real (admin) user; the impersonated user, session ID and
+// request ID are filled in from ctx when present.
 func NewAuditLogWithContext(
 	ctx context.Context,
 	action string,
@@ -275,44 +345,17 @@ func NewAuditLogWithContext(
 	if actorID != uuid.Nil {
 		actorIDPtr = &types.NullUUID{UUID: actorID, Valid: true}
 	}
-	auditLog := types.NewAuditLog(actorIDPtr, action, resourceType, resourceID)
-
-	// Add impersonation metadata if active
-	if impersonatedUserID, impersonatedUserEmail, isImpersonating := GetImpersonationContext(ctx); isImpersonating {
-		currentUser := ctx.Value(ContextKeyUser).(*types.User)
-
-		existingChanges := make(map[string]interface{})
-		existingChanges["_impersonation"] = map[string]interface{}{
-			"impersonated_user_id":    impersonatedUserID.String(),
-			"impersonated_user_email": impersonatedUserEmail,
-			"impersonated_user_name":  currentUser.DisplayName,
-			"performed_by_admin_id":   actorID.String(),
-		}
-		auditLog = auditLog.WithChanges(existingChanges)
-	}
-
-	return auditLog
-}
+	auditLog := types.NewAuditLog(actorIDPtr, action, resourceType, resourceID).
+		WithSessionID(GetSessionIDFromContext(ctx)).
+		WithRequestContext(ctx)
 
-// GetClientIP extracts the client IP address from the request.
-func GetClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header first (for proxied requests)
-	forwarded := r.Header.Get("X-Forwarded-For")
-	if forwarded != "" {
-		parts := strings.Split(forwarded, ",")
-		return strings.TrimSpace(parts[0])
+	if ip := GetClientIPFromContext(ctx); ip != "" {
+		auditLog = auditLog.WithIPAddress(ip)
 	}
 
-	// Check X-Real-IP header
-	realIP := r.Header.Get("X-Real-IP")
-	if realIP != "" {
-		return realIP
+	if impersonatedUserID, _, isImpersonating := GetImpersonationContext(ctx); isImpersonating {
+		auditLog = auditLog.WithImpersonatedUserID(impersonatedUserID)
 	}
 
-	// Fall back to RemoteAddr
-	ip := r.RemoteAddr
-	if host, _, err := net.SplitHostPort(ip); err == nil {
-		return host
-	}
-	return ip
+	return auditLog
 }
@@ -10,6 +10,7 @@ import (
 	"sync"
 	"syscall"
 
+	"github.com/juanfont/juango/frontend"
 	"github.com/spf13/cobra"
 )
 
@@ -20,7 +21,7 @@ var devCmd = &cobra.Command{
 
 The command will:
   1. Start Vite dev server (npm run dev) in frontend/
-  2. Start Go server (go run) with the main package
+  2. Start Go server (go run) with the main package and JUANGO_MODE=dev
   3. Handle Ctrl+C for graceful shutdown of both`,
 	RunE: runDev,
 }
@@ -106,6 +107,19 @@ func getProjectName() (string, error) {
 	return filepath.Base(cwd), nil
 }
 
+// findMainFile returns the path of the project's main package file.
+func findMainFile(projectName string) (string, error) {
+	mainFile := fmt.Sprintf("cmd/%s/%s.go", projectName, projectName)
+	if _, err := os.Stat(mainFile); os.IsNotExist(err) {
+		// Try alternative location
+		mainFile = "main.go"
+		if _, err := os.Stat(mainFile); os.IsNotExist(err) {
+			return "", fmt.Errorf("cannot find main.go (tried cmd/%s/%s.go and main.go)", projectName, projectName)
+		}
+	}
+	return mainFile, nil
+}
+
 func startVite(ctx context.Context) error {
 	fmt.Println("Starting Vite dev server...")
 
@@ -139,17 +153,13 @@ func startVite(ctx context.Context) error {
 func startGo(ctx context.Context, projectName string) error {
 	fmt.Println("Starting Go server...")
 
-	// Find the main.go file
-	mainFile := fmt.Sprintf("cmd/%s/%s.go", projectName, projectName)
-	if _, err := os.Stat(mainFile); os.IsNotExist(err) {
-		// Try alternative location
-		mainFile = "main.go"
-		if _, err := os.Stat(mainFile); os.IsNotExist(err) {
-			return fmt.Errorf("cannot find main.go (tried cmd/%s/%s.go and main.go)", projectName, projectName)
-		}
+	mainFile, err := findMainFile(projectName)
+	if err != nil {
+		return err
 	}
 
 	cmd := exec.CommandContext(ctx, "go", "run", mainFile, "serve")
+	cmd.Env = append(os.Environ(), frontend.ModeEnv+"="+string(frontend.ModeDev))
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/spf13/cobra"
+)
+
+var genCmd = &cobra.Command{
+	Use:   "gen",
+	Short: "Generate code from the Go backend",
+}
+
+var genTSFlags struct {
+	out string
+}
+
+var genTSCmd = &cobra.Command{
+	Use:   "ts",
+	Short: "Generate TypeScript types and API client",
+	Long: `Generates TypeScript interfaces for the juango API types and the
+application's routes, and a typed client with a function per route.
+
+The command runs the application's hidden gen-ts command (go run), which
+reflects over the route table in internal/api. Run it whenever a route or
+one of its Go types changes.`,
+	RunE: runGenTS,
+}
+
+func init() {
+	genTSCmd.Flags().StringVarP(&genTSFlags.out, "out", "o", "frontend/src/lib/api.gen.ts", "Output file")
+	genCmd.AddCommand(genTSCmd)
+}
+
+func runGenTS(cmd *cobra.Command, args []string) error {
+	if !isJuangoProject() {
+		return fmt.Errorf("not a juango project (missing go.mod or frontend/package.json)")
+	}
+
+	projectName, err := getProjectName()
+	if err != nil {
+		return fmt.Errorf("getting project name: %w", err)
+	}
+	mainFile, err := findMainFile(projectName)
+	if err != nil {
+		return err
+	}
+
+	run := exec.CommandContext(cmd.Context(), "go", "run", mainFile, "gen-ts", "--out", genTSFlags.out)
+	run.Stdout = os.Stdout
+	run.Stderr = os.Stderr
+	if err := run.Run(); err != nil {
+		return fmt.Errorf("generating TypeScript: %w", err)
+	}
+
+	fmt.Printf("Wrote %s\n", genTSFlags.out)
+	return nil
+}
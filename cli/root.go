@@ -17,6 +17,7 @@ using Go for the backend and Vite/React for the frontend.
 It provides:
   - Project scaffolding with 'juango init'
   - Development server with 'juango dev'
+  - TypeScript types and API client generation with 'juango gen ts'
   - Reusable Go libraries for common patterns (auth, admin, database, etc.)
   - React components and utilities via @juango/ui`,
 }
@@ -32,5 +33,6 @@ func Execute() {
 func init() {
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(devCmd)
+	rootCmd.AddCommand(genCmd)
 	rootCmd.AddCommand(versionCmd)
 }
@@ -1,5 +1,5 @@
 import { createContext, useContext, useState, useEffect, type ReactNode } from 'react'
-import { getApiClient } from '../lib/api'
+import { errorMessage, getApiClient } from '../lib/api'
 import type { AdminModeState } from '../lib/types'
 import { useAuth } from './AuthContext'
 
@@ -51,7 +51,7 @@ export function AdminProvider({ children }: AdminProviderProps) {
       }
     } catch (err) {
       console.error('Failed to get admin mode status:', err)
-      setError('Failed to get admin mode status')
+      setError(errorMessage(err, 'Failed to get admin mode status'))
       setIsAdminMode(false)
       setAdminModeState(null)
     } finally {
@@ -72,7 +72,7 @@ export function AdminProvider({ children }: AdminProviderProps) {
       }
     } catch (err) {
       console.error('Failed to enable admin mode:', err)
-      setError('Failed to enable admin mode')
+      setError(errorMessage(err, 'Failed to enable admin mode'))
       throw err
     } finally {
       setIsLoading(false)
@@ -90,7 +90,7 @@ export function AdminProvider({ children }: AdminProviderProps) {
       setAdminModeState(null)
     } catch (err) {
       console.error('Failed to disable admin mode:', err)
-      setError('Failed to disable admin mode')
+      setError(errorMessage(err, 'Failed to disable admin mode'))
       throw err
     } finally {
       setIsLoading(false)
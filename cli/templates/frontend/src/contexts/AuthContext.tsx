@@ -1,5 +1,6 @@
 import { createContext, useContext, useEffect, useState, useCallback, type ReactNode } from 'react'
 import { getApiClient } from '../lib/api'
+import { takeBootstrapSession } from '../lib/bootstrap'
 import type { User, SessionResponse } from '../lib/types'
 
 interface AuthContextType {
@@ -41,7 +42,7 @@ export function AuthProvider({ children, loginPath = '/login' }: AuthProviderPro
     }
 
     try {
-      const data: SessionResponse = await apiClient.checkSession()
+      const data: SessionResponse = takeBootstrapSession() ?? await apiClient.checkSession()
 
       if (data.authenticated && data.user) {
         setUser(data.user)
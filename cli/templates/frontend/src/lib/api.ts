@@ -3,46 +3,169 @@ import type {
   AdminModeEnableRequest,
   AdminModeEnableResponse,
   AdminModeStatusResponse,
+  AuditLog,
+  AuditLogFilters,
+  CsrfTokenResponse,
   ImpersonationStartRequest,
   ImpersonationStartResponse,
   ImpersonationStopResponse,
   ImpersonationStatusResponse,
   SessionResponse,
   Notification,
+  Problem,
 } from "./types"
+import { createClient } from "./api.gen"
+import { getBootstrap } from "./bootstrap"
 
 const DEFAULT_API_BASE = "/api"
 
+// Requests with these methods must carry the session's CSRF token.
+const CSRF_METHODS = ["POST", "PUT", "PATCH", "DELETE"]
+const CSRF_HEADER = "X-CSRF-Token"
+const CSRF_ERROR = "CSRF check failed"
+
+// ApiError is thrown for error responses. Its message is the detail of the
+// RFC 9457 problem sent by the server, or the status when there is none.
+export class ApiError extends Error {
+  readonly status: number
+  readonly problem: Problem | null
+
+  constructor(status: number, statusText: string, problem: Problem | null) {
+    super(
+      problem?.detail ||
+        problem?.title ||
+        `HTTP error! status: ${status} ${statusText}`
+    )
+    this.name = "ApiError"
+    this.status = status
+    this.problem = problem
+  }
+
+  // fieldErrors maps invalid request fields to their messages.
+  get fieldErrors(): Record<string, string> {
+    const errors: Record<string, string> = {}
+    for (const { field, message } of this.problem?.fields ?? []) {
+      errors[field] = message
+    }
+    return errors
+  }
+}
+
+// errorMessage returns the problem detail of an ApiError, or fallback.
+export function errorMessage(error: unknown, fallback: string): string {
+  if (error instanceof ApiError && error.problem?.detail) {
+    return error.problem.detail
+  }
+  return fallback
+}
+
+async function readProblem(response: Response): Promise<Problem | null> {
+  if (!response.headers.get("Content-Type")?.includes("json")) {
+    return null
+  }
+  try {
+    return (await response.json()) as Problem
+  } catch {
+    return null
+  }
+}
+
+async function apiError(response: Response): Promise<ApiError> {
+  return new ApiError(
+    response.status,
+    response.statusText,
+    await readProblem(response)
+  )
+}
+
 export class ApiClient {
   private baseUrl: string
+  private csrfToken: Promise<string> | null = null
 
   constructor(baseUrl: string = DEFAULT_API_BASE) {
     this.baseUrl = baseUrl
+
+    const token = getBootstrap()?.csrf_token
+    if (token) {
+      this.csrfToken = Promise.resolve(token)
+    }
+  }
+
+  private getCsrfToken(): Promise<string> {
+    if (!this.csrfToken) {
+      this.csrfToken = fetch(`${this.baseUrl}/auth/csrf`, {
+        credentials: "include",
+        headers: { Accept: "application/json" },
+      })
+        .then(async (response) => {
+          if (!response.ok) {
+            throw await apiError(response)
+          }
+          const data: CsrfTokenResponse = await response.json()
+          return data.token
+        })
+        .catch((error) => {
+          this.csrfToken = null
+          throw error
+        })
+    }
+    return this.csrfToken
   }
 
-  private async request<T>(
-    endpoint: string,
-    options: RequestInit = {}
+  private request<T>(endpoint: string, options: RequestInit = {}): Promise<T> {
+    return this.fetchJson<T>(`${this.baseUrl}${endpoint}`, options)
+  }
+
+  // send requests an absolute path, e.g. "/api/auth/session". It is the
+  // transport of the generated client, see `api` below.
+  send<T>(method: string, path: string, body?: unknown): Promise<T> {
+    return this.fetchJson<T>(path, {
+      method,
+      body: body === undefined ? undefined : JSON.stringify(body),
+    })
+  }
+
+  private async fetchJson<T>(
+    url: string,
+    options: RequestInit = {},
+    retryCsrf = true
   ): Promise<T> {
-    const url = `${this.baseUrl}${endpoint}`
+    const method = (options.method ?? "GET").toUpperCase()
+
+    const headers = new Headers(options.headers)
+    if (!headers.has("Content-Type")) {
+      headers.set("Content-Type", "application/json")
+    }
+    if (!headers.has("Accept")) {
+      headers.set("Accept", "application/json, application/problem+json")
+    }
+    if (CSRF_METHODS.includes(method)) {
+      headers.set(CSRF_HEADER, await this.getCsrfToken())
+    }
 
-    const config: RequestInit = {
+    const response = await fetch(url, {
       credentials: "include",
-      headers: {
-        "Content-Type": "application/json",
-        ...options.headers,
-      },
       ...options,
-    }
+      headers,
+    })
 
-    const response = await fetch(url, config)
+    // The session may have been replaced since the token was fetched;
+    // refresh it and retry once.
+    if (response.status === 403 && retryCsrf && CSRF_METHODS.includes(method)) {
+      const problem = await readProblem(response.clone())
+      if (problem?.detail === CSRF_ERROR) {
+        this.csrfToken = null
+        return this.fetchJson<T>(url, options, false)
+      }
+    }
 
     if (!response.ok) {
-      throw new Error(
-        `HTTP error! status: ${response.status} ${response.statusText}`
-      )
+      throw await apiError(response)
     }
 
+    if (response.status === 204) {
+      return undefined as T
+    }
     return await response.json()
   }
 
@@ -109,6 +232,21 @@ export class ApiClient {
     )
   }
 
+  // Audit logs (requires admin mode)
+  async getAuditLogs(filters: AuditLogFilters = {}): Promise<AuditLog[]> {
+    const searchParams = new URLSearchParams()
+    for (const [key, value] of Object.entries(filters)) {
+      if (value !== undefined && value !== "") {
+        searchParams.append(key, String(value))
+      }
+    }
+
+    const query = searchParams.toString()
+    return this.request<AuditLog[]>(
+      `/admin/audit-logs${query ? `?${query}` : ""}`
+    )
+  }
+
   // Notifications
   async getNotifications(params?: {
     unread?: boolean
@@ -164,3 +302,9 @@ export function getApiClient(): ApiClient {
   }
   return defaultClient
 }
+
+// Typed functions for the routes in internal/api/routes.go, generated by
+// `juango gen ts`, e.g. `await api.getSession()`.
+export const api = createClient(<T>(method: string, path: string, body?: unknown) =>
+  getApiClient().send<T>(method, path, body)
+)
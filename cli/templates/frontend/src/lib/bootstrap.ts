@@ -0,0 +1,31 @@
+import type { Bootstrap, SessionResponse } from "./types"
+
+let bootstrap: Bootstrap | null | undefined
+let sessionTaken = false
+
+// getBootstrap returns the data the server embedded in index.html, or null
+// when there is none, e.g. when index.html is served by the Vite dev server.
+export function getBootstrap(): Bootstrap | null {
+  if (bootstrap === undefined) {
+    bootstrap = null
+    const text = document.getElementById("bootstrap")?.textContent
+    if (text) {
+      try {
+        bootstrap = JSON.parse(text) as Bootstrap
+      } catch {
+        // Not rendered by the server
+      }
+    }
+  }
+  return bootstrap
+}
+
+// takeBootstrapSession returns the embedded session the first time it is
+// called and null afterwards, so later checks ask the API.
+export function takeBootstrapSession(): SessionResponse | null {
+  if (sessionTaken) {
+    return null
+  }
+  sessionTaken = true
+  return getBootstrap()?.session ?? null
+}
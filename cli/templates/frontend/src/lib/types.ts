@@ -1,79 +1,10 @@
-// User & Session Types
-export interface User {
-  id: string
-  email: string
-  name: string
-  is_admin: boolean
-  display_name: string
-  profile_pic_url: string
-  last_login?: string
-  created_at: string
-  modified_at: string
-}
-
-export interface SessionResponse {
-  authenticated: boolean
-  user?: User
-  reason?: string
-  impersonation?: ImpersonationState
-}
-
-// Admin Mode Types
-export interface AdminModeState {
-  enabled: boolean
-  since: string
-  reason: string
-  ip_address: string
-}
-
-export interface AdminModeStatusResponse {
-  is_admin: boolean
-  admin_mode?: AdminModeState
-}
-
-export interface AdminModeEnableRequest {
-  reason: string
-}
-
-export interface AdminModeEnableResponse {
-  message: string
-  state?: AdminModeState
-}
-
-export interface AdminModeDisableResponse {
-  message: string
-}
-
-// Impersonation Types
-export interface ImpersonationState {
-  enabled: boolean
-  since: string
-  reason: string
-  target_user_id: string
-  target_user_email: string
-  target_user_name: string
-  original_admin_id: string
-  ip_address: string
-}
-
-export interface ImpersonationStartRequest {
-  target_user_id: string
-  reason: string
-}
-
-export interface ImpersonationStartResponse {
-  message: string
-  impersonation: ImpersonationState
-}
-
-export interface ImpersonationStopResponse {
-  message: string
-}
-
-export interface ImpersonationStatusResponse {
-  active: boolean
-  impersonation?: ImpersonationState
-}
+// API types generated from the Go structs by `juango gen ts`. Don't
+// redefine them here; regenerate after changing a route or a Go type.
+export type * from "./api.gen"
+export type {
+  AdminModeRequest as AdminModeEnableRequest,
+  CSRFTokenResponse as CsrfTokenResponse,
+} from "./api.gen"
 
 // Audit Logs
 export interface AuditLog {
@@ -87,10 +18,18 @@ export interface AuditLog {
   changes?: Record<string, unknown>
   ip_address?: string
   user_agent?: string
+  impersonated_user_id?: string | null
+  session_id?: string
+  request_id?: string
+  trace_id?: string
 }
 
 export interface AuditLogFilters {
   actor_user_id?: string
+  impersonated_user_id?: string
+  session_id?: string
+  request_id?: string
+  trace_id?: string
   action?: string
   resource_type?: string
   resource_id?: string
@@ -100,7 +39,8 @@ export interface AuditLogFilters {
   offset?: number
 }
 
-// Notifications
+// Notifications. This shadows the generated Notification until the backend
+// serves it.
 export interface Notification {
   id: string
   user_id: string
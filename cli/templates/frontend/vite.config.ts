@@ -1,10 +1,44 @@
-import { defineConfig } from 'vite'
+import { defineConfig, type Plugin } from 'vite'
 import react from '@vitejs/plugin-react'
 import path from 'path'
+import { brotliCompressSync, constants, gzipSync } from 'zlib'
+
+// precompress emits .br and .gz variants of text files, which the Go
+// server picks by Accept-Encoding.
+function precompress(): Plugin {
+  const compressible = /\.(js|mjs|css|html|svg|json|txt|xml|wasm)$/
+
+  return {
+    name: 'precompress',
+    apply: 'build',
+    enforce: 'post',
+    generateBundle(_options, bundle) {
+      for (const [fileName, output] of Object.entries(bundle)) {
+        if (!compressible.test(fileName)) continue
+
+        const source = Buffer.from(output.type === 'chunk' ? output.code : output.source)
+        if (source.length < 1024) continue
+
+        this.emitFile({
+          type: 'asset',
+          fileName: `${fileName}.br`,
+          source: brotliCompressSync(source, {
+            params: { [constants.BROTLI_PARAM_QUALITY]: constants.BROTLI_MAX_QUALITY },
+          }),
+        })
+        this.emitFile({
+          type: 'asset',
+          fileName: `${fileName}.gz`,
+          source: gzipSync(source, { level: 9 }),
+        })
+      }
+    },
+  }
+}
 
 // https://vite.dev/config/
 export default defineConfig({
-  plugins: [react()],
+  plugins: [react(), precompress()],
   resolve: {
     alias: {
       '@': path.resolve(__dirname, './src'),
@@ -72,12 +72,75 @@ type SMTPConfig struct {
 	ReplyTo  string `mapstructure:"reply_to"`
 }
 
+// AuditSyslogConfig holds configuration for the RFC 5424 syslog audit sink.
+type AuditSyslogConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	Network  string `mapstructure:"network"`
+	Addr     string `mapstructure:"addr"`
+	AppName  string `mapstructure:"app_name"`
+	Facility *int   `mapstructure:"facility"` // nil: audit.DefaultSyslogFacility
+}
+
+// AuditWebhookConfig holds configuration for the HMAC-signed webhook audit sink.
+type AuditWebhookConfig struct {
+	Enabled bool          `mapstructure:"enabled"`
+	URL     string        `mapstructure:"url"`
+	Secret  string        `mapstructure:"secret"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// AuditFileConfig holds configuration for the rotating JSON-lines audit sink.
+type AuditFileConfig struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	Path       string `mapstructure:"path"`
+	MaxSizeMB  int    `mapstructure:"max_size_mb"`
+	MaxBackups int    `mapstructure:"max_backups"`
+}
+
+// AuditConfig holds configuration for streaming audit events to external sinks.
+type AuditConfig struct {
+	QueueSize      int                `mapstructure:"queue_size"`
+	MaxRetries     int                `mapstructure:"max_retries"`
+	InitialBackoff time.Duration      `mapstructure:"initial_backoff"`
+	MaxBackoff     time.Duration      `mapstructure:"max_backoff"`
+	SendTimeout    time.Duration      `mapstructure:"send_timeout"`
+	Syslog         AuditSyslogConfig  `mapstructure:"syslog"`
+	Webhook        AuditWebhookConfig `mapstructure:"webhook"`
+	File           AuditFileConfig    `mapstructure:"file"`
+}
+
+// TracingConfig holds OpenTelemetry tracing configuration.
+type TracingConfig struct {
+	Enabled     bool              `mapstructure:"enabled"`
+	Endpoint    string            `mapstructure:"endpoint"`
+	Insecure    bool              `mapstructure:"insecure"`
+	Headers     map[string]string `mapstructure:"headers"`
+	ServiceName string            `mapstructure:"service_name"`
+	SampleRatio float64           `mapstructure:"sample_ratio"`
+}
+
+// FrontendConfig holds frontend serving configuration.
+type FrontendConfig struct {
+	// Mode is "dev" or "prod". When empty, frontend.ResolveMode falls back
+	// to the JUANGO_MODE environment variable and the dev build tag.
+	Mode    string `mapstructure:"mode"`
+	DevHost string `mapstructure:"dev_host"`
+
+	// DevFallback serves the embedded build while the Vite dev server is
+	// unreachable in dev mode.
+	DevFallback bool `mapstructure:"dev_fallback"`
+}
+
 // BaseConfig holds common configuration fields used by juango applications.
 type BaseConfig struct {
 	ListenAddr       string        `mapstructure:"listen_addr"`
 	AdvertiseURL     string        `mapstructure:"advertise_url"`
 	AdminModeTimeout time.Duration `mapstructure:"admin_mode_timeout"`
 
+	// TrustedProxies lists the reverse proxies (CIDRs or addresses) whose
+	// forwarding headers are used to resolve client IPs.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+
 	Session  SessionConfig  `mapstructure:"session"`
 	Database DatabaseConfig `mapstructure:"database"`
 	Redis    RedisConfig    `mapstructure:"redis"`
@@ -85,6 +148,9 @@ type BaseConfig struct {
 	OIDC     OIDCConfig     `mapstructure:"oidc"`
 	Logging  LogConfig      `mapstructure:"logging"`
 	SMTP     SMTPConfig     `mapstructure:"smtp"`
+	Audit    AuditConfig    `mapstructure:"audit"`
+	Tracing  TracingConfig  `mapstructure:"tracing"`
+	Frontend FrontendConfig `mapstructure:"frontend"`
 }
 
 // LoaderConfig holds configuration for the config loader.
@@ -123,6 +189,18 @@ func DefaultLoaderConfig(envPrefix string) *LoaderConfig {
 			"logging.level":           "info",
 			"logging.format":          TextLogFormat,
 			"logging.with_caller":     false,
+			"audit.queue_size":        1000,
+			"audit.max_retries":       5,
+			"audit.initial_backoff":   time.Second,
+			"audit.max_backoff":       time.Minute,
+			"audit.send_timeout":      10 * time.Second,
+			"audit.syslog.network":    "udp",
+			"audit.syslog.facility":   13,
+			"audit.webhook.timeout":   10 * time.Second,
+			"audit.file.max_size_mb":  100,
+			"audit.file.max_backups":  5,
+			"tracing.endpoint":        "localhost:4318",
+			"tracing.sample_ratio":    1.0,
 		},
 	}
 }
@@ -212,6 +290,7 @@ func GetBaseConfig() *BaseConfig {
 		ListenAddr:       viper.GetString("listen_addr"),
 		AdvertiseURL:     viper.GetString("advertise_url"),
 		AdminModeTimeout: viper.GetDuration("admin_mode_timeout"),
+		TrustedProxies:   viper.GetStringSlice("trusted_proxies"),
 		Logging:          logConfig,
 		Database: DatabaseConfig{
 			Path:              viper.GetString("database.path"),
@@ -246,7 +325,70 @@ func GetBaseConfig() *BaseConfig {
 			From:     viper.GetString("smtp.from_address"),
 			ReplyTo:  viper.GetString("smtp.reply_to"),
 		},
+		Audit:    GetAuditConfig(),
+		Tracing:  GetTracingConfig(),
+		Frontend: GetFrontendConfig(),
+	}
+}
+
+// GetFrontendConfig returns the frontend serving configuration from Viper.
+func GetFrontendConfig() FrontendConfig {
+	return FrontendConfig{
+		Mode:        viper.GetString("frontend.mode"),
+		DevHost:     viper.GetString("frontend.dev_host"),
+		DevFallback: viper.GetBool("frontend.dev_fallback"),
+	}
+}
+
+// GetTracingConfig returns the OpenTelemetry tracing configuration from Viper.
+func GetTracingConfig() TracingConfig {
+	return TracingConfig{
+		Enabled:     viper.GetBool("tracing.enabled"),
+		Endpoint:    viper.GetString("tracing.endpoint"),
+		Insecure:    viper.GetBool("tracing.insecure"),
+		Headers:     viper.GetStringMapString("tracing.headers"),
+		ServiceName: viper.GetString("tracing.service_name"),
+		SampleRatio: viper.GetFloat64("tracing.sample_ratio"),
+	}
+}
+
+// GetAuditConfig returns the audit sink configuration from Viper.
+func GetAuditConfig() AuditConfig {
+	return AuditConfig{
+		QueueSize:      viper.GetInt("audit.queue_size"),
+		MaxRetries:     viper.GetInt("audit.max_retries"),
+		InitialBackoff: viper.GetDuration("audit.initial_backoff"),
+		MaxBackoff:     viper.GetDuration("audit.max_backoff"),
+		SendTimeout:    viper.GetDuration("audit.send_timeout"),
+		Syslog: AuditSyslogConfig{
+			Enabled:  viper.GetBool("audit.syslog.enabled"),
+			Network:  viper.GetString("audit.syslog.network"),
+			Addr:     viper.GetString("audit.syslog.addr"),
+			AppName:  viper.GetString("audit.syslog.app_name"),
+			Facility: optionalInt("audit.syslog.facility"),
+		},
+		Webhook: AuditWebhookConfig{
+			Enabled: viper.GetBool("audit.webhook.enabled"),
+			URL:     viper.GetString("audit.webhook.url"),
+			Secret:  viper.GetString("audit.webhook.secret"),
+			Timeout: viper.GetDuration("audit.webhook.timeout"),
+		},
+		File: AuditFileConfig{
+			Enabled:    viper.GetBool("audit.file.enabled"),
+			Path:       viper.GetString("audit.file.path"),
+			MaxSizeMB:  viper.GetInt("audit.file.max_size_mb"),
+			MaxBackups: viper.GetInt("audit.file.max_backups"),
+		},
+	}
+}
+
+// optionalInt returns the integer at key, or nil if it isn't set.
+func optionalInt(key string) *int {
+	if !viper.IsSet(key) {
+		return nil
 	}
+	v := viper.GetInt(key)
+	return &v
 }
 
 // ValidateRequired checks that required configuration fields are set.
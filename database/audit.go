@@ -0,0 +1,93 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/juanfont/juango/types"
+)
+
+// Audit log listing limits.
+const (
+	DefaultAuditLogLimit = 100
+	MaxAuditLogLimit     = 1000
+)
+
+// ListAuditLogs returns audit log entries matching filter, newest first,
+// together with the display name of the actor.
+func (d *Database) ListAuditLogs(ctx context.Context, filter types.AuditLogFilter) ([]types.AuditLogEntry, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+
+	if filter.ActorUserID != uuid.Nil {
+		where = append(where, "a.actor_user_id = ?")
+		args = append(args, filter.ActorUserID.String())
+	}
+	if filter.ImpersonatedUserID != uuid.Nil {
+		where = append(where, "a.impersonated_user_id = ?")
+		args = append(args, filter.ImpersonatedUserID.String())
+	}
+	if filter.Action != "" {
+		where = append(where, "a.action = ?")
+		args = append(args, filter.Action)
+	}
+	if filter.ResourceType != "" {
+		where = append(where, "a.resource_type = ?")
+		args = append(args, filter.ResourceType)
+	}
+	if filter.ResourceID != "" {
+		where = append(where, "a.resource_id = ?")
+		args = append(args, filter.ResourceID)
+	}
+	if filter.SessionID != "" {
+		where = append(where, "a.session_id = ?")
+		args = append(args, filter.SessionID)
+	}
+	if filter.RequestID != "" {
+		where = append(where, "a.request_id = ?")
+		args = append(args, filter.RequestID)
+	}
+	if filter.TraceID != "" {
+		where = append(where, "a.trace_id = ?")
+		args = append(args, filter.TraceID)
+	}
+	if !filter.StartTime.IsZero() {
+		where = append(where, "a.timestamp >= ?")
+		args = append(args, filter.StartTime)
+	}
+	if !filter.EndTime.IsZero() {
+		where = append(where, "a.timestamp < ?")
+		args = append(args, filter.EndTime)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultAuditLogLimit
+	}
+	if limit > MaxAuditLogLimit {
+		limit = MaxAuditLogLimit
+	}
+	offset := max(filter.Offset, 0)
+
+	query := `
+		SELECT a.id, a.timestamp, a.actor_user_id, a.action, a.resource_type, a.resource_id,
+			a.changes, a.ip_address, a.user_agent, a.impersonated_user_id, a.session_id, a.request_id, a.trace_id,
+			COALESCE(u.display_name, '') AS actor_name
+		FROM audit_log a
+		LEFT JOIN users u ON u.id = a.actor_user_id`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY a.timestamp DESC, a.id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	entries := []types.AuditLogEntry{}
+	if err := d.SelectContext(ctx, &entries, query, args...); err != nil {
+		return nil, fmt.Errorf("listing audit logs: %w", err)
+	}
+	return entries, nil
+}
@@ -30,7 +30,8 @@ var (
 
 // Database wraps the sqlx database connection.
 type Database struct {
-	db *sqlx.DB
+	db   *sqlx.DB
+	path string
 }
 
 // Config holds database configuration.
@@ -41,6 +42,16 @@ type Config struct {
 
 // New creates a new Database instance with the given path and schema.
 func New(path string, schema string) (*Database, error) {
+	var s *squibble.Schema
+	if schema != "" {
+		s = &squibble.Schema{Current: schema}
+	}
+	return NewWithSchema(path, s)
+}
+
+// NewWithSchema creates a new Database instance and applies a squibble
+// schema, including any update rules for migrating existing databases.
+func NewWithSchema(path string, schema *squibble.Schema) (*Database, error) {
 	// Register types for session serialization
 	registerGobTypes()
 
@@ -50,7 +61,7 @@ func New(path string, schema string) (*Database, error) {
 		return nil, err
 	}
 
-	return &Database{db: db}, nil
+	return &Database{db: db, path: path}, nil
 }
 
 // NewWithConfig creates a new Database with custom configuration.
@@ -91,7 +102,7 @@ func NewWithConfig(cfg *sqliteconfig.Config, schema string) (*Database, error) {
 		}
 	}
 
-	return &Database{db: db}, nil
+	return &Database{db: db, path: cfg.Path}, nil
 }
 
 // registerGobTypes registers types needed for session serialization.
@@ -104,7 +115,7 @@ func registerGobTypes() {
 	gob.Register(sql.NullTime{})
 }
 
-func openDatabase(path string, schema string) (*sqlx.DB, error) {
+func openDatabase(path string, schema *squibble.Schema) (*sqlx.DB, error) {
 	isNewDatabase := false
 	if path != ":memory:" {
 		if _, err := os.Stat(path); os.IsNotExist(err) {
@@ -145,9 +156,8 @@ func openDatabase(path string, schema string) (*sqlx.DB, error) {
 	}
 
 	// Apply schema if provided
-	if schema != "" {
-		s := &squibble.Schema{Current: schema}
-		if err := s.Apply(context.Background(), db.DB); err != nil {
+	if schema != nil {
+		if err := schema.Apply(context.Background(), db.DB); err != nil {
 			db.Close()
 			return nil, fmt.Errorf("%w: %w", ErrApplySchema, err)
 		}
@@ -230,6 +240,47 @@ func MigrateWithSquibble(db *Database, schema *squibble.Schema) error {
 	return nil
 }
 
+// SchemaUpdate builds a squibble update rule that migrates a database from
+// the schema in from to the schema in to by executing stmts. Keep the
+// previous version of your schema file around and pass both texts, e.g.
+//
+//	rule, err := database.SchemaUpdate(schemaV1, schemaV2, database.AuditLogContextColumns...)
+func SchemaUpdate(from, to string, stmts ...string) (squibble.UpdateRule, error) {
+	source, err := squibble.SQLDigest(from)
+	if err != nil {
+		return squibble.UpdateRule{}, fmt.Errorf("computing source schema digest: %w", err)
+	}
+	target, err := squibble.SQLDigest(to)
+	if err != nil {
+		return squibble.UpdateRule{}, fmt.Errorf("computing target schema digest: %w", err)
+	}
+	return squibble.UpdateRule{
+		Source: source,
+		Target: target,
+		Apply:  squibble.Exec(stmts...),
+	}, nil
+}
+
+// AuditLogContextColumns adds the impersonated_user_id, session_id and
+// request_id columns (and their indexes) to an audit_log table created by
+// an older BaseSchema. Use it with SchemaUpdate.
+var AuditLogContextColumns = []string{
+	`ALTER TABLE audit_log ADD COLUMN impersonated_user_id TEXT`,
+	`ALTER TABLE audit_log ADD COLUMN session_id TEXT`,
+	`ALTER TABLE audit_log ADD COLUMN request_id TEXT`,
+	`CREATE INDEX IF NOT EXISTS idx_audit_log_impersonated ON audit_log(impersonated_user_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_audit_log_session ON audit_log(session_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_audit_log_request ON audit_log(request_id)`,
+}
+
+// AuditLogTraceColumns adds the trace_id column (and its index) to an
+// audit_log table that already has the AuditLogContextColumns. Use it with
+// SchemaUpdate.
+var AuditLogTraceColumns = []string{
+	`ALTER TABLE audit_log ADD COLUMN trace_id TEXT`,
+	`CREATE INDEX IF NOT EXISTS idx_audit_log_trace ON audit_log(trace_id)`,
+}
+
 // BaseSchema returns a minimal base schema for juango applications.
 // Applications should extend this with their own tables.
 func BaseSchema() string {
@@ -263,6 +314,10 @@ CREATE TABLE IF NOT EXISTS audit_log (
     changes TEXT,
     ip_address TEXT,
     user_agent TEXT,
+    impersonated_user_id TEXT,
+    session_id TEXT,
+    request_id TEXT,
+    trace_id TEXT,
     FOREIGN KEY (actor_user_id) REFERENCES users(id)
 );
 
@@ -270,6 +325,10 @@ CREATE INDEX IF NOT EXISTS idx_audit_log_timestamp ON audit_log(timestamp DESC);
 CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor_user_id);
 CREATE INDEX IF NOT EXISTS idx_audit_log_action ON audit_log(action);
 CREATE INDEX IF NOT EXISTS idx_audit_log_resource ON audit_log(resource_type, resource_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_impersonated ON audit_log(impersonated_user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_session ON audit_log(session_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_request ON audit_log(request_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_trace ON audit_log(trace_id);
 
 -- Notifications table
 CREATE TABLE IF NOT EXISTS notifications (
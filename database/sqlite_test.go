@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tailscale/squibble"
+)
+
+// schemaUpgrades lists released BaseSchema versions, kept verbatim in
+// testdata, with the statements that upgrade each to the next version.
+var schemaUpgrades = []struct {
+	file  string
+	stmts []string
+}{
+	{file: "base_schema_v1.sql", stmts: AuditLogContextColumns},
+	{file: "base_schema_v2.sql", stmts: AuditLogTraceColumns},
+}
+
+func TestBaseSchemaUpgrades(t *testing.T) {
+	var history []string
+	for _, v := range schemaUpgrades {
+		text, err := os.ReadFile(filepath.Join("testdata", v.file))
+		if err != nil {
+			t.Fatalf("reading %s: %v", v.file, err)
+		}
+		history = append(history, string(text))
+	}
+
+	var rules []squibble.UpdateRule
+	for i, v := range schemaUpgrades {
+		next := BaseSchema()
+		if i+1 < len(history) {
+			next = history[i+1]
+		}
+		rule, err := SchemaUpdate(history[i], next, v.stmts...)
+		if err != nil {
+			t.Fatalf("building rule for %s: %v", v.file, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	for i, v := range schemaUpgrades {
+		t.Run(v.file, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "test.db")
+			old, err := sql.Open("sqlite", path)
+			if err != nil {
+				t.Fatalf("opening database: %v", err)
+			}
+			if err := (&squibble.Schema{Current: history[i]}).Apply(context.Background(), old); err != nil {
+				t.Fatalf("creating %s database: %v", v.file, err)
+			}
+			old.Close()
+
+			db, err := NewWithSchema(path, &squibble.Schema{Current: BaseSchema(), Updates: rules})
+			if err != nil {
+				t.Fatalf("upgrading %s database: %v", v.file, err)
+			}
+			db.Close()
+		})
+	}
+}
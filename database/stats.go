@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// WALCheckpointStats is the result of a WAL checkpoint.
+type WALCheckpointStats struct {
+	// Busy is true if the checkpoint could not complete because of
+	// concurrent readers or writers.
+	Busy bool
+	// LogFrames is the number of frames in the WAL file.
+	LogFrames int
+	// CheckpointedFrames is the number of WAL frames copied back into the
+	// database file.
+	CheckpointedFrames int
+}
+
+// Path returns the database file path.
+func (d *Database) Path() string {
+	return d.path
+}
+
+// WALSize returns the size of the write-ahead log file in bytes, or 0 if
+// there is none (in-memory databases, or journal modes other than WAL).
+func (d *Database) WALSize() (int64, error) {
+	if d.path == "" || d.path == ":memory:" {
+		return 0, nil
+	}
+	info, err := os.Stat(d.path + "-wal")
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// WALCheckpoint runs a passive WAL checkpoint, which copies as many frames
+// as possible without waiting on readers or writers, and returns its stats.
+// Outside WAL mode all counts are -1.
+func (d *Database) WALCheckpoint(ctx context.Context) (WALCheckpointStats, error) {
+	var busy int
+	var stats WALCheckpointStats
+	row := d.db.QueryRowContext(ctx, "PRAGMA wal_checkpoint(PASSIVE)")
+	if err := row.Scan(&busy, &stats.LogFrames, &stats.CheckpointedFrames); err != nil {
+		return WALCheckpointStats{}, err
+	}
+	stats.Busy = busy != 0
+	return stats, nil
+}
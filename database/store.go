@@ -0,0 +1,273 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/juanfont/juango/types"
+)
+
+// User listing limits.
+const (
+	DefaultUserLimit = 100
+	MaxUserLimit     = 1000
+)
+
+const userColumns = `id, email, name, display_name, profile_pic_url, provider_identifier, is_admin,
+	last_login, created_at, modified_at, deleted_at`
+
+// Store implements auth.UserStore and auth.AuditLogger on a database with
+// BaseSchema, using statements prepared once by NewStore. Applications
+// embed it and add their own methods:
+//
+//	type DB struct {
+//		*database.Store
+//	}
+type Store struct {
+	*Database
+
+	upsertUser       *sqlx.NamedStmt
+	userByID         stmt
+	userByEmail      stmt
+	userByProviderID stmt
+	updateLastLogin  stmt
+	deactivateUser   stmt
+	reactivateUser   stmt
+	insertAuditLog   *sqlx.NamedStmt
+	closers          []func() error
+}
+
+// stmt is a prepared statement with its query, kept for tracing.
+type stmt struct {
+	*sqlx.Stmt
+	query string
+}
+
+// NewStore prepares the store's statements on db, which must have the
+// BaseSchema tables. Closing the store closes db.
+func NewStore(ctx context.Context, db *Database) (*Store, error) {
+	s := &Store{Database: db}
+
+	prepare := func(dst *stmt, query string) error {
+		prepared, err := db.db.PreparexContext(ctx, query)
+		if err != nil {
+			return fmt.Errorf("preparing %q: %w", strings.TrimSpace(query), err)
+		}
+		*dst = stmt{Stmt: prepared, query: query}
+		s.closers = append(s.closers, prepared.Close)
+		return nil
+	}
+	prepareNamed := func(dst **sqlx.NamedStmt, query string) error {
+		prepared, err := db.db.PrepareNamedContext(ctx, query)
+		if err != nil {
+			return fmt.Errorf("preparing %q: %w", strings.TrimSpace(query), err)
+		}
+		*dst = prepared
+		s.closers = append(s.closers, prepared.Close)
+		return nil
+	}
+
+	err := errors.Join(
+		prepareNamed(&s.upsertUser, upsertUserQuery),
+		prepare(&s.userByID, "SELECT "+userColumns+" FROM users WHERE id = ?"),
+		prepare(&s.userByEmail, "SELECT "+userColumns+" FROM users WHERE email = ?"),
+		prepare(&s.userByProviderID, "SELECT "+userColumns+" FROM users WHERE provider_identifier = ?"),
+		prepare(&s.updateLastLogin, "UPDATE users SET last_login = CURRENT_TIMESTAMP WHERE id = ?"),
+		prepare(&s.deactivateUser, `UPDATE users SET deleted_at = COALESCE(deleted_at, CURRENT_TIMESTAMP),
+			modified_at = CURRENT_TIMESTAMP WHERE id = ?`),
+		prepare(&s.reactivateUser, "UPDATE users SET deleted_at = NULL, modified_at = CURRENT_TIMESTAMP WHERE id = ?"),
+		prepareNamed(&s.insertAuditLog, insertAuditLogQuery),
+	)
+	if err != nil {
+		s.closeStatements()
+		return nil, err
+	}
+	return s, nil
+}
+
+// upsertUserQuery creates a user or updates its profile in one statement,
+// so concurrent first logins of the same user can't race. The email is
+// kept when the claims carry no verified address.
+const upsertUserQuery = `
+	INSERT INTO users (id, email, name, display_name, profile_pic_url, provider_identifier, is_admin, created_at, modified_at)
+	VALUES (:id, :email, :name, :display_name, :profile_pic_url, :provider_identifier, :is_admin, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	ON CONFLICT (provider_identifier) DO UPDATE SET
+		email = CASE WHEN excluded.email = '' THEN users.email ELSE excluded.email END,
+		name = excluded.name,
+		display_name = excluded.display_name,
+		profile_pic_url = excluded.profile_pic_url,
+		modified_at = CURRENT_TIMESTAMP
+	RETURNING ` + userColumns
+
+const insertAuditLogQuery = `
+	INSERT INTO audit_log (timestamp, actor_user_id, action, resource_type, resource_id, changes, ip_address, user_agent,
+		impersonated_user_id, session_id, request_id, trace_id)
+	VALUES (:timestamp, :actor_user_id, :action, :resource_type, :resource_id, :changes, :ip_address, :user_agent,
+		:impersonated_user_id, :session_id, :request_id, :trace_id)`
+
+// Close closes the prepared statements and the database.
+func (s *Store) Close() error {
+	return errors.Join(s.closeStatements(), s.Database.Close())
+}
+
+func (s *Store) closeStatements() error {
+	var errs []error
+	for _, closeFn := range s.closers {
+		errs = append(errs, closeFn())
+	}
+	s.closers = nil
+	return errors.Join(errs...)
+}
+
+// CreateOrUpdateUserFromClaim creates the user identified by the claims'
+// provider identifier, or updates its profile.
+// Implements auth.UserStore.
+func (s *Store) CreateOrUpdateUserFromClaim(ctx context.Context, claims *types.OIDCClaims) (*types.User, error) {
+	user := types.User{ID: uuid.New()}
+	user.FromClaim(claims)
+
+	ctx, span := startQuerySpan(ctx, upsertUserQuery)
+	var saved types.User
+	err := s.upsertUser.GetContext(ctx, &saved, user)
+	endQuerySpan(span, err)
+	if err != nil {
+		return nil, fmt.Errorf("saving user from claims: %w", err)
+	}
+	return &saved, nil
+}
+
+// UpdateLastLogin sets the last login time of the user to now.
+// Implements auth.UserStore.
+func (s *Store) UpdateLastLogin(ctx context.Context, userID uuid.UUID) error {
+	return s.execUser(ctx, s.updateLastLogin, userID)
+}
+
+// GetUserByID returns the user with the given ID.
+// Implements auth.UserStore.
+func (s *Store) GetUserByID(ctx context.Context, userID uuid.UUID) (*types.User, error) {
+	return s.getUser(ctx, s.userByID, userID.String())
+}
+
+// GetUserByEmail returns the user with the given email address.
+// Implements auth.UserStore.
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (*types.User, error) {
+	return s.getUser(ctx, s.userByEmail, email)
+}
+
+// GetUserByProviderIdentifier returns the user with the given OIDC provider
+// identifier (see types.User.FromClaim).
+// Implements auth.UserStore.
+func (s *Store) GetUserByProviderIdentifier(ctx context.Context, identifier string) (*types.User, error) {
+	return s.getUser(ctx, s.userByProviderID, identifier)
+}
+
+// ListUsers returns users matching filter, ordered by email. The query
+// depends on the filter, so it isn't prepared.
+// Implements auth.UserStore.
+func (s *Store) ListUsers(ctx context.Context, filter types.UserFilter) ([]types.User, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+
+	if !filter.IncludeInactive {
+		where = append(where, "deleted_at IS NULL")
+	}
+	if filter.IsAdmin != nil {
+		where = append(where, "is_admin = ?")
+		args = append(args, *filter.IsAdmin)
+	}
+	if filter.Search != "" {
+		where = append(where, `(email LIKE ? ESCAPE '\' OR name LIKE ? ESCAPE '\' OR display_name LIKE ? ESCAPE '\')`)
+		pattern := "%" + escapeLike(filter.Search) + "%"
+		args = append(args, pattern, pattern, pattern)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultUserLimit
+	}
+	if limit > MaxUserLimit {
+		limit = MaxUserLimit
+	}
+	offset := max(filter.Offset, 0)
+
+	query := "SELECT " + userColumns + " FROM users"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY email, id LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	users := []types.User{}
+	if err := s.SelectContext(ctx, &users, query, args...); err != nil {
+		return nil, fmt.Errorf("listing users: %w", err)
+	}
+	return users, nil
+}
+
+// Deactivate soft-deletes the user. Deactivating an inactive user keeps
+// the original deactivation time.
+// Implements auth.UserStore.
+func (s *Store) Deactivate(ctx context.Context, userID uuid.UUID) error {
+	return s.execUser(ctx, s.deactivateUser, userID)
+}
+
+// Reactivate clears the user's deactivation.
+// Implements auth.UserStore.
+func (s *Store) Reactivate(ctx context.Context, userID uuid.UUID) error {
+	return s.execUser(ctx, s.reactivateUser, userID)
+}
+
+// CreateAuditLog inserts an audit log entry. Entries that don't match
+// their registered payload schema are inserted too, and the validation
+// error is returned afterwards (see types.IsAuditValidationError).
+// Implements auth.AuditLogger.
+func (s *Store) CreateAuditLog(ctx context.Context, log *types.AuditLog) error {
+	ctx, span := startQuerySpan(ctx, insertAuditLogQuery)
+	_, err := s.insertAuditLog.ExecContext(ctx, log)
+	endQuerySpan(span, err)
+	if err != nil {
+		return fmt.Errorf("creating audit log: %w", err)
+	}
+	return log.Validate()
+}
+
+func (s *Store) getUser(ctx context.Context, stmt stmt, arg string) (*types.User, error) {
+	ctx, span := startQuerySpan(ctx, stmt.query)
+	var user types.User
+	err := stmt.GetContext(ctx, &user, arg)
+	endQuerySpan(span, err)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("user: %w", types.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting user: %w", err)
+	}
+	return &user, nil
+}
+
+// execUser runs an update of one user and reports types.ErrNotFound if
+// there is no such user.
+func (s *Store) execUser(ctx context.Context, stmt stmt, userID uuid.UUID) error {
+	ctx, span := startQuerySpan(ctx, stmt.query)
+	res, err := stmt.ExecContext(ctx, userID.String())
+	endQuerySpan(span, err)
+	if err != nil {
+		return fmt.Errorf("updating user: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("user %s: %w", userID, types.ErrNotFound)
+	}
+	return nil
+}
+
+// escapeLike escapes the LIKE wildcards in s.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,289 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/juanfont/juango/types"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	db, err := New(filepath.Join(t.TempDir(), "test.db"), BaseSchema())
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	store, err := NewStore(context.Background(), db)
+	if err != nil {
+		db.Close()
+		t.Fatalf("creating store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	return store
+}
+
+func testClaims(sub, email, name string) *types.OIDCClaims {
+	return &types.OIDCClaims{
+		Iss:           "https://idp.example.com",
+		Sub:           sub,
+		Email:         email,
+		EmailVerified: email != "",
+		Name:          name,
+		Username:      sub,
+	}
+}
+
+func TestStoreCreateOrUpdateUserFromClaim(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	created, err := store.CreateOrUpdateUserFromClaim(ctx, testClaims("alice", "alice@example.com", "Alice"))
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	if created.ID == uuid.Nil || created.Email != "alice@example.com" || created.DisplayName != "Alice" {
+		t.Fatalf("created user = %+v", created)
+	}
+	if created.CreatedAt.IsZero() {
+		t.Errorf("created_at not returned")
+	}
+
+	// A login without a verified email updates the profile and keeps the
+	// email.
+	updated, err := store.CreateOrUpdateUserFromClaim(ctx, testClaims("alice", "", "Alice Liddell"))
+	if err != nil {
+		t.Fatalf("updating user: %v", err)
+	}
+	if updated.ID != created.ID {
+		t.Errorf("updated ID = %s, want %s", updated.ID, created.ID)
+	}
+	if updated.DisplayName != "Alice Liddell" || updated.Email != "alice@example.com" {
+		t.Errorf("updated user = %+v", updated)
+	}
+
+	users, err := store.ListUsers(ctx, types.UserFilter{})
+	if err != nil {
+		t.Fatalf("listing users: %v", err)
+	}
+	if len(users) != 1 {
+		t.Errorf("got %d users, want 1", len(users))
+	}
+}
+
+func TestStoreConcurrentFirstLogin(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	const logins = 20
+	ids := make([]uuid.UUID, logins)
+	errs := make([]error, logins)
+	var wg sync.WaitGroup
+	for i := range logins {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user, err := store.CreateOrUpdateUserFromClaim(ctx, testClaims("bob", "bob@example.com", "Bob"))
+			errs[i] = err
+			if err == nil {
+				ids[i] = user.ID
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := range logins {
+		if errs[i] != nil {
+			t.Fatalf("login %d: %v", i, errs[i])
+		}
+		if ids[i] != ids[0] {
+			t.Fatalf("login %d got user %s, login 0 got %s", i, ids[i], ids[0])
+		}
+	}
+}
+
+func TestStoreLookups(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	user, err := store.CreateOrUpdateUserFromClaim(ctx, testClaims("carol", "carol@example.com", "Carol"))
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+
+	byID, err := store.GetUserByID(ctx, user.ID)
+	if err != nil || byID.Email != user.Email {
+		t.Errorf("GetUserByID = %+v, %v", byID, err)
+	}
+	byEmail, err := store.GetUserByEmail(ctx, "carol@example.com")
+	if err != nil || byEmail.ID != user.ID {
+		t.Errorf("GetUserByEmail = %+v, %v", byEmail, err)
+	}
+	byIdentifier, err := store.GetUserByProviderIdentifier(ctx, user.ProviderIdentifier.String)
+	if err != nil || byIdentifier.ID != user.ID {
+		t.Errorf("GetUserByProviderIdentifier = %+v, %v", byIdentifier, err)
+	}
+
+	if err := store.UpdateLastLogin(ctx, user.ID); err != nil {
+		t.Fatalf("updating last login: %v", err)
+	}
+	if byID, _ := store.GetUserByID(ctx, user.ID); byID.LastLogin == nil {
+		t.Errorf("last_login not set")
+	}
+
+	if _, err := store.GetUserByID(ctx, uuid.New()); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("GetUserByID(unknown) error = %v, want ErrNotFound", err)
+	}
+	if _, err := store.GetUserByEmail(ctx, "nobody@example.com"); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("GetUserByEmail(unknown) error = %v, want ErrNotFound", err)
+	}
+	if err := store.UpdateLastLogin(ctx, uuid.New()); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("UpdateLastLogin(unknown) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStoreListUsers(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	for _, c := range []*types.OIDCClaims{
+		testClaims("dave", "dave@example.com", "Dave"),
+		testClaims("erin", "erin_admin@example.com", "Erin"),
+		testClaims("frank", "frank@example.com", "Frank 100%"),
+	} {
+		if _, err := store.CreateOrUpdateUserFromClaim(ctx, c); err != nil {
+			t.Fatalf("creating user: %v", err)
+		}
+	}
+	if _, err := store.ExecContext(ctx, "UPDATE users SET is_admin = 1 WHERE email = ?", "erin_admin@example.com"); err != nil {
+		t.Fatalf("promoting user: %v", err)
+	}
+
+	admin := true
+	tests := []struct {
+		name   string
+		filter types.UserFilter
+		want   []string
+	}{
+		{"all", types.UserFilter{}, []string{"dave@example.com", "erin_admin@example.com", "frank@example.com"}},
+		{"search", types.UserFilter{Search: "FRANK"}, []string{"frank@example.com"}},
+		{"search escapes underscore", types.UserFilter{Search: "n_a"}, []string{"erin_admin@example.com"}},
+		{"search escapes percent", types.UserFilter{Search: "0%"}, []string{"frank@example.com"}},
+		{"admins", types.UserFilter{IsAdmin: &admin}, []string{"erin_admin@example.com"}},
+		{"paging", types.UserFilter{Limit: 1, Offset: 1}, []string{"erin_admin@example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, err := store.ListUsers(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("listing users: %v", err)
+			}
+			var got []string
+			for _, u := range users {
+				got = append(got, u.Email)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreDeactivate(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	user, err := store.CreateOrUpdateUserFromClaim(ctx, testClaims("grace", "grace@example.com", "Grace"))
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+
+	if err := store.Deactivate(ctx, user.ID); err != nil {
+		t.Fatalf("deactivating user: %v", err)
+	}
+	deactivated, err := store.GetUserByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("getting user: %v", err)
+	}
+	if deactivated.IsActive() {
+		t.Errorf("user still active after Deactivate")
+	}
+	if users, _ := store.ListUsers(ctx, types.UserFilter{}); len(users) != 0 {
+		t.Errorf("ListUsers returned deactivated users: %+v", users)
+	}
+	if users, _ := store.ListUsers(ctx, types.UserFilter{IncludeInactive: true}); len(users) != 1 {
+		t.Errorf("ListUsers(IncludeInactive) returned %d users, want 1", len(users))
+	}
+
+	// Logging in doesn't reactivate the user.
+	relogged, err := store.CreateOrUpdateUserFromClaim(ctx, testClaims("grace", "grace@example.com", "Grace"))
+	if err != nil {
+		t.Fatalf("logging in: %v", err)
+	}
+	if relogged.IsActive() {
+		t.Errorf("login reactivated the user")
+	}
+
+	if err := store.Reactivate(ctx, user.ID); err != nil {
+		t.Fatalf("reactivating user: %v", err)
+	}
+	if reactivated, _ := store.GetUserByID(ctx, user.ID); !reactivated.IsActive() {
+		t.Errorf("user inactive after Reactivate")
+	}
+
+	if err := store.Deactivate(ctx, uuid.New()); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("Deactivate(unknown) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStoreCreateAuditLog(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	user, err := store.CreateOrUpdateUserFromClaim(ctx, testClaims("heidi", "heidi@example.com", "Heidi"))
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+
+	entry, err := types.NewAuditLog(
+		&types.NullUUID{UUID: user.ID, Valid: true},
+		types.ActionUserLoggedIn,
+		types.ResourceTypeUser,
+		user.ID.String(),
+	).WithPayload(types.UserLoggedInChanges{Email: user.Email, DisplayName: user.DisplayName})
+	if err != nil {
+		t.Fatalf("encoding payload: %v", err)
+	}
+	if err := store.CreateAuditLog(ctx, entry.WithSessionID("session-1")); err != nil {
+		t.Fatalf("creating audit log: %v", err)
+	}
+
+	// Entries failing validation are stored, and the error reported.
+	invalid := types.NewAuditLog(&types.NullUUID{UUID: user.ID, Valid: true}, "item.unregistered", "item", "1")
+	if err := store.CreateAuditLog(ctx, invalid); !errors.Is(err, types.ErrUnknownAuditAction) {
+		t.Errorf("CreateAuditLog(unregistered) error = %v, want ErrUnknownAuditAction", err)
+	}
+
+	entries, err := store.ListAuditLogs(ctx, types.AuditLogFilter{ActorUserID: user.ID})
+	if err != nil {
+		t.Fatalf("listing audit logs: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d audit log entries, want 2", len(entries))
+	}
+	for _, got := range entries {
+		if got.ActorName != "Heidi" {
+			t.Errorf("audit log entry = %+v", got)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of database spans.
+const tracerName = "github.com/juanfont/juango/database"
+
+// The methods below wrap the sqlx methods of the same name and record an
+// OpenTelemetry client span for each query. Queries made directly through
+// DB() are not traced.
+
+// GetContext runs a query that returns a single row and scans it into dest.
+func (d *Database) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	ctx, span := startQuerySpan(ctx, query)
+	err := d.db.GetContext(ctx, dest, query, args...)
+	endQuerySpan(span, err)
+	return err
+}
+
+// SelectContext runs a query and scans all rows into dest.
+func (d *Database) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	ctx, span := startQuerySpan(ctx, query)
+	err := d.db.SelectContext(ctx, dest, query, args...)
+	endQuerySpan(span, err)
+	return err
+}
+
+// ExecContext executes a statement without returning rows.
+func (d *Database) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	res, err := d.db.ExecContext(ctx, query, args...)
+	endQuerySpan(span, err)
+	return res, err
+}
+
+// NamedExecContext executes a statement with named parameters bound from arg.
+func (d *Database) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	res, err := d.db.NamedExecContext(ctx, query, arg)
+	endQuerySpan(span, err)
+	return res, err
+}
+
+// QueryxContext runs a query and returns the rows. The span covers
+// executing the query, not iterating the rows.
+func (d *Database) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	rows, err := d.db.QueryxContext(ctx, query, args...)
+	endQuerySpan(span, err)
+	return rows, err
+}
+
+func startQuerySpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	operation := queryOperation(query)
+	return otel.Tracer(tracerName).Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNameSQLite,
+			semconv.DBOperationName(operation),
+			semconv.DBQueryText(strings.TrimSpace(query)),
+		),
+	)
+}
+
+func endQuerySpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// queryOperation returns the SQL keyword a query starts with, e.g. "SELECT".
+func queryOperation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "QUERY"
+	}
+	return strings.ToUpper(fields[0])
+}
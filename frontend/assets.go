@@ -0,0 +1,173 @@
+package frontend
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cache-Control values for SPA responses. Vite content-hashes the files it
+// emits under assets/, so they never change; everything else must be
+// revalidated with its ETag.
+const (
+	cacheImmutable   = "public, max-age=31536000, immutable"
+	cacheRevalidate  = "no-cache"
+	hashedAssetsPath = "assets/"
+)
+
+// minCompressSize is the smallest file worth compressing.
+const minCompressSize = 1024
+
+// asset is a file from the dist directory, loaded in memory with its
+// precompressed variants.
+type asset struct {
+	name        string
+	contentType string
+	etag        string // strong ETag of the identity encoding, without quotes
+	data        []byte
+	gzip        []byte
+	brotli      []byte
+}
+
+// loadAssets reads every file under root. Brotli and gzip variants built
+// alongside a file (name.br, name.gz) are attached to it; text files
+// without a gzip variant are compressed now.
+func loadAssets(fsys fs.FS) (map[string]*asset, error) {
+	files := make(map[string][]byte)
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		files[p] = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	assets := make(map[string]*asset, len(files))
+	for name, data := range files {
+		if isVariant(name, files) {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		a := &asset{
+			name:        name,
+			contentType: contentType(name, data),
+			etag:        base64.RawURLEncoding.EncodeToString(sum[:16]),
+			data:        data,
+			gzip:        files[name+".gz"],
+			brotli:      files[name+".br"],
+		}
+		if a.gzip == nil && compressible(a.contentType) && len(data) >= minCompressSize {
+			a.gzip = gzipBytes(data)
+		}
+		assets[name] = a
+	}
+	return assets, nil
+}
+
+// isVariant reports whether name is a precompressed copy of another file.
+func isVariant(name string, files map[string][]byte) bool {
+	for _, ext := range []string{".gz", ".br"} {
+		if base, ok := strings.CutSuffix(name, ext); ok {
+			if _, exists := files[base]; exists {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contentType(name string, data []byte) string {
+	if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+		return ct
+	}
+	return http.DetectContentType(data)
+}
+
+func compressible(contentType string) bool {
+	ct, _, _ := strings.Cut(contentType, ";")
+	switch {
+	case strings.HasPrefix(ct, "text/"),
+		strings.HasSuffix(ct, "+json"), strings.HasSuffix(ct, "+xml"):
+		return true
+	}
+	switch ct {
+	case "application/javascript", "application/json", "application/xml",
+		"application/wasm", "image/svg+xml", "application/manifest+json":
+		return true
+	}
+	return false
+}
+
+// gzipBytes compresses data, returning nil if that doesn't save at least
+// a tenth of its size.
+func gzipBytes(data []byte) []byte {
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	zw.Write(data)
+	zw.Close()
+	if buf.Len() > len(data)*9/10 {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// serve writes the asset, picking the best encoding the client accepts.
+// Conditional and range requests are handled by http.ServeContent using
+// the per-encoding ETag.
+func (a *asset) serve(w http.ResponseWriter, r *http.Request, cacheControl string) {
+	h := w.Header()
+	h.Set("Content-Type", a.contentType)
+	h.Set("Cache-Control", cacheControl)
+
+	data, encoding, etagSuffix := a.data, "", ""
+	if a.gzip != nil || a.brotli != nil {
+		h.Add("Vary", "Accept-Encoding")
+		accepted := acceptedEncodings(r.Header.Get("Accept-Encoding"))
+		switch {
+		case a.brotli != nil && accepted["br"]:
+			data, encoding, etagSuffix = a.brotli, "br", "-br"
+		case a.gzip != nil && accepted["gzip"]:
+			data, encoding, etagSuffix = a.gzip, "gzip", "-gz"
+		}
+	}
+	if encoding != "" {
+		h.Set("Content-Encoding", encoding)
+	}
+	h.Set("ETag", `"`+a.etag+etagSuffix+`"`)
+
+	http.ServeContent(w, r, a.name, time.Time{}, bytes.NewReader(data))
+}
+
+// acceptedEncodings parses Accept-Encoding, dropping encodings with q=0.
+func acceptedEncodings(header string) map[string]bool {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				continue
+			}
+		}
+		accepted[coding] = true
+	}
+	return accepted
+}
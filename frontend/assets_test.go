@@ -0,0 +1,168 @@
+package frontend
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func serveAsset(t *testing.T, h http.Handler, path string, header map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAssetEncodingNegotiation(t *testing.T) {
+	h := NewSPAHandler(testFS, testDist, "index.html")
+	original, err := testFS.ReadFile(testDist + "/assets/app.js")
+	if err != nil {
+		t.Fatalf("reading app.js: %v", err)
+	}
+	brotli, err := testFS.ReadFile(testDist + "/assets/app.js.br")
+	if err != nil {
+		t.Fatalf("reading app.js.br: %v", err)
+	}
+
+	tests := []struct {
+		acceptEncoding string
+		wantEncoding   string
+	}{
+		{acceptEncoding: "", wantEncoding: ""},
+		{acceptEncoding: "identity", wantEncoding: ""},
+		{acceptEncoding: "gzip", wantEncoding: "gzip"},
+		{acceptEncoding: "gzip, deflate, br", wantEncoding: "br"},
+		{acceptEncoding: "GZIP;q=0.5", wantEncoding: "gzip"},
+		{acceptEncoding: "br;q=0, gzip", wantEncoding: "gzip"},
+		{acceptEncoding: "br;q=0, gzip;q=0", wantEncoding: ""},
+	}
+	etags := make(map[string]string)
+	for _, tt := range tests {
+		t.Run(tt.acceptEncoding, func(t *testing.T) {
+			rec := serveAsset(t, h, "/assets/app.js", map[string]string{"Accept-Encoding": tt.acceptEncoding})
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d, want %d", rec.Code, http.StatusOK)
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+			if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want Accept-Encoding", got)
+			}
+
+			body := rec.Body.Bytes()
+			switch tt.wantEncoding {
+			case "gzip":
+				zr, err := gzip.NewReader(bytes.NewReader(body))
+				if err != nil {
+					t.Fatalf("reading gzip body: %v", err)
+				}
+				if body, err = io.ReadAll(zr); err != nil {
+					t.Fatalf("decompressing body: %v", err)
+				}
+				fallthrough
+			case "":
+				if !bytes.Equal(body, original) {
+					t.Errorf("body doesn't match app.js")
+				}
+			case "br":
+				if !bytes.Equal(body, brotli) {
+					t.Errorf("body doesn't match app.js.br")
+				}
+			}
+
+			etag := rec.Header().Get("ETag")
+			if prev, ok := etags[tt.wantEncoding]; ok && prev != etag {
+				t.Errorf("ETag %s differs from %s for the same encoding", etag, prev)
+			}
+			etags[tt.wantEncoding] = etag
+		})
+	}
+
+	if etags[""] == etags["gzip"] || etags[""] == etags["br"] || etags["gzip"] == etags["br"] {
+		t.Errorf("encodings share ETags: %v", etags)
+	}
+}
+
+func TestAssetVariantsNotServedDirectly(t *testing.T) {
+	h := NewSPAHandler(testFS, testDist, "index.html")
+
+	// The .br file is attached to app.js, so its own path is missing.
+	if rec := serveAsset(t, h, "/assets/app.js.br", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestAssetSmallFileNotCompressed(t *testing.T) {
+	h := NewSPAHandler(testFS, testDist, "index.html")
+
+	rec := serveAsset(t, h, "/robots.txt", map[string]string{"Accept-Encoding": "gzip, br"})
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("Content-Encoding = %q, want none", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "" {
+		t.Errorf("Vary = %q, want none for a single encoding", got)
+	}
+}
+
+func TestAssetConditionalRequests(t *testing.T) {
+	h := NewSPAHandler(testFS, testDist, "index.html")
+
+	for _, encoding := range []string{"", "gzip", "br"} {
+		t.Run("encoding="+encoding, func(t *testing.T) {
+			header := map[string]string{"Accept-Encoding": encoding}
+			etag := serveAsset(t, h, "/assets/app.js", header).Header().Get("ETag")
+			if etag == "" {
+				t.Fatalf("no ETag")
+			}
+
+			header["If-None-Match"] = etag
+			rec := serveAsset(t, h, "/assets/app.js", header)
+			if rec.Code != http.StatusNotModified {
+				t.Errorf("matching If-None-Match: status %d, want %d", rec.Code, http.StatusNotModified)
+			}
+			if rec.Body.Len() != 0 {
+				t.Errorf("304 response has a body")
+			}
+
+			header["If-None-Match"] = `"stale"`
+			if rec := serveAsset(t, h, "/assets/app.js", header); rec.Code != http.StatusOK {
+				t.Errorf("stale If-None-Match: status %d, want %d", rec.Code, http.StatusOK)
+			}
+		})
+	}
+
+	// The identity ETag doesn't validate the gzip variant.
+	identity := serveAsset(t, h, "/assets/app.js", nil).Header().Get("ETag")
+	rec := serveAsset(t, h, "/assets/app.js", map[string]string{"Accept-Encoding": "gzip", "If-None-Match": identity})
+	if rec.Code != http.StatusOK {
+		t.Errorf("identity ETag for gzip: status %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestAssetCacheControl(t *testing.T) {
+	h := NewSPAHandler(testFS, testDist, "index.html")
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/assets/app.js", want: cacheImmutable},
+		{path: "/robots.txt", want: cacheRevalidate},
+		{path: "/", want: cacheRevalidate},
+		{path: "/index.html", want: cacheRevalidate},
+		{path: "/deep/route", want: cacheRevalidate},
+	}
+	for _, tt := range tests {
+		if got := serveAsset(t, h, tt.path, nil).Header().Get("Cache-Control"); got != tt.want {
+			t.Errorf("%s: Cache-Control = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
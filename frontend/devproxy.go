@@ -0,0 +1,241 @@
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultDevRetryTimeout is how long a proxied request waits for the Vite
+// dev server to accept connections.
+const DefaultDevRetryTimeout = 10 * time.Second
+
+// DevFallbackHeader is set on responses served from the stale build
+// because the Vite dev server was unreachable.
+const DevFallbackHeader = "X-Juango-Dev-Fallback"
+
+// devDialInterval is the pause between connection attempts.
+const devDialInterval = 250 * time.Millisecond
+
+// DevProxy proxies requests to the Vite dev server, including the WebSocket
+// connections used for hot module replacement.
+//
+// While Vite is starting, requests wait up to the retry timeout for it to
+// accept connections. When it stays unreachable, requests are answered by
+// the fallback handler if there is one (e.g. an SPAHandler serving a stale
+// build) or by an HTML page explaining the failure that reloads itself
+// until Vite is back.
+type DevProxy struct {
+	host         string
+	retryTimeout time.Duration
+	fallback     http.Handler
+	proxy        *httputil.ReverseProxy
+
+	// down is set once Vite stayed unreachable for the retry timeout, so
+	// that later requests fail fast until a connection succeeds again.
+	down atomic.Bool
+}
+
+// NewDevProxy creates a proxy to cfg.DevHost. fallback may be nil.
+func NewDevProxy(cfg *Config, fallback http.Handler) *DevProxy {
+	cfg.setDefaults()
+	p := &DevProxy{
+		host:         cfg.DevHost,
+		retryTimeout: cfg.DevRetryTimeout,
+		fallback:     fallback,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = p.dial
+
+	target := &url.URL{Scheme: "http", Host: cfg.DevHost}
+	p.proxy = &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.Out.Host = pr.In.Host
+		},
+		Transport:    transport,
+		ErrorHandler: p.handleError,
+	}
+	return p
+}
+
+// ServeHTTP implements http.Handler.
+func (p *DevProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if isWebSocketUpgrade(r) {
+		p.serveWebSocket(w, r)
+		return
+	}
+	p.proxy.ServeHTTP(w, r)
+}
+
+// dial connects to the dev server, retrying until the retry timeout while
+// it is starting.
+func (p *DevProxy) dial(ctx context.Context, network, _ string) (net.Conn, error) {
+	timeout := p.retryTimeout
+	if p.down.Load() {
+		timeout = 0
+	}
+	conn, err := dialRetry(ctx, network, p.host, timeout)
+	p.down.Store(err != nil)
+	return conn, err
+}
+
+// serveWebSocket tunnels a WebSocket upgrade to the dev server. The
+// handshake and frames are copied verbatim in both directions.
+func (p *DevProxy) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	backend, err := p.dial(r.Context(), "tcp", p.host)
+	if err != nil {
+		log.Warn().Err(err).Str("devHost", p.host).Msg("Vite dev server unreachable for WebSocket")
+		http.Error(w, "vite dev server unavailable", http.StatusBadGateway)
+		return
+	}
+	defer backend.Close()
+
+	if err := r.Write(backend); err != nil {
+		http.Error(w, "vite dev server unavailable", http.StatusBadGateway)
+		return
+	}
+
+	client, buf, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to hijack WebSocket connection")
+		http.Error(w, "websocket proxying not supported", http.StatusInternalServerError)
+		return
+	}
+	defer client.Close()
+
+	// Forward anything the client sent after the handshake that the server
+	// already buffered.
+	if n := buf.Reader.Buffered(); n > 0 {
+		data, _ := buf.Reader.Peek(n)
+		if _, err := backend.Write(data); err != nil {
+			return
+		}
+	}
+
+	errc := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(backend, client)
+		errc <- err
+	}()
+	go func() {
+		_, err := io.Copy(client, backend)
+		errc <- err
+	}()
+	<-errc
+}
+
+// handleError answers requests the dev server couldn't serve: from the
+// fallback for GET and HEAD, otherwise with the overlay page for browsers
+// and a plain 502 for everything else.
+func (p *DevProxy) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	if r.Context().Err() != nil {
+		return
+	}
+
+	log.Warn().
+		Err(err).
+		Str("devHost", p.host).
+		Str("path", r.URL.Path).
+		Msg("Vite dev server request failed")
+
+	if p.fallback != nil && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		w.Header().Set(DevFallbackHeader, "stale-build")
+		p.fallback.ServeHTTP(w, r)
+		return
+	}
+
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		http.Error(w, fmt.Sprintf("vite dev server at %s unavailable: %v", p.host, err), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusBadGateway)
+	overlayTemplate.Execute(w, struct {
+		Host  string
+		Path  string
+		Error string
+	}{p.host, r.URL.Path, err.Error()})
+}
+
+// isWebSocketUpgrade reports whether r asks to switch to the WebSocket
+// protocol.
+func isWebSocketUpgrade(r *http.Request) bool {
+	return headerContainsToken(r.Header, "Connection", "upgrade") &&
+		strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// headerContainsToken reports whether the comma-separated header contains
+// token, case-insensitively.
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dialRetry connects to host, retrying until timeout or ctx is done. A zero
+// timeout tries once.
+func dialRetry(ctx context.Context, network, host string, timeout time.Duration) (net.Conn, error) {
+	deadline := time.Now().Add(timeout)
+	var dialer net.Dialer
+	for {
+		conn, err := dialer.DialContext(ctx, network, host)
+		if err == nil {
+			return conn, nil
+		}
+		if time.Now().Add(devDialInterval).After(deadline) {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(devDialInterval):
+		}
+	}
+}
+
+// overlayTemplate is the page shown when the dev server is unreachable.
+// It reloads itself so the app appears as soon as Vite is up.
+var overlayTemplate = template.Must(template.New("overlay").Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="2">
+<title>Vite dev server unavailable</title>
+<style>
+body { margin: 0; font: 15px/1.5 ui-monospace, SFMono-Regular, Menlo, monospace; background: #181818; color: #d8d8d8; }
+main { max-width: 720px; margin: 10vh auto; padding: 24px 32px; border-top: 4px solid #ff5555; background: #232323; }
+h1 { margin-top: 0; font-size: 18px; color: #ff5555; }
+pre { white-space: pre-wrap; color: #ffb86c; }
+code { color: #8be9fd; }
+</style>
+</head>
+<body>
+<main>
+<h1>Vite dev server unavailable</h1>
+<p>The request for <code>{{.Path}}</code> couldn't be proxied to <code>{{.Host}}</code>:</p>
+<pre>{{.Error}}</pre>
+<p>If Vite is still starting, this page reloads automatically once it's up.
+Otherwise check the <code>npm run dev</code> output, or run <code>juango dev</code> to start both servers.</p>
+</main>
+</body>
+</html>
+`))
@@ -0,0 +1,234 @@
+package frontend
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// unreachableHost returns an address nothing listens on.
+func unreachableHost(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+func TestDevProxyForwardsRequests(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream-Host", r.Host)
+		io.WriteString(w, "vite:"+r.URL.Path)
+	}))
+	defer upstream.Close()
+
+	proxy := NewDevProxy(&Config{DevHost: strings.TrimPrefix(upstream.URL, "http://")}, nil)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "http://app.example.com/src/main.ts", nil)
+	proxy.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "vite:/src/main.ts" {
+		t.Fatalf("got %d %q, want 200 from upstream", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("X-Upstream-Host"); got != "app.example.com" {
+		t.Errorf("upstream saw Host %q, want the original host", got)
+	}
+}
+
+func TestDevProxyUpstreamDown(t *testing.T) {
+	cfg := &Config{DevHost: unreachableHost(t), DevRetryTimeout: time.Nanosecond}
+
+	tests := []struct {
+		name         string
+		fallback     http.Handler
+		method       string
+		accept       string
+		wantStatus   int
+		wantType     string
+		wantBody     string
+		wantFallback bool
+	}{
+		{
+			name:       "browser gets overlay",
+			method:     http.MethodGet,
+			accept:     "text/html,application/xhtml+xml",
+			wantStatus: http.StatusBadGateway,
+			wantType:   "text/html; charset=utf-8",
+			wantBody:   "Vite dev server unavailable",
+		},
+		{
+			name:       "non-browser gets plain error",
+			method:     http.MethodGet,
+			accept:     "application/json",
+			wantStatus: http.StatusBadGateway,
+			wantType:   "text/plain; charset=utf-8",
+			wantBody:   "vite dev server at " + cfg.DevHost + " unavailable",
+		},
+		{
+			name:         "fallback serves stale build",
+			fallback:     http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "stale") }),
+			method:       http.MethodGet,
+			accept:       "text/html",
+			wantStatus:   http.StatusOK,
+			wantBody:     "stale",
+			wantFallback: true,
+		},
+		{
+			name:       "fallback skipped for POST",
+			fallback:   http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "stale") }),
+			method:     http.MethodPost,
+			accept:     "text/html",
+			wantStatus: http.StatusBadGateway,
+			wantBody:   "Vite dev server unavailable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := NewDevProxy(cfg, tt.fallback)
+			req := httptest.NewRequest(tt.method, "/dashboard", nil)
+			req.Header.Set("Accept", tt.accept)
+			rec := httptest.NewRecorder()
+			proxy.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantType != "" && rec.Header().Get("Content-Type") != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", rec.Header().Get("Content-Type"), tt.wantType)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body %q does not contain %q", rec.Body.String(), tt.wantBody)
+			}
+			if got := rec.Header().Get(DevFallbackHeader) != ""; got != tt.wantFallback {
+				t.Errorf("%s set = %v, want %v", DevFallbackHeader, got, tt.wantFallback)
+			}
+		})
+	}
+}
+
+func TestDevProxyOverlayEscapesPath(t *testing.T) {
+	proxy := NewDevProxy(&Config{DevHost: unreachableHost(t), DevRetryTimeout: time.Nanosecond}, nil)
+	req := httptest.NewRequest(http.MethodGet, "/%3Cscript%3Ealert(1)%3C/script%3E", nil)
+	req.Header.Set("Accept", "text/html")
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, req)
+
+	if strings.Contains(rec.Body.String(), "<script>alert(1)") {
+		t.Errorf("overlay contains the unescaped path: %s", rec.Body.String())
+	}
+}
+
+// TestDevProxyWebSocket checks that an HMR upgrade is tunneled: the
+// handshake reaches Vite and frames flow both ways unchanged.
+func TestDevProxyWebSocket(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isWebSocketUpgrade(r) || r.Header.Get("Sec-WebSocket-Protocol") != "vite-hmr" {
+			http.Error(w, "not an HMR upgrade", http.StatusBadRequest)
+			return
+		}
+		conn, buf, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+			"Upgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Protocol: vite-hmr\r\n\r\n")
+		buf.WriteString(`{"type":"connected"}`)
+		buf.Flush()
+		// Echo whatever the client sends.
+		io.Copy(conn, buf)
+	}))
+	defer upstream.Close()
+
+	proxy := NewDevProxy(&Config{DevHost: strings.TrimPrefix(upstream.URL, "http://")}, nil)
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	io.WriteString(conn, "GET /?token=abc HTTP/1.1\r\nHost: app.example.com\r\n"+
+		"Connection: keep-alive, Upgrade\r\nUpgrade: websocket\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n"+
+		"Sec-WebSocket-Protocol: vite-hmr\r\n\r\n")
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("read handshake: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want 101", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != "vite-hmr" {
+		t.Errorf("Sec-WebSocket-Protocol = %q, want vite-hmr", got)
+	}
+
+	expect := func(want string) {
+		t.Helper()
+		got := make([]byte, len(want))
+		if _, err := io.ReadFull(br, got); err != nil {
+			t.Fatalf("read %q: %v", want, err)
+		}
+		if string(got) != want {
+			t.Errorf("read %q, want %q", got, want)
+		}
+	}
+	expect(`{"type":"connected"}`)
+
+	io.WriteString(conn, "ping")
+	expect("ping")
+}
+
+func TestDevProxyWebSocketUpstreamDown(t *testing.T) {
+	proxy := NewDevProxy(&Config{DevHost: unreachableHost(t), DevRetryTimeout: time.Nanosecond}, nil)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want 502", rec.Code)
+	}
+}
+
+func TestIsWebSocketUpgrade(t *testing.T) {
+	tests := []struct {
+		connection, upgrade string
+		want                bool
+	}{
+		{"Upgrade", "websocket", true},
+		{"keep-alive, upgrade", "WebSocket", true},
+		{"keep-alive", "websocket", false},
+		{"Upgrade", "h2c", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.connection != "" {
+			r.Header.Set("Connection", tt.connection)
+		}
+		if tt.upgrade != "" {
+			r.Header.Set("Upgrade", tt.upgrade)
+		}
+		if got := isWebSocketUpgrade(r); got != tt.want {
+			t.Errorf("isWebSocketUpgrade(Connection=%q, Upgrade=%q) = %v, want %v",
+				tt.connection, tt.upgrade, got, tt.want)
+		}
+	}
+}
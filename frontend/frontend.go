@@ -4,155 +4,386 @@
 package frontend
 
 import (
+	"bytes"
 	"embed"
+	"html/template"
 	"io/fs"
 	"net/http"
-	"net/http/httputil"
-	"net/url"
-	"os"
-	"path/filepath"
+	"path"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/juanfont/juango/types"
 	"github.com/rs/zerolog/log"
 )
 
 const (
 	// DefaultDevHost is the default Vite dev server address.
 	DefaultDevHost = "localhost:5173"
+
+	// DefaultNotFoundFile is the page served with 404 responses, if the
+	// dist directory has one.
+	DefaultNotFoundFile = "404.html"
 )
 
+// BootstrapFunc returns the per-request data embedded in index.html, e.g. a
+// types.Bootstrap. An error is answered with a 500.
+type BootstrapFunc func(w http.ResponseWriter, r *http.Request) (any, error)
+
+// IndexData is the data index.html is executed with when Config.Bootstrap
+// is set. index.html is then an html/template, so Bootstrap is JSON-encoded
+// and escaped in the context it is used in:
+//
+//	<script type="application/json" id="bootstrap">{{.Bootstrap}}</script>
+type IndexData struct {
+	Bootstrap any
+	Nonce     string
+}
+
+// DefaultExcludedPrefixes are the paths never answered with the SPA.
+var DefaultExcludedPrefixes = []string{"/api/"}
+
 // Config holds the configuration for frontend serving.
 type Config struct {
+	// Mode selects dev or prod serving (default: ResolveMode("")).
+	Mode Mode
+
 	// DevHost is the address of the Vite dev server (default: localhost:5173).
 	DevHost string
 
+	// DevWaitTimeout is how long to wait for the dev server at startup
+	// (default: DefaultDevWaitTimeout).
+	DevWaitTimeout time.Duration
+
+	// DevRetryTimeout is how long a proxied request waits for the dev
+	// server (default: DefaultDevRetryTimeout).
+	DevRetryTimeout time.Duration
+
+	// DevFallback serves the embedded build from DistPath, however stale,
+	// while the dev server is unreachable. Startup then doesn't fail when
+	// the dev server is down.
+	DevFallback bool
+
 	// DistPath is the path to the embedded dist directory (e.g., "frontend/dist").
 	DistPath string
 
 	// IndexFile is the name of the index file (default: "index.html").
 	IndexFile string
+
+	// ExcludedPrefixes are path prefixes that get a plain 404 instead of
+	// the SPA, so that unknown API routes fail like API routes (default:
+	// DefaultExcludedPrefixes). Set an empty, non-nil slice to exclude
+	// nothing. A prefix ending in "/" also matches the path without it.
+	ExcludedPrefixes []string
+
+	// NotFoundFile is served with a 404 for missing files, i.e. paths with
+	// a file extension (default: DefaultNotFoundFile, if present).
+	NotFoundFile string
+
+	// Bootstrap, if set, renders index.html as a template with IndexData
+	// on every request. It isn't used in dev mode, where Vite serves
+	// index.html.
+	Bootstrap BootstrapFunc
+
+	// Meta, if set, rewrites the title and link preview meta tags of
+	// index.html for the routes it matches. Like Bootstrap, it isn't used
+	// in dev mode.
+	Meta *MetaRoutes
 }
 
 // DefaultConfig returns the default frontend configuration.
 func DefaultConfig() *Config {
 	return &Config{
-		DevHost:   DefaultDevHost,
-		DistPath:  "frontend/dist",
-		IndexFile: "index.html",
+		DevHost:          DefaultDevHost,
+		DistPath:         "frontend/dist",
+		IndexFile:        "index.html",
+		ExcludedPrefixes: DefaultExcludedPrefixes,
+		NotFoundFile:     DefaultNotFoundFile,
 	}
 }
 
 // Setup configures frontend serving on the given router.
-// In development mode (detected via IsDev()), it proxies requests to the Vite dev server.
+// In development mode (see ResolveMode), it proxies requests to the Vite dev server.
 // In production mode, it serves static files from the embedded filesystem.
-func Setup(router *mux.Router, frontend embed.FS, distPath string) {
-	SetupWithConfig(router, frontend, &Config{
-		DevHost:   DefaultDevHost,
-		DistPath:  distPath,
-		IndexFile: "index.html",
+func Setup(router *mux.Router, frontend embed.FS, distPath string) error {
+	return SetupWithConfig(router, frontend, &Config{
+		DistPath: distPath,
 	})
 }
 
 // SetupWithConfig configures frontend serving with custom configuration.
-func SetupWithConfig(router *mux.Router, frontend embed.FS, cfg *Config) {
-	if cfg.DevHost == "" {
-		cfg.DevHost = DefaultDevHost
-	}
-	if cfg.IndexFile == "" {
-		cfg.IndexFile = "index.html"
+// In dev mode it returns an error, rather than proxying, if the Vite dev
+// server doesn't accept connections within DevWaitTimeout and there is no
+// DevFallback.
+func SetupWithConfig(router *mux.Router, frontend embed.FS, cfg *Config) error {
+	cfg.setDefaults()
+	if cfg.Mode == "" {
+		mode, err := ResolveMode("")
+		if err != nil {
+			return err
+		}
+		cfg.Mode = mode
 	}
 
-	if IsDev() {
+	if cfg.Mode == ModeDev {
+		var fallback http.Handler
+		if cfg.DevFallback {
+			fallback = NewSPAHandlerWithConfig(frontend, cfg)
+		}
+
+		if err := waitForDevServer(cfg.DevHost, cfg.DevWaitTimeout); err != nil {
+			if fallback == nil {
+				return err
+			}
+			log.Warn().
+				Err(err).
+				Str("distPath", cfg.DistPath).
+				Msg("Vite dev server is down, serving the embedded build until it is up")
+		}
+
 		log.Info().
 			Str("devHost", cfg.DevHost).
 			Msg("Dev mode detected. Frontend is being proxied to Vite dev server")
 
-		proxy := httputil.NewSingleHostReverseProxy(&url.URL{
-			Scheme: "http",
-			Host:   cfg.DevHost,
-		})
-		router.PathPrefix("/").Handler(proxy)
+		proxy := NewDevProxy(cfg, fallback)
+		router.PathPrefix("/").Handler(excludePrefixes(cfg.ExcludedPrefixes, proxy))
 	} else {
 		log.Info().
 			Str("distPath", cfg.DistPath).
 			Msg("Production mode detected. Serving frontend from embedded filesystem")
 
-		handler := NewSPAHandler(frontend, cfg.DistPath, cfg.IndexFile)
+		handler := NewSPAHandlerWithConfig(frontend, cfg)
 		router.PathPrefix("/").Handler(handler)
 	}
-}
-
-// IsDev returns true when the application is running via `go run`.
-// It detects this by checking if the executable path contains "go-build",
-// which is the temporary directory used by `go run`.
-func IsDev() bool {
-	ex, err := os.Executable()
-	if err != nil {
-		return false
-	}
-	return strings.Contains(filepath.Dir(ex), "go-build")
+	return nil
 }
 
 // SPAHandler serves a Single Page Application from an embedded filesystem.
 // It serves static files when they exist and falls back to index.html for
 // client-side routing.
+//
+// Files are loaded in memory when the handler is created, with content-hash
+// ETags and gzip/brotli variants selected by Accept-Encoding. Hashed files
+// under assets/ are cached as immutable; index.html and other files are
+// revalidated on every use.
 type SPAHandler struct {
-	fs        embed.FS
-	distPath  string
-	indexFile string
+	assets       map[string]*asset
+	indexFile    string
+	notFoundFile string
+	excluded     []string
+
+	bootstrap     BootstrapFunc
+	indexTemplate *template.Template
+	meta          *MetaRoutes
 }
 
 // NewSPAHandler creates a new SPA handler.
 func NewSPAHandler(frontend embed.FS, distPath, indexFile string) *SPAHandler {
-	return &SPAHandler{
-		fs:        frontend,
-		distPath:  distPath,
-		indexFile: indexFile,
+	return NewSPAHandlerWithConfig(frontend, &Config{
+		DistPath:  distPath,
+		IndexFile: indexFile,
+	})
+}
+
+// NewSPAHandlerWithConfig creates a new SPA handler with custom
+// configuration.
+func NewSPAHandlerWithConfig(frontend embed.FS, cfg *Config) *SPAHandler {
+	cfg.setDefaults()
+	distPath := cfg.DistPath
+	h := &SPAHandler{
+		assets:       make(map[string]*asset),
+		indexFile:    cfg.IndexFile,
+		notFoundFile: cfg.NotFoundFile,
+		excluded:     cfg.ExcludedPrefixes,
+		bootstrap:    cfg.Bootstrap,
+		meta:         cfg.Meta,
 	}
+
+	sub, err := fs.Sub(frontend, distPath)
+	if err == nil {
+		h.assets, err = loadAssets(sub)
+	}
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("distPath", distPath).
+			Msg("Failed to load frontend assets")
+	}
+
+	if index, ok := h.assets[h.indexFile]; ok && h.bootstrap != nil {
+		h.indexTemplate, err = template.New(h.indexFile).Parse(string(index.data))
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("indexFile", h.indexFile).
+				Msg("Failed to parse index template, serving it without bootstrap data")
+		}
+	}
+
+	return h
 }
 
-// ServeHTTP implements http.Handler for serving the SPA.
+// ServeHTTP implements http.Handler for serving the SPA. Paths under the
+// excluded prefixes and missing files (paths with an extension) get a 404;
+// other unknown paths get index.html for client-side routing.
 func (h *SPAHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Get the absolute path to prevent directory traversal
-	path, err := filepath.Abs(r.URL.Path)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	clean := path.Clean("/" + r.URL.Path)
+	if isExcluded(h.excluded, clean) {
+		http.NotFound(w, r)
 		return
 	}
 
-	// Prepend the path with the static directory path
-	path = filepath.Join(h.distPath, path)
-
-	// Check if the file exists
-	_, err = h.fs.Open(path)
-	if os.IsNotExist(err) {
-		// File does not exist, serve index.html for SPA routing
+	name := strings.TrimPrefix(clean, "/")
+	a, ok := h.assets[name]
+	switch {
+	case !ok && path.Ext(name) != "":
+		h.serveNotFound(w, r)
+		return
+	case !ok || name == h.indexFile:
 		h.serveIndex(w, r)
 		return
-	} else if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+
+	cacheControl := cacheRevalidate
+	if strings.HasPrefix(name, hashedAssetsPath) {
+		cacheControl = cacheImmutable
+	}
+	a.serve(w, r, cacheControl)
+}
+
+// serveIndex serves the index.html file. If the request carries a CSP
+// nonce (see middleware.SecurityHeaders), it is added to every <script> tag.
+func (h *SPAHandler) serveIndex(w http.ResponseWriter, r *http.Request) {
+	index, ok := h.assets[h.indexFile]
+	if !ok {
+		http.Error(w, "index file not found", http.StatusInternalServerError)
 		return
 	}
 
-	// Serve the static file
-	sub, err := fs.Sub(h.fs, h.distPath)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	var meta *Meta
+	if h.meta != nil {
+		meta = h.meta.Resolve(r)
+	}
+
+	nonce := types.CSPNonceFromContext(r.Context())
+	if nonce == "" && h.indexTemplate == nil && meta == nil {
+		index.serve(w, r, cacheRevalidate)
 		return
 	}
-	http.FileServer(http.FS(sub)).ServeHTTP(w, r)
+
+	data := index.data
+	if h.indexTemplate != nil {
+		var err error
+		if data, err = h.renderIndex(w, r, nonce); err != nil {
+			types.WriteError(w, r, types.NewHTTPError(http.StatusInternalServerError, "Failed to render index", err))
+			return
+		}
+	}
+	if meta != nil {
+		data = rewriteHead(data, meta)
+	}
+	if nonce != "" {
+		data = injectNonce(data, nonce)
+	}
+
+	// The page differs on every request.
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", index.contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
 }
 
-// serveIndex serves the index.html file.
-func (h *SPAHandler) serveIndex(w http.ResponseWriter, r *http.Request) {
-	indexPath := filepath.Join(h.distPath, h.indexFile)
-	index, err := h.fs.ReadFile(indexPath)
+// renderIndex executes the index template with the request's bootstrap
+// data.
+func (h *SPAHandler) renderIndex(w http.ResponseWriter, r *http.Request, nonce string) ([]byte, error) {
+	bootstrap, err := h.bootstrap(w, r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := h.indexTemplate.Execute(&buf, IndexData{Bootstrap: bootstrap, Nonce: nonce}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// serveNotFound serves the custom 404 page, if any, with a 404 status.
+func (h *SPAHandler) serveNotFound(w http.ResponseWriter, r *http.Request) {
+	page, ok := h.assets[h.notFoundFile]
+	if !ok {
+		http.NotFound(w, r)
 		return
 	}
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	w.Write(index)
+
+	w.Header().Set("Cache-Control", cacheRevalidate)
+	w.Header().Set("Content-Type", page.contentType)
+	w.WriteHeader(http.StatusNotFound)
+	if r.Method != http.MethodHead {
+		w.Write(page.data)
+	}
+}
+
+func (cfg *Config) setDefaults() {
+	if cfg.DevHost == "" {
+		cfg.DevHost = DefaultDevHost
+	}
+	if cfg.DevWaitTimeout <= 0 {
+		cfg.DevWaitTimeout = DefaultDevWaitTimeout
+	}
+	if cfg.DevRetryTimeout <= 0 {
+		cfg.DevRetryTimeout = DefaultDevRetryTimeout
+	}
+	if cfg.IndexFile == "" {
+		cfg.IndexFile = "index.html"
+	}
+	if cfg.ExcludedPrefixes == nil {
+		cfg.ExcludedPrefixes = DefaultExcludedPrefixes
+	}
+	if cfg.NotFoundFile == "" {
+		cfg.NotFoundFile = DefaultNotFoundFile
+	}
+}
+
+// isExcluded reports whether the cleaned path p is under one of prefixes.
+func isExcluded(prefixes []string, p string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(p, prefix) || p == strings.TrimSuffix(prefix, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// excludePrefixes returns a 404 for paths under prefixes and passes other
+// requests to next.
+func excludePrefixes(prefixes []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isExcluded(prefixes, path.Clean("/"+r.URL.Path)) {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// injectNonce adds a nonce attribute to the <script> tags in html.
+func injectNonce(html []byte, nonce string) []byte {
+	attr := []byte(` nonce="` + nonce + `"`)
+	var out bytes.Buffer
+	for {
+		i := bytes.Index(html, []byte("<script"))
+		if i < 0 {
+			out.Write(html)
+			return out.Bytes()
+		}
+		end := i + len("<script")
+		out.Write(html[:end])
+		// Skip tags that merely start with "script", e.g. <scripts>.
+		if end < len(html) && (html[end] == '>' || html[end] == ' ' || html[end] == '\t' || html[end] == '\n' || html[end] == '\r' || html[end] == '/') {
+			out.Write(attr)
+		}
+		html = html[end:]
+	}
 }
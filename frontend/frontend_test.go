@@ -0,0 +1,221 @@
+package frontend
+
+import (
+	"embed"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/juanfont/juango/middleware"
+)
+
+//go:embed testdata
+var testFS embed.FS
+
+const testDist = "testdata/dist"
+
+var (
+	cspNonceRe    = regexp.MustCompile(`'nonce-([^']+)'`)
+	scriptNonceRe = regexp.MustCompile(`<script nonce="([^"]+)"`)
+)
+
+func TestServeIndexInjectsCSPNonce(t *testing.T) {
+	handler := middleware.SecurityHeaders(nil)(NewSPAHandler(testFS, testDist, "index.html"))
+
+	serve := func() (*httptest.ResponseRecorder, string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/deep/route", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status %d, want %d", rec.Code, http.StatusOK)
+		}
+
+		m := cspNonceRe.FindStringSubmatch(rec.Header().Get("Content-Security-Policy"))
+		if m == nil {
+			t.Fatalf("no nonce in CSP %q", rec.Header().Get("Content-Security-Policy"))
+		}
+		return rec, m[1]
+	}
+
+	rec, nonce := serve()
+	body := rec.Body.String()
+	scripts := scriptNonceRe.FindAllStringSubmatch(body, -1)
+	if len(scripts) != 1 || scripts[0][1] != nonce {
+		t.Errorf("script nonces = %v, want one matching the CSP nonce %q\n%s", scripts, nonce, body)
+	}
+	if !strings.Contains(body, "<scripts>not a script</scripts>") {
+		t.Errorf("non-script tag was modified:\n%s", body)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", got)
+	}
+
+	if _, other := serve(); other == nonce {
+		t.Errorf("nonce %q reused across requests", nonce)
+	}
+}
+
+func TestServeIndexWithoutNonce(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewSPAHandler(testFS, testDist, "index.html").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if strings.Contains(rec.Body.String(), "nonce=") {
+		t.Errorf("index has a nonce without SecurityHeaders:\n%s", rec.Body)
+	}
+}
+
+func TestServeIndexBootstrap(t *testing.T) {
+	newHandler := func(bootstrap BootstrapFunc) http.Handler {
+		return NewSPAHandlerWithConfig(testFS, &Config{
+			DistPath:  "testdata/bootstrap",
+			Bootstrap: bootstrap,
+		})
+	}
+
+	t.Run("rendered per request", func(t *testing.T) {
+		calls := 0
+		handler := newHandler(func(w http.ResponseWriter, r *http.Request) (any, error) {
+			calls++
+			return map[string]any{"user": "</script><script>alert(1)</script>", "path": r.URL.Path}, nil
+		})
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/settings", nil))
+
+		body := rec.Body.String()
+		if rec.Code != http.StatusOK || calls != 1 {
+			t.Fatalf("status %d after %d bootstrap calls, want 200 after 1", rec.Code, calls)
+		}
+		if !strings.Contains(body, `"path":"/settings"`) {
+			t.Errorf("bootstrap data missing:\n%s", body)
+		}
+		if strings.Contains(body, "<script>alert(1)") {
+			t.Errorf("bootstrap data can close the script tag:\n%s", body)
+		}
+		if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+			t.Errorf("Cache-Control = %q, want no-store", got)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		handler := newHandler(func(w http.ResponseWriter, r *http.Request) (any, error) {
+			return nil, errors.New("session store down")
+		})
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("status %d, want %d", rec.Code, http.StatusInternalServerError)
+		}
+		if strings.Contains(rec.Body.String(), "session store down") {
+			t.Errorf("internal error leaked: %s", rec.Body)
+		}
+	})
+}
+
+func TestInjectNonce(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{in: `<script>`, want: `<script nonce="n">`},
+		{in: `<script src="a.js"></script>`, want: `<script nonce="n" src="a.js"></script>`},
+		{in: "<script\ntype=module>", want: "<script nonce=\"n\"\ntype=module>"},
+		{in: `<script/>`, want: `<script nonce="n"/>`},
+		{in: `<scripts>`, want: `<scripts>`},
+		{in: `<noscript>`, want: `<noscript>`},
+		{in: `<script><script>`, want: `<script nonce="n"><script nonce="n">`},
+	}
+	for _, tt := range tests {
+		if got := string(injectNonce([]byte(tt.in), "n")); got != tt.want {
+			t.Errorf("injectNonce(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSPAHandlerNotFound(t *testing.T) {
+	index, err := testFS.ReadFile(testDist + "/index.html")
+	if err != nil {
+		t.Fatalf("reading index.html: %v", err)
+	}
+	notFound, err := testFS.ReadFile(testDist + "/404.html")
+	if err != nil {
+		t.Fatalf("reading 404.html: %v", err)
+	}
+
+	const (
+		plain404 = "404 page not found\n"
+		page404  = "404.html"
+		spaIndex = "index"
+	)
+	tests := []struct {
+		name     string
+		excluded []string
+		path     string
+		code     int
+		body     string
+	}{
+		{name: "API route", path: "/api/x", code: http.StatusNotFound, body: plain404},
+		{name: "API root", path: "/api", code: http.StatusNotFound, body: plain404},
+		{name: "API route with extension", path: "/api/export.csv", code: http.StatusNotFound, body: plain404},
+		{name: "missing file", path: "/missing.js", code: http.StatusNotFound, body: page404},
+		{name: "missing asset", path: "/assets/app-deadbeef.css", code: http.StatusNotFound, body: page404},
+		{name: "client route", path: "/deep/route", code: http.StatusOK, body: spaIndex},
+		{name: "root", path: "/", code: http.StatusOK, body: spaIndex},
+		{name: "prefix lookalike", path: "/apiary", code: http.StatusOK, body: spaIndex},
+		{name: "existing file", path: "/robots.txt", code: http.StatusOK},
+		{name: "nothing excluded", excluded: []string{}, path: "/api/x", code: http.StatusOK, body: spaIndex},
+		{name: "custom prefix", excluded: []string{"/auth/"}, path: "/auth/callback", code: http.StatusNotFound, body: plain404},
+		{name: "custom prefix leaves API", excluded: []string{"/auth/"}, path: "/api/x", code: http.StatusOK, body: spaIndex},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewSPAHandlerWithConfig(testFS, &Config{
+				DistPath:         testDist,
+				ExcludedPrefixes: tt.excluded,
+			})
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != tt.code {
+				t.Errorf("status %d, want %d", rec.Code, tt.code)
+			}
+			switch tt.body {
+			case spaIndex:
+				if rec.Body.String() != string(index) {
+					t.Errorf("body is not index.html:\n%s", rec.Body)
+				}
+			case page404:
+				if rec.Body.String() != string(notFound) {
+					t.Errorf("body is not 404.html:\n%s", rec.Body)
+				}
+			case plain404:
+				if rec.Body.String() != plain404 {
+					t.Errorf("body = %q, want a plain 404", rec.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestExcludePrefixes(t *testing.T) {
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	h := excludePrefixes(DefaultExcludedPrefixes, upstream)
+
+	for path, want := range map[string]int{
+		"/api/x":      http.StatusNotFound,
+		"/api/../api": http.StatusNotFound,
+		"/deep/route": http.StatusTeapot,
+		"/src/app.ts": http.StatusTeapot,
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: status %d, want %d", path, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package frontend
+
+import (
+	"bytes"
+	"html"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultMetaCacheTTL is how long resolved page metadata is cached.
+const DefaultMetaCacheTTL = 5 * time.Minute
+
+// maxMetaCacheEntries bounds the metadata cache; it is emptied when full.
+const maxMetaCacheEntries = 1024
+
+// Meta is the metadata of an SPA page, used by link previews (Open Graph
+// and Twitter cards) and the browser title.
+type Meta struct {
+	Title       string
+	Description string
+	// Image is the absolute URL of the preview image.
+	Image string
+	// URL is the canonical absolute URL of the page.
+	URL string
+}
+
+// MetaResolver returns the metadata of a page. vars holds the variables of
+// the matched route pattern. A nil Meta leaves index.html unchanged.
+type MetaResolver func(r *http.Request, vars map[string]string) (*Meta, error)
+
+// MetaRoutes maps SPA route patterns to metadata resolvers. Results are
+// cached per path, so resolvers must not depend on the requesting user;
+// link unfurlers are anonymous anyway.
+type MetaRoutes struct {
+	ttl    time.Duration
+	routes []metaRoute
+
+	mu    sync.Mutex
+	cache map[string]metaCacheEntry
+}
+
+type metaRoute struct {
+	route   *mux.Route
+	resolve MetaResolver
+}
+
+type metaCacheEntry struct {
+	meta    *Meta
+	expires time.Time
+}
+
+// NewMetaRoutes creates an empty set of metadata routes. A ttl <= 0 uses
+// DefaultMetaCacheTTL.
+func NewMetaRoutes(ttl time.Duration) *MetaRoutes {
+	if ttl <= 0 {
+		ttl = DefaultMetaCacheTTL
+	}
+	return &MetaRoutes{
+		ttl:   ttl,
+		cache: make(map[string]metaCacheEntry),
+	}
+}
+
+// Handle registers resolve for a gorilla/mux path pattern such as
+// "/items/{id}". Routes are tried in registration order. Register all
+// routes before serving requests.
+func (m *MetaRoutes) Handle(pattern string, resolve MetaResolver) {
+	m.routes = append(m.routes, metaRoute{
+		route:   new(mux.Router).Path(pattern),
+		resolve: resolve,
+	})
+}
+
+// Resolve returns the metadata for r's path, or nil if no route matches or
+// its resolver fails. Nil results and failures are cached like any other,
+// so a failing resolver runs at most once per path and TTL.
+func (m *MetaRoutes) Resolve(r *http.Request) *Meta {
+	key := path.Clean("/" + r.URL.Path)
+
+	m.mu.Lock()
+	entry, ok := m.cache[key]
+	m.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.meta
+	}
+
+	var match mux.RouteMatch
+	var resolve MetaResolver
+	for _, route := range m.routes {
+		if route.route.Match(r, &match) {
+			resolve = route.resolve
+			break
+		}
+	}
+	if resolve == nil {
+		return nil
+	}
+
+	meta, err := resolve(r, match.Vars)
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Str("path", r.URL.Path).
+			Msg("Failed to resolve page metadata")
+		meta = nil
+	}
+
+	m.mu.Lock()
+	if len(m.cache) >= maxMetaCacheEntries {
+		clear(m.cache)
+	}
+	m.cache[key] = metaCacheEntry{meta: meta, expires: time.Now().Add(m.ttl)}
+	m.mu.Unlock()
+
+	return meta
+}
+
+var (
+	titleTagRe = regexp.MustCompile(`(?is)<title[^>]*>.*?</title>`)
+	metaTagRe  = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	metaKeyRe  = regexp.MustCompile(`(?i)\b(?:name|property)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>/]+))`)
+	headEndRe  = regexp.MustCompile(`(?i)</head>`)
+)
+
+// metaTag is a meta tag set from a Meta field.
+type metaTag struct {
+	attr, key, value string
+}
+
+// rewriteHead returns a copy of page with the title and the description,
+// Open Graph and Twitter meta tags for the fields set in meta replaced.
+// Tags missing from page are added at the end of the head; all other tags
+// are kept.
+func rewriteHead(page []byte, meta *Meta) []byte {
+	var tags []metaTag
+	addTag := func(attr, key, value string) {
+		if value != "" {
+			tags = append(tags, metaTag{attr: attr, key: key, value: value})
+		}
+	}
+	addTag("name", "description", meta.Description)
+	addTag("property", "og:title", meta.Title)
+	addTag("property", "og:description", meta.Description)
+	addTag("property", "og:image", meta.Image)
+	addTag("property", "og:url", meta.URL)
+	if meta.Image != "" {
+		addTag("name", "twitter:card", "summary_large_image")
+	}
+	addTag("name", "twitter:title", meta.Title)
+	addTag("name", "twitter:description", meta.Description)
+	addTag("name", "twitter:image", meta.Image)
+
+	replaced := make(map[string]bool, len(tags))
+	out := metaTagRe.ReplaceAllFunc(page, func(tag []byte) []byte {
+		key := metaTagKey(tag)
+		for _, t := range tags {
+			if t.key != key {
+				continue
+			}
+			if replaced[key] {
+				return nil // drop duplicates of a replaced tag
+			}
+			replaced[key] = true
+			return t.html()
+		}
+		return tag
+	})
+
+	var missing bytes.Buffer
+	for _, t := range tags {
+		if !replaced[t.key] {
+			missing.Write(t.html())
+			missing.WriteByte('\n')
+		}
+	}
+	if meta.Title != "" {
+		title := "<title>" + html.EscapeString(meta.Title) + "</title>"
+		if loc := titleTagRe.FindIndex(out); loc != nil {
+			out = append(out[:loc[0]:loc[0]], append([]byte(title), out[loc[1]:]...)...)
+		} else {
+			missing.WriteString(title)
+		}
+	}
+
+	loc := headEndRe.FindIndex(out)
+	if loc == nil || missing.Len() == 0 {
+		return out
+	}
+	var buf bytes.Buffer
+	buf.Grow(len(out) + missing.Len())
+	buf.Write(out[:loc[0]])
+	buf.Write(missing.Bytes())
+	buf.Write(out[loc[0]:])
+	return buf.Bytes()
+}
+
+func (t metaTag) html() []byte {
+	return []byte(`<meta ` + t.attr + `="` + t.key + `" content="` + html.EscapeString(t.value) + `">`)
+}
+
+// metaTagKey returns the lowercased name or property of a meta tag.
+func metaTagKey(tag []byte) string {
+	m := metaKeyRe.FindSubmatch(tag)
+	if m == nil {
+		return ""
+	}
+	return strings.ToLower(string(bytes.Join(m[1:], nil)))
+}
@@ -0,0 +1,169 @@
+package frontend
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testIndex = `<!doctype html>
+<html>
+<head>
+<title>Site</title>
+<meta name="description" content="Static description">
+<meta property="og:type" content="website">
+<meta property="og:title" content="Site">
+<meta property="og:image" content="https://example.com/logo.png">
+<meta property="og:image:width" content="1200">
+<meta name="twitter:card" content="summary_large_image">
+<meta name="viewport" content="width=device-width">
+</head>
+<body></body>
+</html>`
+
+func TestRewriteHead(t *testing.T) {
+	tests := []struct {
+		name    string
+		meta    Meta
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "title only keeps other tags",
+			meta: Meta{Title: "Item <1>"},
+			want: []string{
+				`<title>Item &lt;1&gt;</title>`,
+				`<meta property="og:title" content="Item &lt;1&gt;">`,
+				`<meta name="twitter:title" content="Item &lt;1&gt;">`,
+				`<meta name="description" content="Static description">`,
+				`<meta property="og:image" content="https://example.com/logo.png">`,
+				`<meta property="og:image:width" content="1200">`,
+				`<meta property="og:type" content="website">`,
+				`<meta name="twitter:card" content="summary_large_image">`,
+				`<meta name="viewport" content="width=device-width">`,
+			},
+			notWant: []string{`content="Site"`, `<title>Site</title>`},
+		},
+		{
+			name: "all fields",
+			meta: Meta{
+				Title:       "Item",
+				Description: "An item",
+				Image:       "https://example.com/item.png",
+				URL:         "https://example.com/items/1",
+			},
+			want: []string{
+				`<meta name="description" content="An item">`,
+				`<meta property="og:description" content="An item">`,
+				`<meta property="og:image" content="https://example.com/item.png">`,
+				`<meta property="og:url" content="https://example.com/items/1">`,
+				`<meta name="twitter:image" content="https://example.com/item.png">`,
+				`<meta property="og:image:width" content="1200">`,
+			},
+			notWant: []string{`Static description`, `logo.png`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := string(rewriteHead([]byte(testIndex), &tt.meta))
+			for _, s := range tt.want {
+				if strings.Count(out, s) != 1 {
+					t.Errorf("want %s once in:\n%s", s, out)
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(out, s) {
+					t.Errorf("unexpected %s in:\n%s", s, out)
+				}
+			}
+			if head := out[:strings.Index(out, "</head>")]; !strings.Contains(head, `og:title`) {
+				t.Errorf("added tags outside the head:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestRewriteHeadEmptyMeta(t *testing.T) {
+	if out := string(rewriteHead([]byte(testIndex), &Meta{})); out != testIndex {
+		t.Errorf("empty Meta changed the page:\n%s", out)
+	}
+}
+
+func TestMetaRoutesResolveCaches(t *testing.T) {
+	tests := []struct {
+		name    string
+		resolve MetaResolver
+		want    *Meta
+	}{
+		{
+			name: "meta",
+			resolve: func(r *http.Request, vars map[string]string) (*Meta, error) {
+				return &Meta{Title: "Item " + vars["id"]}, nil
+			},
+			want: &Meta{Title: "Item 1"},
+		},
+		{
+			name: "nil",
+			resolve: func(r *http.Request, vars map[string]string) (*Meta, error) {
+				return nil, nil
+			},
+		},
+		{
+			name: "error",
+			resolve: func(r *http.Request, vars map[string]string) (*Meta, error) {
+				return &Meta{Title: "ignored"}, errors.New("database down")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			routes := NewMetaRoutes(time.Hour)
+			routes.Handle("/items/{id}", func(r *http.Request, vars map[string]string) (*Meta, error) {
+				calls++
+				return tt.resolve(r, vars)
+			})
+
+			for _, p := range []string{"/items/1", "/items/1", "/items//1/"} {
+				got := routes.Resolve(httptest.NewRequest(http.MethodGet, p, nil))
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Resolve(%s) = %+v, want %+v", p, got, tt.want)
+				}
+			}
+			if calls != 1 {
+				t.Errorf("resolver called %d times, want 1", calls)
+			}
+		})
+	}
+}
+
+func TestMetaRoutesResolveExpires(t *testing.T) {
+	calls := 0
+	routes := NewMetaRoutes(time.Millisecond)
+	routes.Handle("/items/{id}", func(r *http.Request, vars map[string]string) (*Meta, error) {
+		calls++
+		return nil, errors.New("database down")
+	})
+
+	routes.Resolve(httptest.NewRequest(http.MethodGet, "/items/1", nil))
+	time.Sleep(5 * time.Millisecond)
+	routes.Resolve(httptest.NewRequest(http.MethodGet, "/items/1", nil))
+	if calls != 2 {
+		t.Errorf("resolver called %d times after the TTL, want 2", calls)
+	}
+}
+
+func TestMetaRoutesResolveNoMatch(t *testing.T) {
+	routes := NewMetaRoutes(0)
+	routes.Handle("/items/{id}", func(r *http.Request, vars map[string]string) (*Meta, error) {
+		t.Error("resolver called for a path it doesn't match")
+		return nil, nil
+	})
+	if got := routes.Resolve(httptest.NewRequest(http.MethodGet, "/users/1", nil)); got != nil {
+		t.Errorf("Resolve() = %+v, want nil", got)
+	}
+}
@@ -0,0 +1,80 @@
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Mode selects how the frontend is served.
+type Mode string
+
+const (
+	// ModeProd serves the embedded build.
+	ModeProd Mode = "prod"
+	// ModeDev proxies to the Vite dev server.
+	ModeDev Mode = "dev"
+)
+
+// ModeEnv is the environment variable that selects the mode when the
+// configuration doesn't. `juango dev` sets it to "dev".
+const ModeEnv = "JUANGO_MODE"
+
+// DefaultDevWaitTimeout is how long dev mode waits for the Vite dev server
+// to accept connections at startup.
+const DefaultDevWaitTimeout = 30 * time.Second
+
+// ParseMode parses "dev"/"development" or "prod"/"production".
+func ParseMode(s string) (Mode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "dev", "development":
+		return ModeDev, nil
+	case "prod", "production":
+		return ModeProd, nil
+	}
+	return "", fmt.Errorf("invalid frontend mode %q, want %q or %q", s, ModeDev, ModeProd)
+}
+
+// ResolveMode returns the frontend mode from, in order: configured (e.g.
+// the frontend.mode config key), the JUANGO_MODE environment variable, and
+// the dev build tag. It defaults to ModeProd, so a binary never proxies to
+// a dev server unless asked to.
+func ResolveMode(configured string) (Mode, error) {
+	return resolveMode(configured, os.Getenv(ModeEnv), buildMode)
+}
+
+// resolveMode applies ResolveMode's precedence to explicit inputs.
+func resolveMode(configured, env string, build Mode) (Mode, error) {
+	if configured != "" {
+		return ParseMode(configured)
+	}
+	if env != "" {
+		mode, err := ParseMode(env)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", ModeEnv, err)
+		}
+		return mode, nil
+	}
+	if build != "" {
+		return build, nil
+	}
+	return ModeProd, nil
+}
+
+// IsDev reports whether the mode resolved from the environment and build
+// tags is ModeDev.
+func IsDev() bool {
+	mode, err := ResolveMode("")
+	return err == nil && mode == ModeDev
+}
+
+// waitForDevServer waits until host accepts TCP connections.
+func waitForDevServer(host string, timeout time.Duration) error {
+	conn, err := dialRetry(context.Background(), "tcp", host, timeout)
+	if err != nil {
+		return fmt.Errorf("vite dev server at %s is unreachable after %s: %w", host, timeout, err)
+	}
+	return conn.Close()
+}
@@ -0,0 +1,6 @@
+//go:build dev
+
+package frontend
+
+// buildMode is the mode selected by build tags.
+const buildMode = ModeDev
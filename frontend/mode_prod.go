@@ -0,0 +1,7 @@
+//go:build !dev
+
+package frontend
+
+// buildMode is the mode selected by build tags; without the dev tag the
+// environment or configuration decides.
+const buildMode Mode = ""
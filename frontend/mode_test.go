@@ -0,0 +1,51 @@
+package frontend
+
+import "testing"
+
+func TestResolveModePrecedence(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		env        string
+		build      Mode
+		want       Mode
+		wantErr    bool
+	}{
+		{name: "default", want: ModeProd},
+		{name: "build tag", build: ModeDev, want: ModeDev},
+		{name: "env over build tag", env: "prod", build: ModeDev, want: ModeProd},
+		{name: "env", env: "development", want: ModeDev},
+		{name: "config over env", configured: "prod", env: "dev", want: ModeProd},
+		{name: "config over build tag", configured: "production", build: ModeDev, want: ModeProd},
+		{name: "config", configured: "Dev", want: ModeDev},
+		{name: "invalid config", configured: "staging", env: "dev", wantErr: true},
+		{name: "invalid env", env: "staging", build: ModeDev, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveMode(tt.configured, tt.env, tt.build)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("resolveMode() = %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveMode() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("resolveMode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveModeReadsEnv(t *testing.T) {
+	t.Setenv(ModeEnv, "dev")
+	if got, err := ResolveMode(""); err != nil || got != ModeDev {
+		t.Errorf("ResolveMode(\"\") = %q, %v, want %q", got, err, ModeDev)
+	}
+	if got, err := ResolveMode("prod"); err != nil || got != ModeProd {
+		t.Errorf("ResolveMode(\"prod\") = %q, %v, want %q", got, err, ModeProd)
+	}
+}
@@ -0,0 +1,60 @@
+console.log("line 0 of the app bundle");
+console.log("line 1 of the app bundle");
+console.log("line 2 of the app bundle");
+console.log("line 3 of the app bundle");
+console.log("line 4 of the app bundle");
+console.log("line 5 of the app bundle");
+console.log("line 6 of the app bundle");
+console.log("line 7 of the app bundle");
+console.log("line 8 of the app bundle");
+console.log("line 9 of the app bundle");
+console.log("line 10 of the app bundle");
+console.log("line 11 of the app bundle");
+console.log("line 12 of the app bundle");
+console.log("line 13 of the app bundle");
+console.log("line 14 of the app bundle");
+console.log("line 15 of the app bundle");
+console.log("line 16 of the app bundle");
+console.log("line 17 of the app bundle");
+console.log("line 18 of the app bundle");
+console.log("line 19 of the app bundle");
+console.log("line 20 of the app bundle");
+console.log("line 21 of the app bundle");
+console.log("line 22 of the app bundle");
+console.log("line 23 of the app bundle");
+console.log("line 24 of the app bundle");
+console.log("line 25 of the app bundle");
+console.log("line 26 of the app bundle");
+console.log("line 27 of the app bundle");
+console.log("line 28 of the app bundle");
+console.log("line 29 of the app bundle");
+console.log("line 30 of the app bundle");
+console.log("line 31 of the app bundle");
+console.log("line 32 of the app bundle");
+console.log("line 33 of the app bundle");
+console.log("line 34 of the app bundle");
+console.log("line 35 of the app bundle");
+console.log("line 36 of the app bundle");
+console.log("line 37 of the app bundle");
+console.log("line 38 of the app bundle");
+console.log("line 39 of the app bundle");
+console.log("line 40 of the app bundle");
+console.log("line 41 of the app bundle");
+console.log("line 42 of the app bundle");
+console.log("line 43 of the app bundle");
+console.log("line 44 of the app bundle");
+console.log("line 45 of the app bundle");
+console.log("line 46 of the app bundle");
+console.log("line 47 of the app bundle");
+console.log("line 48 of the app bundle");
+console.log("line 49 of the app bundle");
+console.log("line 50 of the app bundle");
+console.log("line 51 of the app bundle");
+console.log("line 52 of the app bundle");
+console.log("line 53 of the app bundle");
+console.log("line 54 of the app bundle");
+console.log("line 55 of the app bundle");
+console.log("line 56 of the app bundle");
+console.log("line 57 of the app bundle");
+console.log("line 58 of the app bundle");
+console.log("line 59 of the app bundle");
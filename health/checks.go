@@ -0,0 +1,69 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/juanfont/juango/auth"
+	"github.com/juanfont/juango/database"
+	"github.com/juanfont/juango/tasks"
+)
+
+// SQLiteCheck pings the database and runs PRAGMA quick_check. It is
+// critical and cached for 30 seconds, as quick_check reads every page.
+func SQLiteCheck(db *database.Database) Check {
+	return Check{
+		Name:     "sqlite",
+		Critical: true,
+		CacheTTL: 30 * time.Second,
+		Func: func(ctx context.Context) error {
+			if err := db.DB().PingContext(ctx); err != nil {
+				return fmt.Errorf("ping: %w", err)
+			}
+			var result string
+			if err := db.GetContext(ctx, &result, "PRAGMA quick_check(1)"); err != nil {
+				return fmt.Errorf("quick_check: %w", err)
+			}
+			if result != "ok" {
+				return fmt.Errorf("quick_check: %s", result)
+			}
+			return nil
+		},
+	}
+}
+
+// RedisCheck checks that the task queue's Redis is reachable. It is not
+// critical: the service keeps serving requests while tasks can't be
+// enqueued.
+func RedisCheck(client *tasks.Client) Check {
+	return Check{
+		Name: "redis",
+		Func: func(ctx context.Context) error {
+			return client.Ping()
+		},
+	}
+}
+
+// OIDCCheck refetches the OIDC discovery document, at most once a minute.
+// Failures are degraded until the last successful fetch is older than
+// maxAge, then the check is down. It is not critical: existing sessions
+// keep working while the identity provider is unreachable, so the service
+// stays ready and only reports degraded.
+func OIDCCheck(provider *auth.OIDCProvider, maxAge time.Duration) Check {
+	return Check{
+		Name:     "oidc",
+		CacheTTL: time.Minute,
+		Func: func(ctx context.Context) error {
+			err := provider.RefreshDiscovery(ctx)
+			if err == nil {
+				return nil
+			}
+			age := time.Since(provider.DiscoveredAt())
+			if age < maxAge {
+				return Degraded(err)
+			}
+			return fmt.Errorf("discovery stale for %s: %w", age.Round(time.Second), err)
+		},
+	}
+}
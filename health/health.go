@@ -0,0 +1,249 @@
+// Package health provides liveness and readiness endpoints backed by a
+// registry of checks.
+//
+// Each check runs with its own timeout and its result is cached, so probes
+// hitting /healthz and /readyz frequently do not hammer dependencies. A
+// failing critical check makes the service not ready; failing non-critical
+// checks, and checks that return an error wrapped with Degraded, only
+// degrade it. Neither degraded state fails liveness.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status is the state of a check or of the service.
+type Status string
+
+const (
+	StatusUp       Status = "up"
+	StatusDegraded Status = "degraded"
+	StatusDown     Status = "down"
+)
+
+const (
+	// DefaultTimeout bounds a check run when Check.Timeout is zero.
+	DefaultTimeout = 5 * time.Second
+	// DefaultCacheTTL is how long a result is reused when Check.CacheTTL
+	// is zero.
+	DefaultCacheTTL = 10 * time.Second
+)
+
+// Check is a named health check.
+type Check struct {
+	Name string
+	Func func(ctx context.Context) error
+
+	// Timeout bounds each run (default: DefaultTimeout). A check that
+	// ignores its context is abandoned and reported as down once the
+	// timeout expires.
+	Timeout time.Duration
+	// CacheTTL is how long a result is reused (default: DefaultCacheTTL).
+	CacheTTL time.Duration
+	// Critical checks make the service down when they fail. Failures of
+	// other checks make it degraded.
+	Critical bool
+	// Liveness includes the check in the liveness report. Keep these to
+	// checks whose failure means the process must be restarted.
+	Liveness bool
+}
+
+// Degraded marks err as a degraded rather than a failed result, e.g. for a
+// dependency that is unreachable but still within its grace period.
+func Degraded(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &degradedError{err: err}
+}
+
+type degradedError struct {
+	err error
+}
+
+func (e *degradedError) Error() string { return e.err.Error() }
+func (e *degradedError) Unwrap() error { return e.err }
+
+// Result is the outcome of a check run.
+type Result struct {
+	Status     Status    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	Critical   bool      `json:"critical"`
+	DurationMS int64     `json:"duration_ms"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// Report is the aggregated result of a set of checks.
+type Report struct {
+	Status Status            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Checker is a registry of health checks. It is safe for concurrent use.
+type Checker struct {
+	mu     sync.RWMutex
+	checks map[string]*entry
+}
+
+type entry struct {
+	check Check
+
+	mu      sync.Mutex
+	result  Result
+	expires time.Time
+}
+
+// NewChecker creates an empty checker.
+func NewChecker() *Checker {
+	return &Checker{checks: make(map[string]*entry)}
+}
+
+// Register adds a check, replacing any check with the same name.
+func (c *Checker) Register(check Check) {
+	if check.Timeout <= 0 {
+		check.Timeout = DefaultTimeout
+	}
+	if check.CacheTTL <= 0 {
+		check.CacheTTL = DefaultCacheTTL
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[check.Name] = &entry{check: check}
+}
+
+// Liveness runs the liveness checks. With none registered the service is
+// reported up.
+func (c *Checker) Liveness(ctx context.Context) Report {
+	return c.run(ctx, true)
+}
+
+// Readiness runs all checks.
+func (c *Checker) Readiness(ctx context.Context) Report {
+	return c.run(ctx, false)
+}
+
+func (c *Checker) run(ctx context.Context, livenessOnly bool) Report {
+	c.mu.RLock()
+	entries := make([]*entry, 0, len(c.checks))
+	for _, e := range c.checks {
+		if !livenessOnly || e.check.Liveness {
+			entries = append(entries, e)
+		}
+	}
+	c.mu.RUnlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].check.Name < entries[j].check.Name })
+
+	results := make([]Result, len(entries))
+	var wg sync.WaitGroup
+	for i, e := range entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = e.get(ctx)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Checks: make(map[string]Result, len(entries))}
+	for i, e := range entries {
+		r := results[i]
+		report.Checks[e.check.Name] = r
+		switch {
+		case r.Status == StatusDown && r.Critical:
+			report.Status = StatusDown
+		case r.Status != StatusUp && report.Status == StatusUp:
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+// get returns the cached result or runs the check. Concurrent callers wait
+// for a single run.
+func (e *entry) get(ctx context.Context) Result {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(e.expires) {
+		return e.result
+	}
+
+	e.result = e.runCheck(ctx)
+	e.expires = e.result.CheckedAt.Add(e.check.CacheTTL)
+	return e.result
+}
+
+func (e *entry) runCheck(ctx context.Context) Result {
+	// Probes are short-lived requests; don't let a cancelled probe poison
+	// the cached result.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), e.check.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("check panicked: %v", p)
+			}
+		}()
+		done <- e.check.Func(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("check timed out after %s", e.check.Timeout)
+	}
+
+	result := Result{
+		Status:     StatusUp,
+		Critical:   e.check.Critical,
+		DurationMS: time.Since(start).Milliseconds(),
+		CheckedAt:  time.Now(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+		result.Status = StatusDown
+		var de *degradedError
+		if errors.As(err, &de) {
+			result.Status = StatusDegraded
+		}
+	}
+	return result
+}
+
+// LivenessHandler serves the liveness report (mount at /healthz). It
+// responds 503 only when a critical liveness check is down.
+func (c *Checker) LivenessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, c.Liveness(r.Context()))
+	}
+}
+
+// ReadinessHandler serves the readiness report (mount at /readyz). It
+// responds 503 when a critical check is down.
+func (c *Checker) ReadinessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, c.Readiness(r.Context()))
+	}
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status == StatusDown {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,166 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckCaching(t *testing.T) {
+	var runs atomic.Int32
+	c := NewChecker()
+	c.Register(Check{
+		Name:     "counted",
+		CacheTTL: 50 * time.Millisecond,
+		Func: func(context.Context) error {
+			runs.Add(1)
+			return nil
+		},
+	})
+
+	ctx := context.Background()
+	c.Readiness(ctx)
+	c.Readiness(ctx)
+	if got := runs.Load(); got != 1 {
+		t.Fatalf("runs within TTL = %d, want 1", got)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	c.Readiness(ctx)
+	if got := runs.Load(); got != 2 {
+		t.Errorf("runs after TTL = %d, want 2", got)
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	c := NewChecker()
+	c.Register(Check{
+		Name:     "slow",
+		Critical: true,
+		Timeout:  10 * time.Millisecond,
+		Func: func(context.Context) error {
+			// Ignores its context, so the checker has to abandon it.
+			time.Sleep(time.Second)
+			return nil
+		},
+	})
+
+	start := time.Now()
+	report := c.Readiness(context.Background())
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("readiness took %s, want the check abandoned after its timeout", elapsed)
+	}
+	if report.Status != StatusDown {
+		t.Errorf("status = %s, want %s", report.Status, StatusDown)
+	}
+	if r := report.Checks["slow"]; r.Status != StatusDown || r.Error == "" {
+		t.Errorf("check result = %+v, want down with an error", r)
+	}
+}
+
+func TestCheckPanic(t *testing.T) {
+	c := NewChecker()
+	c.Register(Check{Name: "panics", Func: func(context.Context) error { panic("boom") }})
+
+	if r := c.Readiness(context.Background()).Checks["panics"]; r.Status != StatusDown {
+		t.Errorf("check result = %+v, want down", r)
+	}
+}
+
+func TestAggregation(t *testing.T) {
+	errFailed := errors.New("failed")
+	up := func(context.Context) error { return nil }
+	down := func(context.Context) error { return errFailed }
+	degraded := func(context.Context) error { return Degraded(errFailed) }
+
+	tests := []struct {
+		name   string
+		checks []Check
+		want   Status
+		code   int
+	}{
+		{
+			name: "no checks",
+			want: StatusUp,
+			code: http.StatusOK,
+		},
+		{
+			name:   "all up",
+			checks: []Check{{Name: "a", Func: up, Critical: true}, {Name: "b", Func: up}},
+			want:   StatusUp,
+			code:   http.StatusOK,
+		},
+		{
+			name:   "critical down",
+			checks: []Check{{Name: "a", Func: down, Critical: true}, {Name: "b", Func: up}},
+			want:   StatusDown,
+			code:   http.StatusServiceUnavailable,
+		},
+		{
+			name:   "non-critical down",
+			checks: []Check{{Name: "a", Func: up, Critical: true}, {Name: "b", Func: down}},
+			want:   StatusDegraded,
+			code:   http.StatusOK,
+		},
+		{
+			name:   "critical degraded",
+			checks: []Check{{Name: "a", Func: degraded, Critical: true}},
+			want:   StatusDegraded,
+			code:   http.StatusOK,
+		},
+		{
+			name:   "critical down beats degraded",
+			checks: []Check{{Name: "a", Func: degraded}, {Name: "b", Func: down, Critical: true}},
+			want:   StatusDown,
+			code:   http.StatusServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChecker()
+			for _, check := range tt.checks {
+				c.Register(check)
+			}
+
+			rec := httptest.NewRecorder()
+			c.ReadinessHandler()(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if got := c.Readiness(context.Background()).Status; got != tt.want {
+				t.Errorf("status = %s, want %s", got, tt.want)
+			}
+			if rec.Code != tt.code {
+				t.Errorf("readyz code = %d, want %d", rec.Code, tt.code)
+			}
+		})
+	}
+}
+
+func TestLivenessOnlyRunsLivenessChecks(t *testing.T) {
+	c := NewChecker()
+	c.Register(Check{Name: "db", Critical: true, Func: func(context.Context) error { return errors.New("down") }})
+	c.Register(Check{Name: "process", Critical: true, Liveness: true, Func: func(context.Context) error { return nil }})
+
+	report := c.Liveness(context.Background())
+	if report.Status != StatusUp {
+		t.Errorf("liveness status = %s, want %s", report.Status, StatusUp)
+	}
+	if _, ok := report.Checks["db"]; ok {
+		t.Errorf("liveness ran a readiness-only check")
+	}
+
+	rec := httptest.NewRecorder()
+	c.LivenessHandler()(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("healthz code = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestOIDCCheckIsNotCritical(t *testing.T) {
+	if OIDCCheck(nil, time.Hour).Critical {
+		t.Errorf("OIDCCheck is critical; an identity provider outage must only degrade readiness")
+	}
+}
@@ -0,0 +1,207 @@
+// Package httpjson adapts typed functions into JSON HTTP handlers. The
+// adapter decodes and validates the request body, encodes the response, and
+// reports failures as RFC 9457 problem details (types.Problem), so that
+// handlers only contain their own logic.
+package httpjson
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/juanfont/juango/types"
+)
+
+// DefaultMaxBodySize is the default limit on request bodies.
+const DefaultMaxBodySize = 1 << 20
+
+// Empty is the request type of handlers without a body, and the response
+// type of handlers replying 204 No Content.
+type Empty struct{}
+
+// HandlerFunc handles a decoded and validated request. An error is written
+// with types.WriteError, so return a types.HTTPError or a common error such
+// as types.ErrNotFound to choose the status and detail. w is available for
+// cookies, sessions and headers, but the response body belongs to the
+// adapter.
+type HandlerFunc[Req, Resp any] func(w http.ResponseWriter, r *http.Request, req Req) (Resp, error)
+
+// Config configures a handler.
+type Config struct {
+	// MaxBodySize limits the request body in bytes (default:
+	// DefaultMaxBodySize).
+	MaxBodySize int64
+
+	// AllowUnknownFields accepts request fields that Req doesn't declare.
+	AllowUnknownFields bool
+
+	// Status is the status of successful responses (default: 200, or 204
+	// for Empty responses).
+	Status int
+}
+
+// Handle returns an http.HandlerFunc that decodes the JSON body into Req,
+// validates it and encodes fn's result as JSON. It panics if Req has
+// invalid validate tags (see CheckTags), like http.ServeMux does for
+// conflicting patterns, so create handlers when registering routes.
+func Handle[Req, Resp any](fn HandlerFunc[Req, Resp]) http.HandlerFunc {
+	return HandleWithConfig(Config{}, fn)
+}
+
+// HandleWithConfig is like Handle with a custom configuration.
+//
+// The body is rejected with a 400 problem if it is malformed, has fields
+// Req doesn't declare or fails validation (see Validate), with a 413 if it
+// exceeds the size limit, and with a 415 if its Content-Type isn't JSON.
+// The body is ignored when Req is Empty.
+func HandleWithConfig[Req, Resp any](cfg Config, fn HandlerFunc[Req, Resp]) http.HandlerFunc {
+	if err := CheckTags(new(Req)); err != nil {
+		panic(err.Error())
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = DefaultMaxBodySize
+	}
+	_, emptyReq := any(*new(Req)).(Empty)
+	_, emptyResp := any(*new(Resp)).(Empty)
+	status := cfg.Status
+	if status == 0 {
+		status = http.StatusOK
+		if emptyResp {
+			status = http.StatusNoContent
+		}
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Req
+		if !emptyReq {
+			if err := Decode(w, r, &req, cfg); err != nil {
+				types.WriteError(w, r, err)
+				return
+			}
+		}
+
+		resp, err := fn(w, r, req)
+		if err != nil {
+			types.WriteError(w, r, err)
+			return
+		}
+
+		if emptyResp {
+			w.WriteHeader(status)
+			return
+		}
+		Write(w, status, resp)
+	}
+}
+
+// Decode reads the JSON body of r into v, a pointer to a struct, and
+// validates it. Errors are types.HTTPError values describing the problem to
+// the client.
+func Decode(w http.ResponseWriter, r *http.Request, v any, cfg Config) error {
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = DefaultMaxBodySize
+	}
+
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+			return types.NewHTTPError(http.StatusUnsupportedMediaType, "Content-Type must be application/json", err)
+		}
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, cfg.MaxBodySize))
+	if !cfg.AllowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err)
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		return types.NewHTTPError(http.StatusBadRequest, "Request body must contain a single JSON value", err)
+	}
+
+	return Validate(v)
+}
+
+// decodeError converts a json decoding error into an HTTPError.
+func decodeError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return types.NewHTTPError(http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("Request body must not exceed %d bytes", maxBytesErr.Limit), err)
+	case errors.Is(err, io.EOF):
+		return types.NewHTTPError(http.StatusBadRequest, "Request body is required", err)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return types.NewHTTPError(http.StatusBadRequest, "Request body is truncated", err)
+	case errors.As(err, &syntaxErr):
+		return types.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("Malformed JSON at offset %d", syntaxErr.Offset), err)
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return types.NewHTTPError(http.StatusBadRequest,
+				fmt.Sprintf("Request body must be a JSON %s", jsonKind(typeErr.Type)), err)
+		}
+		herr := types.NewValidationError(types.FieldError{
+			Field:   typeErr.Field,
+			Message: "must be " + article(jsonKind(typeErr.Type)),
+		})
+		herr.Err = err
+		return herr
+	}
+
+	// DisallowUnknownFields has no error type; the message is
+	// `json: unknown field "name"`.
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		herr := types.NewValidationError(types.FieldError{
+			Field:   strings.Trim(name, `"`),
+			Message: "is not allowed",
+		})
+		herr.Err = err
+		return herr
+	}
+	return types.NewHTTPError(http.StatusBadRequest, "Invalid request body", err)
+}
+
+// jsonKind names the JSON type a Go type is decoded from.
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	case reflect.Pointer:
+		return jsonKind(t.Elem())
+	}
+	return "value"
+}
+
+func article(kind string) string {
+	if strings.IndexByte("aeiou", kind[0]) >= 0 {
+		return "an " + kind
+	}
+	return "a " + kind
+}
+
+// Write encodes v as a JSON response with the given status.
+func Write(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,439 @@
+package httpjson
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/juanfont/juango/types"
+)
+
+type testAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type testRequest struct {
+	Name    string        `json:"name" validate:"trim,required,max=10"`
+	Email   string        `json:"email,omitempty" validate:"email"`
+	ID      string        `json:"id,omitempty" validate:"uuid"`
+	Role    string        `json:"role,omitempty" validate:"oneof=admin user"`
+	Age     int           `json:"age,omitempty" validate:"min=18,max=130"`
+	Tags    []string      `json:"tags,omitempty" validate:"max=2"`
+	Address *testAddress  `json:"address,omitempty"`
+	Others  []testAddress `json:"others,omitempty"`
+}
+
+type testResponse struct {
+	Greeting string `json:"greeting"`
+}
+
+func greet(w http.ResponseWriter, r *http.Request, req testRequest) (testResponse, error) {
+	return testResponse{Greeting: "hello " + req.Name}, nil
+}
+
+// serve sends body to h and decodes the response.
+func serve(t *testing.T, h http.Handler, contentType, body string) (*httptest.ResponseRecorder, types.Problem) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var errResp types.Problem
+	if rec.Code >= 400 {
+		if ct := rec.Header().Get("Content-Type"); ct != types.ProblemContentType {
+			t.Errorf("error Content-Type = %q, want %s", ct, types.ProblemContentType)
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &errResp); err != nil {
+			t.Fatalf("error body %q isn't a Problem: %v", rec.Body, err)
+		}
+		if errResp.Status != rec.Code {
+			t.Errorf("problem status %d, want %d", errResp.Status, rec.Code)
+		}
+	}
+	return rec, errResp
+}
+
+func TestHandleDecoding(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		cfg         Config
+		wantStatus  int
+		wantCode    string
+		wantField   string
+	}{
+		{name: "ok", contentType: "application/json", body: `{"name":"ann"}`, wantStatus: http.StatusOK},
+		{name: "json suffix", contentType: "application/merge-patch+json", body: `{"name":"ann"}`, wantStatus: http.StatusOK},
+		{name: "charset", contentType: "application/json; charset=utf-8", body: `{"name":"ann"}`, wantStatus: http.StatusOK},
+		{name: "no content type", body: `{"name":"ann"}`, wantStatus: http.StatusOK},
+		{name: "wrong content type", contentType: "text/plain", body: `{"name":"ann"}`,
+			wantStatus: http.StatusUnsupportedMediaType, wantCode: "unsupported_media_type"},
+		{name: "form", contentType: "application/x-www-form-urlencoded", body: `name=ann`,
+			wantStatus: http.StatusUnsupportedMediaType, wantCode: "unsupported_media_type"},
+		{name: "malformed", contentType: "application/json", body: `{"name":}`,
+			wantStatus: http.StatusBadRequest, wantCode: "bad_request"},
+		{name: "truncated", contentType: "application/json", body: `{"name":"ann"`,
+			wantStatus: http.StatusBadRequest, wantCode: "bad_request"},
+		{name: "empty", contentType: "application/json", body: ``,
+			wantStatus: http.StatusBadRequest, wantCode: "bad_request"},
+		{name: "not an object", contentType: "application/json", body: `["ann"]`,
+			wantStatus: http.StatusBadRequest, wantCode: "bad_request"},
+		{name: "trailing value", contentType: "application/json", body: `{"name":"ann"} {"name":"bob"}`,
+			wantStatus: http.StatusBadRequest, wantCode: "bad_request"},
+		{name: "trailing garbage", contentType: "application/json", body: `{"name":"ann"}x`,
+			wantStatus: http.StatusBadRequest, wantCode: "bad_request"},
+		{name: "trailing whitespace", contentType: "application/json", body: "{\"name\":\"ann\"}\n\t ",
+			wantStatus: http.StatusOK},
+		{name: "unknown field", contentType: "application/json", body: `{"name":"ann","admin":true}`,
+			wantStatus: http.StatusBadRequest, wantCode: types.ErrorCodeValidationFailed, wantField: "admin"},
+		{name: "unknown field allowed", contentType: "application/json", body: `{"name":"ann","admin":true}`,
+			cfg: Config{AllowUnknownFields: true}, wantStatus: http.StatusOK},
+		{name: "wrong field type", contentType: "application/json", body: `{"name":"ann","age":"old"}`,
+			wantStatus: http.StatusBadRequest, wantCode: types.ErrorCodeValidationFailed, wantField: "age"},
+		{name: "too large", contentType: "application/json", body: `{"name":"` + strings.Repeat("a", 64) + `"}`,
+			cfg: Config{MaxBodySize: 32}, wantStatus: http.StatusRequestEntityTooLarge, wantCode: "request_entity_too_large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, errResp := serve(t, HandleWithConfig(tt.cfg, greet), tt.contentType, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus == http.StatusOK {
+				var resp testResponse
+				if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Greeting != "hello ann" {
+					t.Errorf("response %q, want a greeting for ann", rec.Body)
+				}
+				return
+			}
+			if errResp.Code != tt.wantCode {
+				t.Errorf("code %q, want %q", errResp.Code, tt.wantCode)
+			}
+			if tt.wantField != "" && (len(errResp.Fields) != 1 || errResp.Fields[0].Field != tt.wantField) {
+				t.Errorf("fields %+v, want one for %q", errResp.Fields, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestHandleEmpty(t *testing.T) {
+	called := false
+	h := Handle(func(w http.ResponseWriter, r *http.Request, _ Empty) (Empty, error) {
+		called = true
+		return Empty{}, nil
+	})
+
+	// The body of Empty requests is ignored, whatever its type.
+	rec, _ := serve(t, h, "text/plain", "not json")
+	if rec.Code != http.StatusNoContent || !called {
+		t.Errorf("status %d, called %v, want 204 and called", rec.Code, called)
+	}
+	if rec.Body.Len() != 0 || rec.Header().Get("Content-Type") != "" {
+		t.Errorf("204 has a body %q or Content-Type %q", rec.Body, rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestHandleStatus(t *testing.T) {
+	h := HandleWithConfig(Config{Status: http.StatusCreated}, greet)
+	rec, _ := serve(t, h, "application/json", `{"name":"ann"}`)
+	if rec.Code != http.StatusCreated {
+		t.Errorf("status %d, want 201", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+}
+
+func TestHandleErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		wantMsg    string
+	}{
+		{
+			name:       "http error",
+			err:        types.NewHTTPError(http.StatusConflict, "Name taken", errors.New("unique constraint")),
+			wantStatus: http.StatusConflict,
+			wantCode:   "conflict",
+			wantMsg:    "Name taken",
+		},
+		{
+			name:       "validation error",
+			err:        types.NewValidationError(types.FieldError{Field: "name", Message: "is taken"}),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   types.ErrorCodeValidationFailed,
+			wantMsg:    "Invalid request",
+		},
+		{
+			name:       "plain error",
+			err:        errors.New("connection refused"),
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   "internal_server_error",
+			wantMsg:    "internal server error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := Handle(func(w http.ResponseWriter, r *http.Request, req testRequest) (testResponse, error) {
+				return testResponse{}, tt.err
+			})
+			rec, errResp := serve(t, h, "application/json", `{"name":"ann"}`)
+			if rec.Code != tt.wantStatus || errResp.Code != tt.wantCode || errResp.Detail != tt.wantMsg {
+				t.Errorf("got %d %+v, want %d %s %q", rec.Code, errResp, tt.wantStatus, tt.wantCode, tt.wantMsg)
+			}
+			if strings.Contains(rec.Body.String(), "connection refused") || strings.Contains(rec.Body.String(), "unique constraint") {
+				t.Errorf("internal error leaked: %s", rec.Body)
+			}
+		})
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	valid := func() testRequest { return testRequest{Name: "ann"} }
+
+	tests := []struct {
+		name       string
+		modify     func(*testRequest)
+		wantFields []types.FieldError
+	}{
+		{name: "valid", modify: func(*testRequest) {}},
+		{name: "all valid", modify: func(r *testRequest) {
+			r.Email = "ann@example.com"
+			r.ID = "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+			r.Role = "admin"
+			r.Age = 18
+			r.Tags = []string{"a", "b"}
+			r.Address = &testAddress{City: "Madrid"}
+		}},
+		{name: "required", modify: func(r *testRequest) { r.Name = "" },
+			wantFields: []types.FieldError{{Field: "name", Message: "is required"}}},
+		{name: "required blank", modify: func(r *testRequest) { r.Name = " \t" },
+			wantFields: []types.FieldError{{Field: "name", Message: "is required"}}},
+		{name: "max string", modify: func(r *testRequest) { r.Name = "abcdefghijk" },
+			wantFields: []types.FieldError{{Field: "name", Message: "must be at most 10 characters"}}},
+		{name: "max counts runes", modify: func(r *testRequest) { r.Name = "ñññññññññ" }},
+		{name: "trim before max", modify: func(r *testRequest) { r.Name = "   ann   " }},
+		{name: "email", modify: func(r *testRequest) { r.Email = "ann" },
+			wantFields: []types.FieldError{{Field: "email", Message: "must be a valid email address"}}},
+		{name: "email with name", modify: func(r *testRequest) { r.Email = "Ann <ann@example.com>" },
+			wantFields: []types.FieldError{{Field: "email", Message: "must be a valid email address"}}},
+		{name: "uuid", modify: func(r *testRequest) { r.ID = "123" },
+			wantFields: []types.FieldError{{Field: "id", Message: "must be a valid UUID"}}},
+		{name: "oneof", modify: func(r *testRequest) { r.Role = "root" },
+			wantFields: []types.FieldError{{Field: "role", Message: "must be one of: admin, user"}}},
+		{name: "min number", modify: func(r *testRequest) { r.Age = 17 },
+			wantFields: []types.FieldError{{Field: "age", Message: "must be at least 18"}}},
+		{name: "max number", modify: func(r *testRequest) { r.Age = 131 },
+			wantFields: []types.FieldError{{Field: "age", Message: "must be at most 130"}}},
+		{name: "zero skips min", modify: func(r *testRequest) { r.Age = 0 }},
+		{name: "max items", modify: func(r *testRequest) { r.Tags = []string{"a", "b", "c"} },
+			wantFields: []types.FieldError{{Field: "tags", Message: "must be at most 2 items"}}},
+		{name: "nested pointer", modify: func(r *testRequest) { r.Address = &testAddress{} },
+			wantFields: []types.FieldError{{Field: "address.city", Message: "is required"}}},
+		{name: "nested slice", modify: func(r *testRequest) { r.Others = []testAddress{{City: "Oslo"}, {}} },
+			wantFields: []types.FieldError{{Field: "others[1].city", Message: "is required"}}},
+		{name: "several fields", modify: func(r *testRequest) { r.Name = ""; r.Age = 1 },
+			wantFields: []types.FieldError{
+				{Field: "name", Message: "is required"},
+				{Field: "age", Message: "must be at least 18"},
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid()
+			tt.modify(&req)
+			err := Validate(&req)
+
+			if tt.wantFields == nil {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			var herr types.HTTPError
+			if !errors.As(err, &herr) || herr.Status() != http.StatusBadRequest {
+				t.Fatalf("Validate() = %v, want a 400 HTTPError", err)
+			}
+			if !reflect.DeepEqual(herr.Fields, tt.wantFields) {
+				t.Errorf("fields = %+v, want %+v", herr.Fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestValidateTrims(t *testing.T) {
+	req := testRequest{Name: "  ann \n"}
+	if err := Validate(&req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Name != "ann" {
+		t.Errorf("Name = %q, want it trimmed", req.Name)
+	}
+
+	// Unaddressable values are checked trimmed but can't be changed.
+	if err := Validate(testRequest{Name: "    ann    "}); err != nil {
+		t.Errorf("Validate(value) = %v, want nil", err)
+	}
+}
+
+type testValidator struct {
+	Start int `json:"start"`
+	End   int `json:"end" validate:"required"`
+	err   error
+}
+
+func (v testValidator) Validate() error {
+	if v.err != nil {
+		return v.err
+	}
+	if v.End < v.Start {
+		return errors.New("end must not be before start")
+	}
+	return nil
+}
+
+func TestValidateValidator(t *testing.T) {
+	tests := []struct {
+		name       string
+		v          testValidator
+		wantStatus int
+		wantMsg    string
+	}{
+		{name: "ok", v: testValidator{Start: 1, End: 2}},
+		{name: "plain error", v: testValidator{Start: 2, End: 1},
+			wantStatus: http.StatusBadRequest, wantMsg: "end must not be before start"},
+		{name: "http error", v: testValidator{End: 1, err: types.NewHTTPError(http.StatusConflict, "Overlaps", nil)},
+			wantStatus: http.StatusConflict, wantMsg: "Overlaps"},
+		{name: "tags first", v: testValidator{Start: 2, err: errors.New("not reached")},
+			wantStatus: http.StatusBadRequest, wantMsg: "Invalid request"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(&tt.v)
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			var herr types.HTTPError
+			if !errors.As(err, &herr) || herr.Status() != tt.wantStatus || herr.Msg != tt.wantMsg {
+				t.Errorf("Validate() = %v, want %d %q", err, tt.wantStatus, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckTags(t *testing.T) {
+	type recursive struct {
+		Name     string       `json:"name" validate:"required"`
+		Children []*recursive `json:"children"`
+	}
+
+	tests := []struct {
+		name    string
+		v       any
+		wantErr string
+	}{
+		{name: "valid", v: testRequest{}},
+		{name: "recursive", v: recursive{}},
+		{name: "no struct", v: 42},
+		{name: "unknown rule", v: struct {
+			A string `json:"a" validate:"required,url"`
+		}{}, wantErr: `field a: unknown validation rule "url"`},
+		{name: "empty rule", v: struct {
+			A string `validate:"required,"`
+		}{}, wantErr: `field A: unknown validation rule ""`},
+		{name: "min without number", v: struct {
+			A string `json:"a" validate:"min"`
+		}{}, wantErr: "min needs a number"},
+		{name: "max not a number", v: struct {
+			A string `json:"a" validate:"max=ten"`
+		}{}, wantErr: `max needs a number, got "ten"`},
+		{name: "min on bool", v: struct {
+			A bool `json:"a" validate:"min=1"`
+		}{}, wantErr: "min doesn't apply to bool"},
+		{name: "trim on pointer", v: struct {
+			A *string `json:"a" validate:"trim"`
+		}{}, wantErr: "trim needs a string"},
+		{name: "email on int", v: struct {
+			A int `json:"a" validate:"email"`
+		}{}, wantErr: "email needs a string"},
+		{name: "uuid on pointer to string", v: struct {
+			A *string `json:"a" validate:"uuid"`
+		}{}},
+		{name: "empty oneof", v: struct {
+			A string `json:"a" validate:"oneof="`
+		}{}, wantErr: "oneof needs at least one value"},
+		{name: "nested", v: struct {
+			Items []struct {
+				A string `json:"a" validate:"requird"`
+			} `json:"items"`
+		}{}, wantErr: `field items.a: unknown validation rule "requird"`},
+		{name: "embedded", v: struct {
+			testAddress
+			Extra struct {
+				B int `json:"b" validate:"max"`
+			} `json:"extra"`
+		}{}, wantErr: "field extra.b: max needs a number"},
+		{name: "skipped field", v: struct {
+			A string `json:"-" validate:"nonsense"`
+		}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckTags(tt.v)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("CheckTags() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CheckTags() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+type badTags struct {
+	Name string `json:"name" validate:"required,lenght=3"`
+}
+
+func TestHandlePanicsOnInvalidTags(t *testing.T) {
+	defer func() {
+		r := recover()
+		if r == nil || !strings.Contains(r.(string), "lenght=3") {
+			t.Errorf("recover() = %v, want a panic naming the rule", r)
+		}
+	}()
+	Handle(func(w http.ResponseWriter, r *http.Request, req badTags) (Empty, error) {
+		return Empty{}, nil
+	})
+}
+
+func TestValidateInvalidTags(t *testing.T) {
+	err := Validate(&badTags{Name: "ann"})
+	var herr types.HTTPError
+	if !errors.As(err, &herr) || herr.Status() != http.StatusInternalServerError {
+		t.Errorf("Validate() = %v, want a 500", err)
+	}
+}
+
+func TestJuangoRequestTags(t *testing.T) {
+	for _, v := range []any{types.AdminModeRequest{}, types.ImpersonationStartRequest{}} {
+		if err := CheckTags(v); err != nil {
+			t.Errorf("CheckTags(%T) = %v", v, err)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package httpjson
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/juanfont/juango/types"
+)
+
+// Validator is implemented by request types with checks that struct tags
+// can't express. Validate runs after the tag rules pass; a types.HTTPError
+// is returned as is, other errors become a 400 with their message.
+type Validator interface {
+	Validate() error
+}
+
+// Validate checks the `validate` struct tags of v, a pointer to a struct,
+// then calls its Validator method. Rules are comma-separated:
+//
+//	required   the value is non-zero; strings must not be blank
+//	trim       trims surrounding whitespace from a string before checking it
+//	min=N      minimum string length, number or collection length
+//	max=N      maximum string length, number or collection length
+//	email      a valid email address
+//	uuid       a valid UUID
+//	oneof=a b  one of the space-separated values
+//
+// Rules other than required skip zero values. Nested structs, pointers to
+// structs and slices of structs are validated too. Field errors are named
+// by JSON path, e.g. "items[0].name". If the tags of v's type are invalid
+// (see CheckTags), Validate returns a 500 without checking anything.
+func Validate(v any) error {
+	if err := checkTagsCached(reflect.TypeOf(v)); err != nil {
+		return types.NewHTTPError(http.StatusInternalServerError, "Failed to validate request", err)
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+
+	var fields []types.FieldError
+	if rv.Kind() == reflect.Struct {
+		validateStruct(rv, "", &fields)
+	}
+	if len(fields) > 0 {
+		return types.NewValidationError(fields...)
+	}
+
+	if validator, ok := v.(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			var herr types.HTTPError
+			if errors.As(err, &herr) {
+				return herr
+			}
+			return types.NewHTTPError(http.StatusBadRequest, err.Error(), err)
+		}
+	}
+	return nil
+}
+
+func validateStruct(rv reflect.Value, prefix string, fields *[]types.FieldError) {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		fv := rv.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if sf.Anonymous && name == "" {
+			if fv.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				validateStruct(fv, prefix, fields)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		path := prefix + name
+
+		if tag := sf.Tag.Get("validate"); tag != "" {
+			if msg := validateField(fv, tag); msg != "" {
+				*fields = append(*fields, types.FieldError{Field: path, Message: msg})
+				continue
+			}
+		}
+		validateNested(fv, path, fields)
+	}
+}
+
+// validateNested descends into struct values held by a field.
+func validateNested(fv reflect.Value, path string, fields *[]types.FieldError) {
+	switch fv.Kind() {
+	case reflect.Pointer:
+		if !fv.IsNil() {
+			validateNested(fv.Elem(), path, fields)
+		}
+	case reflect.Struct:
+		validateStruct(fv, path+".", fields)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < fv.Len(); i++ {
+			validateNested(fv.Index(i), fmt.Sprintf("%s[%d]", path, i), fields)
+		}
+	}
+}
+
+// validateField applies the rules in tag to fv and returns the message of
+// the first failing rule, or "".
+func validateField(fv reflect.Value, tag string) string {
+	rules := strings.Split(tag, ",")
+
+	for _, rule := range rules {
+		if rule == "trim" {
+			trimmed := strings.TrimSpace(fv.String())
+			if fv.CanSet() {
+				fv.SetString(trimmed)
+			} else {
+				fv = reflect.ValueOf(trimmed).Convert(fv.Type())
+			}
+		}
+	}
+
+	for _, rule := range rules {
+		if rule == "required" && isBlank(fv) {
+			return "is required"
+		}
+	}
+	if fv.IsZero() {
+		return ""
+	}
+
+	for _, rule := range rules {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required", "trim":
+		case "min", "max":
+			if msg := checkBound(fv, name, arg); msg != "" {
+				return msg
+			}
+		case "email":
+			value := reflect.Indirect(fv).String()
+			addr, err := mail.ParseAddress(value)
+			if err != nil || addr.Address != value {
+				return "must be a valid email address"
+			}
+		case "uuid":
+			if _, err := uuid.Parse(reflect.Indirect(fv).String()); err != nil {
+				return "must be a valid UUID"
+			}
+		case "oneof":
+			options := strings.Fields(arg)
+			value := fmt.Sprint(reflect.Indirect(fv).Interface())
+			if !contains(options, value) {
+				return "must be one of: " + strings.Join(options, ", ")
+			}
+		}
+	}
+	return ""
+}
+
+// checkBound applies a min or max rule.
+func checkBound(fv reflect.Value, rule, arg string) string {
+	fv = reflect.Indirect(fv)
+	limit, _ := strconv.ParseFloat(arg, 64)
+
+	var n float64
+	var unit string
+	switch fv.Kind() {
+	case reflect.String:
+		n, unit = float64(utf8.RuneCountInString(fv.String())), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n, unit = float64(fv.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(fv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(fv.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = fv.Float()
+	}
+
+	if rule == "min" && n < limit {
+		return "must be at least " + arg + unit
+	}
+	if rule == "max" && n > limit {
+		return "must be at most " + arg + unit
+	}
+	return ""
+}
+
+// isBlank reports whether fv is zero or a whitespace-only string.
+func isBlank(fv reflect.Value) bool {
+	if fv.Kind() == reflect.String {
+		return strings.TrimSpace(fv.String()) == ""
+	}
+	return fv.IsZero()
+}
+
+func contains(options []string, value string) bool {
+	for _, option := range options {
+		if option == value {
+			return true
+		}
+	}
+	return false
+}
+
+// checkedTags caches the result of CheckTags per type.
+var checkedTags sync.Map // reflect.Type -> error
+
+// CheckTags reports invalid `validate` tags in the type of v: unknown rules,
+// missing or malformed arguments, and rules on fields they don't apply to.
+// Handle and HandleWithConfig check their request type when they are
+// called, so that mistakes fail at startup rather than on a request.
+func CheckTags(v any) error {
+	return checkTagsCached(reflect.TypeOf(v))
+}
+
+func checkTagsCached(t reflect.Type) error {
+	if t == nil {
+		return nil
+	}
+	if err, ok := checkedTags.Load(t); ok {
+		err, _ := err.(error)
+		return err
+	}
+	err := checkType(t, "", make(map[reflect.Type]bool))
+	checkedTags.Store(t, err)
+	return err
+}
+
+// checkType checks the tags of the struct types reachable from t.
+func checkType(t reflect.Type, prefix string, seen map[reflect.Type]bool) error {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return checkType(t.Elem(), prefix, seen)
+	case reflect.Struct:
+	default:
+		return nil
+	}
+	if seen[t] {
+		return nil
+	}
+	seen[t] = true
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if sf.Anonymous && name == "" {
+			et := sf.Type
+			if et.Kind() == reflect.Pointer {
+				et = et.Elem()
+			}
+			if et.Kind() == reflect.Struct {
+				if err := checkType(et, prefix, seen); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		if tag := sf.Tag.Get("validate"); tag != "" {
+			if err := checkRules(sf.Type, tag); err != nil {
+				return fmt.Errorf("httpjson: %s field %s%s: %w", t, prefix, name, err)
+			}
+		}
+		if err := checkType(sf.Type, prefix+name+".", seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRules checks the rules of a validate tag against the field type.
+func checkRules(t reflect.Type, tag string) error {
+	et := t
+	if et.Kind() == reflect.Pointer {
+		et = et.Elem()
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, hasArg := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+		case "trim":
+			if t.Kind() != reflect.String {
+				return fmt.Errorf("trim needs a string, got %s", t)
+			}
+		case "min", "max":
+			if _, err := strconv.ParseFloat(arg, 64); !hasArg || err != nil {
+				return fmt.Errorf("%s needs a number, got %q", name, arg)
+			}
+			switch et.Kind() {
+			case reflect.String, reflect.Slice, reflect.Array, reflect.Map,
+				reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+				reflect.Float32, reflect.Float64:
+			default:
+				return fmt.Errorf("%s doesn't apply to %s", name, t)
+			}
+		case "email", "uuid":
+			if et.Kind() != reflect.String {
+				return fmt.Errorf("%s needs a string, got %s", name, t)
+			}
+		case "oneof":
+			if len(strings.Fields(arg)) == 0 {
+				return errors.New("oneof needs at least one value")
+			}
+		default:
+			return fmt.Errorf("unknown validation rule %q", rule)
+		}
+	}
+	return nil
+}
@@ -145,7 +145,9 @@ logging:
 		return fmt.Errorf("failed to create dist dir: %w", err)
 	}
 
-	indexHTML := `<!DOCTYPE html><html><head><title>Test</title></head><body><div id="root">Test App</div></body></html>`
+	indexHTML := `<!DOCTYPE html><html><head><title>Test</title>` +
+		`<script type="application/json" id="bootstrap">{{.Bootstrap}}</script>` +
+		`</head><body><div id="root">Test App</div></body></html>`
 	if err := os.WriteFile(filepath.Join(distDir, "index.html"), []byte(indexHTML), 0644); err != nil {
 		return fmt.Errorf("failed to write index.html: %w", err)
 	}
@@ -451,11 +453,16 @@ func TestLogout(t *testing.T) {
 
 	// Logout
 	t.Log("Logging out...")
-	logoutResp, err := client.Post(serverBaseURL+"/api/auth/logout", "application/json", nil)
+	logoutReq, _ := http.NewRequest(http.MethodPost, serverBaseURL+"/api/auth/logout", nil)
+	logoutReq.Header.Set("X-CSRF-Token", fetchCSRFToken(t, client))
+	logoutResp, err := client.Do(logoutReq)
 	if err != nil {
 		t.Fatalf("Failed to logout: %v", err)
 	}
 	logoutResp.Body.Close()
+	if logoutResp.StatusCode != http.StatusOK && logoutResp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected successful logout, got %d", logoutResp.StatusCode)
+	}
 
 	// Verify logged out
 	sessionResp2, _ := client.Get(serverBaseURL + "/api/auth/session")
@@ -470,6 +477,220 @@ func TestLogout(t *testing.T) {
 	t.Log("Logout successful!")
 }
 
+func TestCSRFRejectsCrossSitePost(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	mockOIDC.QueueUser(&mockoidc.MockUser{
+		Subject:           "csrf-test-user",
+		Email:             "csrf@example.com",
+		PreferredUsername: "csrfuser",
+		EmailVerified:     true,
+	})
+
+	client := newClient()
+	loginResp, _ := client.Get(serverBaseURL + "/api/auth/login")
+	loginResp.Body.Close()
+	authResp, _ := client.Get(loginResp.Header.Get("Location"))
+	authResp.Body.Close()
+	callbackResp, _ := client.Get(authResp.Header.Get("Location"))
+	callbackResp.Body.Close()
+
+	token := fetchCSRFToken(t, client)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+	}{
+		{name: "no token", headers: map[string]string{}},
+		{name: "wrong token", headers: map[string]string{"X-CSRF-Token": "invalid"}},
+		{name: "cross-site", headers: map[string]string{"X-CSRF-Token": token, "Sec-Fetch-Site": "cross-site"}},
+		{name: "bad origin", headers: map[string]string{"X-CSRF-Token": token, "Origin": "https://evil.example"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, serverBaseURL+"/api/auth/logout", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Failed to post logout: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusForbidden {
+				t.Errorf("Expected 403, got %d", resp.StatusCode)
+			}
+		})
+	}
+
+	// None of the rejected requests logged the user out
+	sessionResp, err := client.Get(serverBaseURL + "/api/auth/session")
+	if err != nil {
+		t.Fatalf("Failed to check session: %v", err)
+	}
+	defer sessionResp.Body.Close()
+
+	var sessionData map[string]interface{}
+	json.NewDecoder(sessionResp.Body).Decode(&sessionData)
+	if sessionData["authenticated"] != true {
+		t.Errorf("Expected to still be authenticated, got: %v", sessionData)
+	}
+}
+
+func TestCSRFExemptsOIDCCallback(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// The identity provider's request carries no CSRF token; it must reach
+	// the callback handler, which rejects it for the missing state instead.
+	req, _ := http.NewRequest(http.MethodPost, serverBaseURL+"/api/oidc/callback?state=forged&code=forged", nil)
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	resp, err := newClient().Do(req)
+	if err != nil {
+		t.Fatalf("Failed to post callback: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 from the callback's state check, got %d", resp.StatusCode)
+	}
+}
+
+func TestCSPReportAcceptsBrowserReports(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// Browsers post reports without a session or CSRF token.
+	body := strings.NewReader(`{"csp-report":{"document-uri":"` + serverBaseURL + `/","violated-directive":"script-src"}}`)
+	req, _ := http.NewRequest(http.MethodPost, serverBaseURL+"/api/csp-report", body)
+	req.Header.Set("Content-Type", "application/csp-report")
+	req.Header.Set("Sec-Fetch-Site", "same-origin")
+	resp, err := newClient().Do(req)
+	if err != nil {
+		t.Fatalf("Failed to post CSP report: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", resp.StatusCode)
+	}
+}
+
+func TestIndexBootstrap(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// Anonymous page loads must not mint a CSRF token, as that would
+	// create a session for every visitor.
+	resp, err := newClient().Get(serverBaseURL + "/")
+	if err != nil {
+		t.Fatalf("Failed to get index: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, body)
+	}
+	if cookies := resp.Header.Values("Set-Cookie"); len(cookies) > 0 {
+		t.Errorf("Expected no cookies for an anonymous index render, got: %v", cookies)
+	}
+	bootstrap := indexBootstrap(t, body)
+	if bootstrap.CSRFToken != "" {
+		t.Errorf("Expected no CSRF token for an anonymous visitor, got %q", bootstrap.CSRFToken)
+	}
+	if bootstrap.Session.Authenticated {
+		t.Error("Expected an unauthenticated session in the bootstrap data")
+	}
+
+	// Signed-in users get their token with the page.
+	mockOIDC.QueueUser(&mockoidc.MockUser{
+		Subject:           "bootstrap-test-user",
+		Email:             "bootstrap@example.com",
+		PreferredUsername: "bootstrapuser",
+		EmailVerified:     true,
+	})
+	client := newClient()
+	loginResp, _ := client.Get(serverBaseURL + "/api/auth/login")
+	loginResp.Body.Close()
+	authResp, _ := client.Get(loginResp.Header.Get("Location"))
+	authResp.Body.Close()
+	callbackResp, _ := client.Get(authResp.Header.Get("Location"))
+	callbackResp.Body.Close()
+
+	resp, err = client.Get(serverBaseURL + "/")
+	if err != nil {
+		t.Fatalf("Failed to get index: %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	bootstrap = indexBootstrap(t, body)
+	if !bootstrap.Session.Authenticated {
+		t.Errorf("Expected an authenticated session in the bootstrap data: %s", body)
+	}
+	if bootstrap.CSRFToken == "" {
+		t.Fatal("Expected a CSRF token for a signed-in user")
+	}
+
+	// The token is valid for the session.
+	req, _ := http.NewRequest(http.MethodPost, serverBaseURL+"/api/auth/logout", nil)
+	req.Header.Set("X-CSRF-Token", bootstrap.CSRFToken)
+	logoutResp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to post logout: %v", err)
+	}
+	logoutResp.Body.Close()
+	if logoutResp.StatusCode == http.StatusForbidden {
+		t.Error("Expected the bootstrap CSRF token to be accepted, got 403")
+	}
+}
+
+// indexBootstrap extracts the bootstrap data from a rendered index.html.
+func indexBootstrap(t *testing.T, body []byte) (bootstrap struct {
+	Session struct {
+		Authenticated bool `json:"authenticated"`
+	} `json:"session"`
+	CSRFToken string `json:"csrf_token"`
+}) {
+	t.Helper()
+
+	_, rest, ok := strings.Cut(string(body), `id="bootstrap">`)
+	data, _, ok2 := strings.Cut(rest, "</script>")
+	if !ok || !ok2 {
+		t.Fatalf("No bootstrap data in index: %s", body)
+	}
+	if err := json.Unmarshal([]byte(data), &bootstrap); err != nil {
+		t.Fatalf("Failed to decode bootstrap data %q: %v", data, err)
+	}
+	return bootstrap
+}
+
+// fetchCSRFToken returns the CSRF token for the client's session.
+func fetchCSRFToken(t *testing.T, client *http.Client) string {
+	t.Helper()
+
+	resp, err := client.Get(serverBaseURL + "/api/auth/csrf")
+	if err != nil {
+		t.Fatalf("Failed to fetch CSRF token: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var tokenResp struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil || tokenResp.Token == "" {
+		t.Fatalf("Failed to decode CSRF token (status %d): %v", resp.StatusCode, err)
+	}
+	return tokenResp.Token
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
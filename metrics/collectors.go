@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/juanfont/juango/database"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectTimeout bounds the queries run while collecting at scrape time.
+const collectTimeout = 5 * time.Second
+
+// RegisterDatabase registers connection pool, WAL size and WAL checkpoint
+// metrics for db. Checkpoint stats come from a passive checkpoint run on
+// each scrape.
+func (m *Metrics) RegisterDatabase(db *database.Database) error {
+	_, err := registerCollector(m.reg, newDatabaseCollector(db))
+	return err
+}
+
+// RegisterSessionCount registers a gauge reporting the number of sessions
+// in the session store, as returned by count at scrape time.
+func (m *Metrics) RegisterSessionCount(count func(context.Context) (int, error)) error {
+	_, err := registerCollector(m.reg, &sessionCollector{
+		count: count,
+		desc: prometheus.NewDesc(
+			"session_store_sessions",
+			"Number of sessions in the session store",
+			nil, nil,
+		),
+	})
+	return err
+}
+
+type sessionCollector struct {
+	count func(context.Context) (int, error)
+	desc  *prometheus.Desc
+}
+
+func (c *sessionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *sessionCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	n, err := c.count(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n))
+}
+
+type databaseCollector struct {
+	db *database.Database
+
+	openConns          *prometheus.Desc
+	inUseConns         *prometheus.Desc
+	idleConns          *prometheus.Desc
+	waitCount          *prometheus.Desc
+	waitDuration       *prometheus.Desc
+	walSize            *prometheus.Desc
+	checkpointBusy     *prometheus.Desc
+	walFrames          *prometheus.Desc
+	checkpointedFrames *prometheus.Desc
+}
+
+func newDatabaseCollector(db *database.Database) *databaseCollector {
+	labels := prometheus.Labels{"path": db.Path()}
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(name, help, nil, labels)
+	}
+	return &databaseCollector{
+		db:                 db,
+		openConns:          desc("sqlite_pool_open_connections", "Number of open database connections"),
+		inUseConns:         desc("sqlite_pool_in_use_connections", "Number of database connections in use"),
+		idleConns:          desc("sqlite_pool_idle_connections", "Number of idle database connections"),
+		waitCount:          desc("sqlite_pool_wait_count_total", "Total number of waits for a database connection"),
+		waitDuration:       desc("sqlite_pool_wait_duration_seconds_total", "Total time spent waiting for a database connection"),
+		walSize:            desc("sqlite_wal_size_bytes", "Size of the write-ahead log file in bytes"),
+		checkpointBusy:     desc("sqlite_wal_checkpoint_busy", "Whether the last passive WAL checkpoint was blocked (1) or completed (0)"),
+		walFrames:          desc("sqlite_wal_frames", "Number of frames in the write-ahead log"),
+		checkpointedFrames: desc("sqlite_wal_checkpointed_frames", "Number of write-ahead log frames checkpointed into the database"),
+	}
+}
+
+func (c *databaseCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		c.openConns, c.inUseConns, c.idleConns, c.waitCount, c.waitDuration,
+		c.walSize, c.checkpointBusy, c.walFrames, c.checkpointedFrames,
+	} {
+		ch <- d
+	}
+}
+
+func (c *databaseCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.db.DB().Stats()
+	ch <- prometheus.MustNewConstMetric(c.openConns, prometheus.GaugeValue, float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.inUseConns, prometheus.GaugeValue, float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds())
+
+	if size, err := c.db.WALSize(); err != nil {
+		ch <- prometheus.NewInvalidMetric(c.walSize, err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.walSize, prometheus.GaugeValue, float64(size))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	cp, err := c.db.WALCheckpoint(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.checkpointBusy, err)
+		return
+	}
+	busy := 0.0
+	if cp.Busy {
+		busy = 1
+	}
+	ch <- prometheus.MustNewConstMetric(c.checkpointBusy, prometheus.GaugeValue, busy)
+	ch <- prometheus.MustNewConstMetric(c.walFrames, prometheus.GaugeValue, float64(cp.LogFrames))
+	ch <- prometheus.MustNewConstMetric(c.checkpointedFrames, prometheus.GaugeValue, float64(cp.CheckpointedFrames))
+}
@@ -0,0 +1,221 @@
+// Package metrics provides Prometheus collectors for juango components:
+// logins, admin mode and impersonation, the session store, background tasks
+// and the SQLite database.
+//
+// Create a Metrics with New and pass it to the components that report
+// events (auth.OIDCHandlers, auth.SessionMiddleware, admin.Handlers,
+// tasks.Client and tasks.Server) via their SetMetrics methods. State that is
+// read at scrape time is added with RegisterDatabase and
+// RegisterSessionCount.
+package metrics
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/juanfont/juango/auth"
+	"github.com/juanfont/juango/tasks"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Result label values.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+var (
+	_ auth.Metrics  = (*Metrics)(nil)
+	_ tasks.Metrics = (*Metrics)(nil)
+)
+
+// Metrics holds the juango collectors and implements auth.Metrics and
+// tasks.Metrics.
+type Metrics struct {
+	reg prometheus.Registerer
+
+	logins         *prometheus.CounterVec
+	tasksEnqueued  *prometheus.CounterVec
+	tasksProcessed *prometheus.CounterVec
+	taskDuration   *prometheus.HistogramVec
+
+	adminMode      activeSet
+	impersonations activeSet
+}
+
+// New creates the collectors and registers them on reg (default:
+// prometheus.DefaultRegisterer). Create one Metrics per registerer: the
+// admin mode and impersonation gauges read the state of the first one.
+func New(reg prometheus.Registerer) (*Metrics, error) {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	m := &Metrics{reg: reg}
+	var err error
+
+	if m.logins, err = registerCollector(reg, prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_logins_total",
+			Help: "Total number of OIDC login callbacks by result and failure reason",
+		},
+		[]string{"result", "reason"},
+	)); err != nil {
+		return nil, err
+	}
+	m.logins.WithLabelValues(ResultSuccess, "")
+
+	if _, err = registerCollector(reg, prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "auth_admin_mode_active",
+			Help: "Number of admins currently in admin mode",
+		},
+		m.adminMode.count,
+	)); err != nil {
+		return nil, err
+	}
+
+	if _, err = registerCollector(reg, prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "auth_impersonations_active",
+			Help: "Number of admins currently impersonating a user",
+		},
+		m.impersonations.count,
+	)); err != nil {
+		return nil, err
+	}
+
+	if m.tasksEnqueued, err = registerCollector(reg, prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tasks_enqueued_total",
+			Help: "Total number of task enqueue attempts by task type and result",
+		},
+		[]string{"task_type", "result"},
+	)); err != nil {
+		return nil, err
+	}
+
+	if m.tasksProcessed, err = registerCollector(reg, prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tasks_processed_total",
+			Help: "Total number of processed tasks by task type and result",
+		},
+		[]string{"task_type", "result"},
+	)); err != nil {
+		return nil, err
+	}
+
+	if m.taskDuration, err = registerCollector(reg, prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "tasks_duration_seconds",
+			Help:    "Task processing duration in seconds",
+			Buckets: prometheus.ExponentialBuckets(0.005, 4, 10),
+		},
+		[]string{"task_type"},
+	)); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// LoginSucceeded implements auth.Metrics.
+func (m *Metrics) LoginSucceeded() {
+	m.logins.WithLabelValues(ResultSuccess, "").Inc()
+}
+
+// LoginFailed implements auth.Metrics.
+func (m *Metrics) LoginFailed(reason string) {
+	m.logins.WithLabelValues(ResultFailure, reason).Inc()
+}
+
+// AdminModeStarted implements auth.Metrics.
+func (m *Metrics) AdminModeStarted(adminID uuid.UUID, expiresAt time.Time) {
+	m.adminMode.start(adminID, expiresAt)
+}
+
+// AdminModeEnded implements auth.Metrics.
+func (m *Metrics) AdminModeEnded(adminID uuid.UUID) {
+	m.adminMode.end(adminID)
+}
+
+// ImpersonationStarted implements auth.Metrics.
+func (m *Metrics) ImpersonationStarted(adminID uuid.UUID, expiresAt time.Time) {
+	m.impersonations.start(adminID, expiresAt)
+}
+
+// ImpersonationEnded implements auth.Metrics.
+func (m *Metrics) ImpersonationEnded(adminID uuid.UUID) {
+	m.impersonations.end(adminID)
+}
+
+// TaskEnqueued implements tasks.Metrics.
+func (m *Metrics) TaskEnqueued(taskType string, err error) {
+	m.tasksEnqueued.WithLabelValues(taskType, result(err)).Inc()
+}
+
+// TaskProcessed implements tasks.Metrics.
+func (m *Metrics) TaskProcessed(taskType string, duration time.Duration, err error) {
+	m.tasksProcessed.WithLabelValues(taskType, result(err)).Inc()
+	m.taskDuration.WithLabelValues(taskType).Observe(duration.Seconds())
+}
+
+func result(err error) string {
+	if err != nil {
+		return ResultFailure
+	}
+	return ResultSuccess
+}
+
+// activeSet tracks admins in a time-limited state. It only sees the
+// transitions handled by this process, so with several replicas each one
+// reports its own share, and a restart resets the count until admins
+// re-enter the state.
+type activeSet struct {
+	mu      sync.Mutex
+	expires map[uuid.UUID]time.Time
+}
+
+func (s *activeSet) start(id uuid.UUID, expiresAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.expires == nil {
+		s.expires = make(map[uuid.UUID]time.Time)
+	}
+	s.expires[id] = expiresAt
+}
+
+func (s *activeSet) end(id uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.expires, id)
+}
+
+// count returns the number of unexpired entries, dropping expired ones.
+func (s *activeSet) count() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for id, exp := range s.expires {
+		if !exp.After(now) {
+			delete(s.expires, id)
+		}
+	}
+	return float64(len(s.expires))
+}
+
+// registerCollector registers c, returning the already registered
+// collector if an identical one exists.
+func registerCollector[C prometheus.Collector](reg prometheus.Registerer, c C) (C, error) {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(C); ok {
+				return existing, nil
+			}
+		}
+		return c, err
+	}
+	return c, nil
+}
@@ -1,95 +1,273 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 )
 
+// ErrCORSWildcardCredentials is returned for configurations that allow
+// credentials from any origin.
+var ErrCORSWildcardCredentials = errors.New("cors: wildcard origin cannot be combined with AllowCredentials")
+
 // CORSConfig holds CORS middleware configuration.
 type CORSConfig struct {
-	// AllowedOrigins is a list of allowed origins. Use "*" for all origins.
+	// AllowedOrigins is a list of allowed origins. Use "*" for all origins,
+	// or a "*." subdomain wildcard such as "https://*.corp.example", which
+	// matches any subdomain but not corp.example itself.
 	AllowedOrigins []string
 
+	// AllowedOriginRegexps are regular expressions matched against the
+	// whole origin, e.g. `https://pr-\d+\.preview\.example\.com`.
+	AllowedOriginRegexps []string
+
 	// AllowedMethods is a list of allowed HTTP methods.
 	AllowedMethods []string
 
-	// AllowedHeaders is a list of allowed headers.
+	// AllowedHeaders is a list of allowed request headers. "*" allows any.
 	AllowedHeaders []string
 
-	// AllowCredentials indicates whether credentials are allowed.
+	// ExposedHeaders is a list of response headers readable by scripts.
+	ExposedHeaders []string
+
+	// AllowCredentials indicates whether credentials are allowed. It can't
+	// be combined with a "*" origin.
 	AllowCredentials bool
 
 	// MaxAge is the max age for preflight cache in seconds.
 	MaxAge int
 }
 
-// DefaultCORSConfig returns a permissive CORS configuration suitable for development.
+// DefaultCORSConfig returns a permissive CORS configuration suitable for
+// development: any origin, without credentials.
 func DefaultCORSConfig() *CORSConfig {
 	return &CORSConfig{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Requested-With"},
-		AllowCredentials: true,
-		MaxAge:           86400,
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "X-Requested-With"},
+		ExposedHeaders: []string{RequestIDHeader},
+		MaxAge:         86400,
 	}
 }
 
-// CORS returns a middleware that handles Cross-Origin Resource Sharing.
-func CORS(cfg *CORSConfig) func(http.Handler) http.Handler {
+// CORSPolicy is a validated CORS configuration. Use Handler to apply it to
+// individual routes, or CORS for a router-wide middleware.
+type CORSPolicy struct {
+	allowAll         bool
+	origins          map[string]bool
+	subdomains       []subdomainPattern
+	regexps          []*regexp.Regexp
+	methods          []string
+	allowAllHeaders  bool
+	headers          map[string]bool
+	allowedMethods   string
+	exposedHeaders   string
+	allowCredentials bool
+	maxAge           string
+}
+
+// subdomainPattern matches scheme://<label>.suffix[:port].
+type subdomainPattern struct {
+	scheme string
+	suffix string // ".corp.example"
+	port   string
+}
+
+// NewCORSPolicy validates cfg (nil = DefaultCORSConfig) and builds a policy.
+func NewCORSPolicy(cfg *CORSConfig) (*CORSPolicy, error) {
 	if cfg == nil {
 		cfg = DefaultCORSConfig()
 	}
 
-	// Build a set of allowed origins for O(1) lookups
-	allowedOrigins := make(map[string]bool)
-	allowWildcard := false
+	p := &CORSPolicy{
+		origins:          make(map[string]bool),
+		headers:          make(map[string]bool),
+		allowCredentials: cfg.AllowCredentials,
+		allowedMethods:   strings.Join(cfg.AllowedMethods, ", "),
+		exposedHeaders:   strings.Join(cfg.ExposedHeaders, ", "),
+	}
+	if cfg.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(cfg.MaxAge)
+	}
+
 	for _, o := range cfg.AllowedOrigins {
-		if o == "*" {
-			allowWildcard = true
+		switch {
+		case o == "*":
+			if cfg.AllowCredentials {
+				return nil, ErrCORSWildcardCredentials
+			}
+			p.allowAll = true
+		case strings.Contains(o, "*"):
+			pattern, err := parseSubdomainPattern(o)
+			if err != nil {
+				return nil, err
+			}
+			p.subdomains = append(p.subdomains, pattern)
+		default:
+			p.origins[strings.ToLower(o)] = true
 		}
-		allowedOrigins[o] = true
 	}
 
-	allowedMethods := strings.Join(cfg.AllowedMethods, ", ")
-	allowedHeaders := strings.Join(cfg.AllowedHeaders, ", ")
+	for _, expr := range cfg.AllowedOriginRegexps {
+		re, err := regexp.Compile(`^(?:` + expr + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("cors: invalid origin regexp %q: %w", expr, err)
+		}
+		p.regexps = append(p.regexps, re)
+	}
 
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			origin := r.Header.Get("Origin")
+	for _, m := range cfg.AllowedMethods {
+		p.methods = append(p.methods, strings.ToUpper(m))
+	}
+	for _, h := range cfg.AllowedHeaders {
+		if h == "*" {
+			p.allowAllHeaders = true
+			continue
+		}
+		p.headers[http.CanonicalHeaderKey(h)] = true
+	}
 
-			// Check if origin is allowed
-			if origin != "" {
-				allowed := allowWildcard || allowedOrigins[origin]
+	return p, nil
+}
 
-				if allowed {
-					if allowWildcard && !cfg.AllowCredentials {
-						w.Header().Set("Access-Control-Allow-Origin", "*")
-					} else {
-						w.Header().Set("Access-Control-Allow-Origin", origin)
-					}
+func parseSubdomainPattern(o string) (subdomainPattern, error) {
+	u, err := url.Parse(o)
+	if err != nil || u.Scheme == "" || !strings.HasPrefix(u.Host, "*.") || strings.Count(o, "*") != 1 ||
+		(u.Path != "" && u.Path != "/") {
+		return subdomainPattern{}, fmt.Errorf("cors: invalid origin pattern %q, want scheme://*.domain[:port]", o)
+	}
+	return subdomainPattern{
+		scheme: strings.ToLower(u.Scheme),
+		suffix: strings.ToLower(strings.TrimPrefix(u.Hostname(), "*")),
+		port:   u.Port(),
+	}, nil
+}
 
-					if cfg.AllowCredentials {
-						w.Header().Set("Access-Control-Allow-Credentials", "true")
-					}
-				}
-			}
+// CORS returns a middleware that handles Cross-Origin Resource Sharing. It
+// panics if cfg is invalid; use NewCORSPolicy to handle the error.
+func CORS(cfg *CORSConfig) func(http.Handler) http.Handler {
+	p, err := NewCORSPolicy(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return p.Handler
+}
+
+// Handler applies the policy to next. Preflight requests are answered
+// directly; when used on a single gorilla/mux route, the route must also
+// match OPTIONS.
+func (p *CORSPolicy) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
 
-			// Handle preflight requests
-			if r.Method == http.MethodOptions {
-				w.Header().Set("Access-Control-Allow-Methods", allowedMethods)
-				w.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
-				if cfg.MaxAge > 0 {
-					w.Header().Set("Access-Control-Max-Age", strconv.Itoa(cfg.MaxAge))
-				}
-				w.WriteHeader(http.StatusNoContent)
-				return
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			p.handlePreflight(w, r, origin)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		if origin != "" && p.originAllowed(origin) {
+			p.setAllowOrigin(w, origin)
+			if p.exposedHeaders != "" {
+				w.Header().Set("Access-Control-Expose-Headers", p.exposedHeaders)
 			}
+		}
 
-			// Set Vary header for caching
-			w.Header().Add("Vary", "Origin")
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (p *CORSPolicy) handlePreflight(w http.ResponseWriter, r *http.Request, origin string) {
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+
+	method := r.Header.Get("Access-Control-Request-Method")
+	requested := parseHeaderList(r.Header.Values("Access-Control-Request-Headers"))
+	if origin == "" || !p.originAllowed(origin) || !slices.Contains(p.methods, method) || !p.headersAllowed(requested) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	p.setAllowOrigin(w, origin)
+	h.Set("Access-Control-Allow-Methods", p.allowedMethods)
+	if len(requested) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+	}
+	if p.maxAge != "" {
+		h.Set("Access-Control-Max-Age", p.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-			next.ServeHTTP(w, r)
-		})
+func (p *CORSPolicy) setAllowOrigin(w http.ResponseWriter, origin string) {
+	if p.allowAll {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if p.allowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (p *CORSPolicy) originAllowed(origin string) bool {
+	if p.allowAll {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if p.origins[origin] {
+		return true
+	}
+	for _, re := range p.regexps {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	if len(p.subdomains) == 0 {
+		return false
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	for _, s := range p.subdomains {
+		if u.Scheme == s.scheme && u.Port() == s.port &&
+			strings.HasSuffix(host, s.suffix) && len(host) > len(s.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *CORSPolicy) headersAllowed(requested []string) bool {
+	if p.allowAllHeaders {
+		return true
+	}
+	for _, h := range requested {
+		if !p.headers[http.CanonicalHeaderKey(h)] {
+			return false
+		}
+	}
+	return true
+}
+
+// parseHeaderList splits comma-separated header names.
+func parseHeaderList(values []string) []string {
+	var names []string
+	for _, v := range values {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, strings.ToLower(name))
+			}
+		}
 	}
+	return names
 }
@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewCORSPolicyRejectsWildcardWithCredentials(t *testing.T) {
+	_, err := NewCORSPolicy(&CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com", "*"},
+		AllowCredentials: true,
+	})
+	if !errors.Is(err, ErrCORSWildcardCredentials) {
+		t.Fatalf("NewCORSPolicy error = %v, want ErrCORSWildcardCredentials", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("CORS did not panic on a wildcard origin with credentials")
+		}
+	}()
+	CORS(&CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+}
+
+func TestNewCORSPolicyInvalidPatterns(t *testing.T) {
+	for _, cfg := range []*CORSConfig{
+		{AllowedOrigins: []string{"https://*.*.example.com"}},
+		{AllowedOrigins: []string{"https://app.*.example.com"}},
+		{AllowedOrigins: []string{"*.example.com"}},
+		{AllowedOriginRegexps: []string{`https://(`}},
+	} {
+		if _, err := NewCORSPolicy(cfg); err == nil {
+			t.Errorf("NewCORSPolicy(%+v) succeeded, want error", cfg)
+		}
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	policy, err := NewCORSPolicy(&CORSConfig{
+		AllowedOrigins:       []string{"https://app.example.com", "https://*.corp.example"},
+		AllowedOriginRegexps: []string{`https://pr-\d+\.preview\.example\.com`},
+		AllowedMethods:       []string{"GET", "POST"},
+		AllowedHeaders:       []string{"Content-Type", "X-CSRF-Token"},
+		AllowCredentials:     true,
+		MaxAge:               600,
+	})
+	if err != nil {
+		t.Fatalf("creating policy: %v", err)
+	}
+	handler := policy.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("preflight reached the handler")
+	}))
+
+	tests := []struct {
+		name    string
+		origin  string
+		method  string
+		headers string
+		want    int
+	}{
+		{name: "allowed", origin: "https://app.example.com", method: "POST", headers: "content-type, x-csrf-token", want: http.StatusNoContent},
+		{name: "subdomain pattern", origin: "https://git.corp.example", method: "GET", want: http.StatusNoContent},
+		{name: "origin regexp", origin: "https://pr-42.preview.example.com", method: "GET", want: http.StatusNoContent},
+		{name: "disallowed method", origin: "https://app.example.com", method: "DELETE", want: http.StatusForbidden},
+		{name: "lowercase method", origin: "https://app.example.com", method: "post", want: http.StatusForbidden},
+		{name: "disallowed header", origin: "https://app.example.com", method: "POST", headers: "Content-Type, X-Admin", want: http.StatusForbidden},
+		{name: "disallowed origin", origin: "https://evil.example", method: "GET", want: http.StatusForbidden},
+		{name: "pattern base domain", origin: "https://corp.example", method: "GET", want: http.StatusForbidden},
+		{name: "pattern suffix lookalike", origin: "https://evilcorp.example", method: "GET", want: http.StatusForbidden},
+		{name: "pattern wrong scheme", origin: "http://git.corp.example", method: "GET", want: http.StatusForbidden},
+		{name: "regexp is anchored", origin: "https://pr-42.preview.example.com.evil.example", method: "GET", want: http.StatusForbidden},
+		{name: "no origin", method: "GET", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, "/api/items", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			req.Header.Set("Access-Control-Request-Method", tt.method)
+			if tt.headers != "" {
+				req.Header.Set("Access-Control-Request-Headers", tt.headers)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status %d, want %d", rec.Code, tt.want)
+			}
+			allowOrigin := rec.Header().Get("Access-Control-Allow-Origin")
+			if tt.want != http.StatusNoContent {
+				if allowOrigin != "" || rec.Header().Get("Access-Control-Allow-Methods") != "" {
+					t.Errorf("rejected preflight carries CORS headers: %v", rec.Header())
+				}
+				return
+			}
+			if allowOrigin != tt.origin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", allowOrigin, tt.origin)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want true", got)
+			}
+			if got := rec.Header().Get("Access-Control-Max-Age"); got != "600" {
+				t.Errorf("Access-Control-Max-Age = %q, want 600", got)
+			}
+		})
+	}
+}
+
+func TestCORSSimpleRequest(t *testing.T) {
+	handler := CORS(&CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{"GET"},
+		ExposedHeaders:   []string{RequestIDHeader},
+		AllowCredentials: true,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		origin    string
+		wantAllow string
+	}{
+		{origin: "https://app.example.com", wantAllow: "https://app.example.com"},
+		{origin: "https://evil.example", wantAllow: ""},
+		{origin: "null", wantAllow: ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/items", nil)
+		req.Header.Set("Origin", tt.origin)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllow {
+			t.Errorf("origin %s: Access-Control-Allow-Origin = %q, want %q", tt.origin, got, tt.wantAllow)
+		}
+		if tt.wantAllow == "" && rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+			t.Errorf("origin %s: credentials allowed for a disallowed origin", tt.origin)
+		}
+		if got := rec.Header().Get("Vary"); got != "Origin" {
+			t.Errorf("origin %s: Vary = %q, want Origin", tt.origin, got)
+		}
+	}
+}
+
+func TestCORSWildcardOmitsCredentials(t *testing.T) {
+	handler := CORS(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/items", nil)
+	req.Header.Set("Origin", "https://any.example")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want *", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Access-Control-Allow-Credentials = %q, want none", got)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/juanfont/juango/auth"
 	"github.com/rs/zerolog"
 )
 
@@ -26,6 +27,12 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to
+// hijack WebSocket connections or flush.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // Logging returns a middleware that logs HTTP requests using zerolog.
 func Logging(logger zerolog.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -47,12 +54,13 @@ func Logging(logger zerolog.Logger) func(http.Handler) http.Handler {
 				event = logger.Debug()
 			}
 
-			event.
+			withRequestFields(event, w, r).
 				Str("method", r.Method).
 				Str("path", r.URL.Path).
 				Int("status", wrapped.statusCode).
 				Dur("duration", duration).
 				Str("remote_addr", r.RemoteAddr).
+				Str("client_ip", auth.GetClientIP(r)).
 				Str("user_agent", r.UserAgent()).
 				Msg("HTTP request")
 		})
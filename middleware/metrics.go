@@ -1,17 +1,45 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+// UnmatchedRoute is the path label for requests that did not match a
+// gorilla/mux route.
+const UnmatchedRoute = "unmatched"
+
+// MetricsConfig configures the HTTP metrics middleware.
+type MetricsConfig struct {
+	// Registerer is where the metrics are registered (default:
+	// prometheus.DefaultRegisterer). Registering the same metrics twice
+	// reuses the existing collectors.
+	Registerer prometheus.Registerer
+
+	// AuthState, if set, adds an auth_state label with its return value,
+	// e.g. auth.SessionMiddleware.AuthState.
+	AuthState func(*http.Request) string
+
+	// DurationBuckets are the request duration histogram buckets in seconds
+	// (default: prometheus.DefBuckets).
+	DurationBuckets []float64
+
+	// SizeBuckets are the request and response size histogram buckets in
+	// bytes (default: 100B to 100MB, exponential).
+	SizeBuckets []float64
+}
+
+// Collectors used by Metrics on the default registerer.
 var (
 	// HTTPRequestsTotal counts total HTTP requests.
-	HTTPRequestsTotal = promauto.NewCounterVec(
+	//
+	// Deprecated: use HTTPMetrics.RequestsTotal from NewHTTPMetrics.
+	HTTPRequestsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "http_requests_total",
 			Help: "Total number of HTTP requests",
@@ -20,7 +48,9 @@ var (
 	)
 
 	// HTTPRequestDuration tracks HTTP request duration.
-	HTTPRequestDuration = promauto.NewHistogramVec(
+	//
+	// Deprecated: use HTTPMetrics.RequestDuration from NewHTTPMetrics.
+	HTTPRequestDuration = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    "http_request_duration_seconds",
 			Help:    "HTTP request duration in seconds",
@@ -30,7 +60,9 @@ var (
 	)
 
 	// HTTPRequestsInFlight tracks the number of in-flight requests.
-	HTTPRequestsInFlight = promauto.NewGauge(
+	//
+	// Deprecated: use HTTPMetrics.RequestsInFlight from NewHTTPMetrics.
+	HTTPRequestsInFlight = prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Name: "http_requests_in_flight",
 			Help: "Number of HTTP requests currently being processed",
@@ -38,10 +70,130 @@ var (
 	)
 )
 
-// metricsResponseWriter wraps http.ResponseWriter to capture status code.
+// HTTPMetrics holds the collectors used by the metrics middleware.
+type HTTPMetrics struct {
+	RequestsTotal    *prometheus.CounterVec
+	RequestDuration  *prometheus.HistogramVec
+	RequestSize      *prometheus.HistogramVec
+	ResponseSize     *prometheus.HistogramVec
+	RequestsInFlight prometheus.Gauge
+
+	authState func(*http.Request) string
+}
+
+// NewHTTPMetrics creates the HTTP metrics and registers them on
+// cfg.Registerer. With the default registerer and labels it records into
+// the deprecated HTTPRequestsTotal, HTTPRequestDuration and
+// HTTPRequestsInFlight collectors.
+func NewHTTPMetrics(cfg MetricsConfig) (*HTTPMetrics, error) {
+	reg := cfg.Registerer
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	durationBuckets := cfg.DurationBuckets
+	if durationBuckets == nil {
+		durationBuckets = prometheus.DefBuckets
+	}
+	sizeBuckets := cfg.SizeBuckets
+	if sizeBuckets == nil {
+		sizeBuckets = prometheus.ExponentialBuckets(100, 10, 7)
+	}
+
+	labels := []string{"method", "path"}
+	if cfg.AuthState != nil {
+		labels = append(labels, "auth_state")
+	}
+
+	m := &HTTPMetrics{authState: cfg.AuthState}
+	var err error
+
+	requestsTotal := HTTPRequestsTotal
+	requestDuration := HTTPRequestDuration
+	requestsInFlight := HTTPRequestsInFlight
+	if reg != prometheus.DefaultRegisterer || cfg.AuthState != nil || cfg.DurationBuckets != nil {
+		requestsTotal = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "http_requests_total",
+				Help: "Total number of HTTP requests",
+			},
+			append(labels[:len(labels):len(labels)], "status"),
+		)
+		requestDuration = prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "http_request_duration_seconds",
+				Help:    "HTTP request duration in seconds",
+				Buckets: durationBuckets,
+			},
+			labels,
+		)
+	}
+	if reg != prometheus.DefaultRegisterer {
+		requestsInFlight = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "http_requests_in_flight",
+				Help: "Number of HTTP requests currently being processed",
+			},
+		)
+	}
+
+	if m.RequestsTotal, err = registerCollector(reg, requestsTotal); err != nil {
+		return nil, err
+	}
+
+	if m.RequestDuration, err = registerCollector(reg, requestDuration); err != nil {
+		return nil, err
+	}
+
+	if m.RequestSize, err = registerCollector(reg, prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_request_size_bytes",
+			Help:    "HTTP request body size in bytes",
+			Buckets: sizeBuckets,
+		},
+		labels,
+	)); err != nil {
+		return nil, err
+	}
+
+	if m.ResponseSize, err = registerCollector(reg, prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_response_size_bytes",
+			Help:    "HTTP response body size in bytes",
+			Buckets: sizeBuckets,
+		},
+		labels,
+	)); err != nil {
+		return nil, err
+	}
+
+	if m.RequestsInFlight, err = registerCollector(reg, requestsInFlight); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// registerCollector registers c, returning the already registered
+// collector if an identical one exists.
+func registerCollector[C prometheus.Collector](reg prometheus.Registerer, c C) (C, error) {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(C); ok {
+				return existing, nil
+			}
+		}
+		return c, err
+	}
+	return c, nil
+}
+
+// metricsResponseWriter wraps http.ResponseWriter to capture the status
+// code and the number of bytes written.
 type metricsResponseWriter struct {
 	http.ResponseWriter
 	statusCode int
+	written    int64
 }
 
 func newMetricsResponseWriter(w http.ResponseWriter) *metricsResponseWriter {
@@ -56,41 +208,88 @@ func (rw *metricsResponseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
-// Metrics returns a middleware that collects Prometheus metrics.
-func Metrics() func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			HTTPRequestsInFlight.Inc()
-			defer HTTPRequestsInFlight.Dec()
+func (rw *metricsResponseWriter) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	rw.written += int64(n)
+	return n, err
+}
 
-			start := time.Now()
-			wrapped := newMetricsResponseWriter(w)
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rw *metricsResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
 
-			next.ServeHTTP(wrapped, r)
+// Handler returns a middleware that records metrics for each request. The
+// path label is the matched gorilla/mux path template, or UnmatchedRoute.
+func (m *HTTPMetrics) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.RequestsInFlight.Inc()
+		defer m.RequestsInFlight.Dec()
 
-			duration := time.Since(start).Seconds()
+		labels := []string{r.Method, routeLabel(r)}
+		if m.authState != nil {
+			labels = append(labels, m.authState(r))
+		}
 
-			HTTPRequestsTotal.WithLabelValues(
-				r.Method,
-				r.URL.Path,
-				strconv.Itoa(wrapped.statusCode),
-			).Inc()
+		start := time.Now()
+		wrapped := newMetricsResponseWriter(w)
 
-			HTTPRequestDuration.WithLabelValues(
-				r.Method,
-				r.URL.Path,
-			).Observe(duration)
-		})
+		next.ServeHTTP(wrapped, r)
+
+		duration := time.Since(start).Seconds()
+
+		m.RequestsTotal.WithLabelValues(append(labels, strconv.Itoa(wrapped.statusCode))...).Inc()
+		m.RequestDuration.WithLabelValues(labels...).Observe(duration)
+		m.RequestSize.WithLabelValues(labels...).Observe(float64(max(r.ContentLength, 0)))
+		m.ResponseSize.WithLabelValues(labels...).Observe(float64(wrapped.written))
+	})
+}
+
+// routeLabel returns the path template of the matched mux route.
+func routeLabel(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return UnmatchedRoute
+	}
+	tmpl, err := route.GetPathTemplate()
+	if err != nil {
+		return UnmatchedRoute
+	}
+	return tmpl
+}
+
+// Metrics returns a middleware that collects Prometheus metrics on the
+// default registerer. It panics if the metrics can't be registered; use
+// MetricsWithConfig to handle the error.
+func Metrics() func(http.Handler) http.Handler {
+	mw, err := MetricsWithConfig(MetricsConfig{})
+	if err != nil {
+		panic(err)
 	}
+	return mw
+}
+
+// MetricsWithConfig returns a middleware that collects Prometheus metrics.
+// It returns an error if the metrics can't be registered, e.g. because
+// metrics with the same names but different labels already exist.
+func MetricsWithConfig(cfg MetricsConfig) (func(http.Handler) http.Handler, error) {
+	m, err := NewHTTPMetrics(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return m.Handler, nil
 }
 
 // MetricsSimple returns a simpler metrics middleware that only counts requests.
 // This is useful when you don't need detailed path-level metrics.
 func MetricsSimple() func(http.Handler) http.Handler {
-	counter := promauto.NewCounter(prometheus.CounterOpts{
+	counter, err := registerCollector(prometheus.DefaultRegisterer, prometheus.NewCounter(prometheus.CounterOpts{
 		Name: "http_requests_total_simple",
 		Help: "Total number of HTTP requests (simple counter)",
-	})
+	}))
+	if err != nil {
+		panic(err)
+	}
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// requestCounts returns http_requests_total keyed by its label values.
+func requestCounts(t *testing.T, reg *prometheus.Registry) map[string]float64 {
+	t.Helper()
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("gathering metrics: %v", err)
+	}
+	counts := make(map[string]float64)
+	for _, f := range families {
+		if f.GetName() != "http_requests_total" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			counts[labelKey(m)] = m.GetCounter().GetValue()
+		}
+	}
+	return counts
+}
+
+func labelKey(m *dto.Metric) string {
+	var key string
+	for _, l := range m.GetLabel() {
+		if key != "" {
+			key += ","
+		}
+		key += l.GetName() + "=" + l.GetValue()
+	}
+	return key
+}
+
+func newMetricsRouter(t *testing.T, cfg MetricsConfig) *mux.Router {
+	t.Helper()
+
+	mw, err := MetricsWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("creating metrics middleware: %v", err)
+	}
+	router := mux.NewRouter()
+	router.Use(mw)
+	router.HandleFunc("/api/items/{id}", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+	router.NotFoundHandler = mw(http.NotFoundHandler())
+	return router
+}
+
+func TestMetricsRouteLabels(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	router := newMetricsRouter(t, MetricsConfig{Registerer: reg})
+
+	for _, path := range []string{"/api/items/1", "/api/items/2", "/nope", "/api/items/3/extra"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	got := requestCounts(t, reg)
+	want := map[string]float64{
+		"method=GET,path=/api/items/{id},status=200":        2,
+		"method=GET,path=" + UnmatchedRoute + ",status=404": 2,
+	}
+	if len(got) != len(want) {
+		t.Errorf("series = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+}
+
+func TestMetricsAuthStateLabel(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	router := newMetricsRouter(t, MetricsConfig{
+		Registerer: reg,
+		AuthState:  func(r *http.Request) string { return r.Header.Get("X-State") },
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/items/1", nil)
+	req.Header.Set("X-State", "user")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	got := requestCounts(t, reg)
+	if key := "auth_state=user,method=GET,path=/api/items/{id},status=200"; got[key] != 1 {
+		t.Errorf("series = %v, want %s", got, key)
+	}
+}
+
+func TestMetricsWithConfigRegistration(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	if _, err := MetricsWithConfig(MetricsConfig{Registerer: reg}); err != nil {
+		t.Fatalf("first registration: %v", err)
+	}
+	if _, err := MetricsWithConfig(MetricsConfig{Registerer: reg}); err != nil {
+		t.Errorf("identical registration: %v, want existing collectors reused", err)
+	}
+
+	authState := func(*http.Request) string { return "" }
+	if _, err := MetricsWithConfig(MetricsConfig{Registerer: reg, AuthState: authState}); err == nil {
+		t.Errorf("registering different labels: got nil error")
+	}
+}
@@ -0,0 +1,218 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juanfont/juango/auth"
+	"github.com/juanfont/juango/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+)
+
+// Rate is a token bucket rate limit: Requests tokens are added every Period,
+// up to Burst.
+type Rate struct {
+	Requests int
+	Period   time.Duration
+
+	// Burst is the bucket size, i.e. how many requests can be made at once
+	// (default: Requests).
+	Burst int
+}
+
+// PerSecond returns the refill rate in tokens per second.
+func (r Rate) PerSecond() float64 {
+	return float64(r.Requests) / r.Period.Seconds()
+}
+
+// BurstSize returns the bucket size.
+func (r Rate) BurstSize() int {
+	if r.Burst > 0 {
+		return r.Burst
+	}
+	return r.Requests
+}
+
+// RateLimitResult is the outcome of taking a token.
+type RateLimitResult struct {
+	Allowed bool
+	// Remaining is the number of whole tokens left.
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next token, if not allowed.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore holds token buckets.
+type RateLimitStore interface {
+	// Take takes a token from the bucket for key.
+	Take(ctx context.Context, key string, rate Rate) (RateLimitResult, error)
+}
+
+// RateLimitConfig configures the rate limit middleware.
+type RateLimitConfig struct {
+	// Name identifies the limiter in bucket keys and the limiter metrics
+	// label (default: "default"). Limiters sharing a store need distinct
+	// names.
+	Name string
+
+	Rate Rate
+
+	// Key returns the bucket key for a request (default: KeyByIP). An empty
+	// key exempts the request.
+	Key func(*http.Request) string
+
+	// Store holds the buckets (default: a new MemoryRateLimitStore). Use a
+	// SQLiteRateLimitStore to keep limits across restarts, or a
+	// RedisRateLimitStore to share them between instances.
+	Store RateLimitStore
+
+	// Registerer is where the rejected requests counter is registered
+	// (default: prometheus.DefaultRegisterer).
+	Registerer prometheus.Registerer
+}
+
+// KeyByIP keys requests by client IP, as returned by auth.GetClientIP.
+func KeyByIP(r *http.Request) string {
+	return "ip:" + auth.GetClientIP(r)
+}
+
+// KeyByUser keys requests by authenticated user ID, falling back to the
+// client IP for anonymous requests. The limiter must run after
+// auth.SessionMiddleware.RequireAuth for the user to be known.
+func KeyByUser(r *http.Request) string {
+	if user := auth.GetUserFromContext(r.Context()); user != nil {
+		return "user:" + user.ID.String()
+	}
+	return KeyByIP(r)
+}
+
+// KeyByRoute keys requests by method and mux route template, giving each
+// route one shared bucket.
+func KeyByRoute(r *http.Request) string {
+	return "route:" + r.Method + " " + routeLabel(r)
+}
+
+// Keys combines key functions, e.g. Keys(KeyByRoute, KeyByIP) for a bucket
+// per route and client. The request is exempt if any key is empty.
+func Keys(fns ...func(*http.Request) string) func(*http.Request) string {
+	return func(r *http.Request) string {
+		parts := make([]string, len(fns))
+		for i, fn := range fns {
+			if parts[i] = fn(r); parts[i] == "" {
+				return ""
+			}
+		}
+		return strings.Join(parts, "|")
+	}
+}
+
+// RateLimit returns a middleware that limits requests with token buckets.
+// Responses carry RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and
+// RateLimit-Policy headers; rejected requests get a 429 with Retry-After
+// and are counted in http_rate_limited_total. If the store fails, requests
+// are allowed and the error is logged.
+//
+// It panics if the rate is invalid or the counter can't be registered.
+func RateLimit(cfg RateLimitConfig) func(http.Handler) http.Handler {
+	if cfg.Rate.Requests <= 0 || cfg.Rate.Period <= 0 {
+		panic(fmt.Sprintf("middleware: invalid rate limit %d per %s", cfg.Rate.Requests, cfg.Rate.Period))
+	}
+	if cfg.Name == "" {
+		cfg.Name = "default"
+	}
+	if cfg.Key == nil {
+		cfg.Key = KeyByIP
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryRateLimitStore()
+	}
+	if cfg.Registerer == nil {
+		cfg.Registerer = prometheus.DefaultRegisterer
+	}
+
+	rejected, err := registerCollector(cfg.Registerer, prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_rate_limited_total",
+			Help: "Total number of requests rejected by rate limiting",
+		},
+		[]string{"limiter"},
+	))
+	if err != nil {
+		panic(err)
+	}
+	rejectedCounter := rejected.WithLabelValues(cfg.Name)
+
+	burst := strconv.Itoa(cfg.Rate.BurstSize())
+	policy := fmt.Sprintf("%s;w=%d", burst, int(math.Ceil(cfg.Rate.Period.Seconds())))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := cfg.Key(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			result, err := cfg.Store.Take(r.Context(), cfg.Name+":"+key, cfg.Rate)
+			if err != nil {
+				zerolog.Ctx(r.Context()).Warn().
+					Err(err).
+					Str("limiter", cfg.Name).
+					Msg("Rate limit store failed, allowing request")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", burst)
+			h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+			h.Set("RateLimit-Policy", policy)
+
+			if !result.Allowed {
+				rejectedCounter.Inc()
+				h.Set("Retry-After", strconv.Itoa(max(ceilSeconds(result.RetryAfter), 1)))
+				types.WriteError(w, r, types.NewHTTPError(http.StatusTooManyRequests, "Too many requests", nil))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// takeToken refills a bucket holding tokens after elapsed and takes one
+// token from it. It returns the new token count and the result.
+func takeToken(tokens float64, elapsed time.Duration, rate Rate) (float64, RateLimitResult) {
+	perSecond := rate.PerSecond()
+	burst := float64(rate.BurstSize())
+
+	tokens = math.Min(burst, tokens+elapsed.Seconds()*perSecond)
+
+	var result RateLimitResult
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsDuration((1 - tokens) / perSecond)
+	}
+	result.Remaining = int(tokens)
+	result.Reset = secondsDuration((burst - tokens) / perSecond)
+	return tokens, result
+}
+
+func secondsDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,223 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/juanfont/juango/database"
+	"github.com/redis/go-redis/v9"
+)
+
+// MemoryRateLimitStore keeps token buckets in process memory.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+type memoryBucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time // when the bucket will be full and can be dropped
+}
+
+// sweepInterval is how often full buckets are dropped from memory.
+const sweepInterval = time.Minute
+
+// NewMemoryRateLimitStore creates an in-memory store. Limits are per
+// process.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets:   make(map[string]*memoryBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Take implements RateLimitStore.
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, rate Rate) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) > sweepInterval {
+		for k, b := range s.buckets {
+			if now.After(b.full) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: float64(rate.BurstSize()), last: now}
+		s.buckets[key] = b
+	}
+
+	tokens, result := takeToken(b.tokens, now.Sub(b.last), rate)
+	b.tokens = tokens
+	b.last = now
+	b.full = now.Add(result.Reset)
+	return result, nil
+}
+
+// rateLimitScript atomically refills and takes from a bucket stored as a
+// hash of tokens and last update time in milliseconds. It expires buckets
+// once they would be full again.
+var rateLimitScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(bucket[1]) or burst
+local ts = tonumber(bucket[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil((burst - tokens) / rate * 1000) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisRateLimitStore keeps token buckets in Redis so that limits are
+// shared between instances. It can use the same Redis as the task queue.
+type RedisRateLimitStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisRateLimitStore creates a Redis store. Keys are prefixed with
+// prefix (default: "juango:ratelimit:").
+func NewRedisRateLimitStore(client redis.UniversalClient, prefix string) *RedisRateLimitStore {
+	if prefix == "" {
+		prefix = "juango:ratelimit:"
+	}
+	return &RedisRateLimitStore{client: client, prefix: prefix}
+}
+
+// Take implements RateLimitStore.
+func (s *RedisRateLimitStore) Take(ctx context.Context, key string, rate Rate) (RateLimitResult, error) {
+	res, err := rateLimitScript.Run(ctx, s.client, []string{s.prefix + key},
+		rate.PerSecond(), rate.BurstSize(), time.Now().UnixMilli(),
+	).Slice()
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	allowed, _ := res[0].(int64)
+	tokensStr, _ := res[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	// Recompute the result from the remaining tokens without refilling.
+	perSecond := rate.PerSecond()
+	result := RateLimitResult{
+		Allowed:   allowed == 1,
+		Remaining: int(tokens),
+		Reset:     secondsDuration((float64(rate.BurstSize()) - tokens) / perSecond),
+	}
+	if !result.Allowed {
+		result.RetryAfter = secondsDuration((1 - tokens) / perSecond)
+	}
+	return result, nil
+}
+
+// sqliteRateLimitSchema stores one row per bucket. Times are Unix
+// nanoseconds; full_at is when the bucket will be full and can be dropped.
+const sqliteRateLimitSchema = `
+CREATE TABLE IF NOT EXISTS rate_limits (
+    key TEXT PRIMARY KEY,
+    tokens REAL NOT NULL,
+    updated_at INTEGER NOT NULL,
+    full_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limits_full_at ON rate_limits(full_at);
+`
+
+// SQLiteRateLimitStore keeps token buckets in a SQLite database so that
+// limits survive restarts and are shared by processes on the same host.
+type SQLiteRateLimitStore struct {
+	db *database.Database
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// NewSQLiteRateLimitStore opens (or creates) the SQLite database at path
+// for rate limit buckets. Use a file of its own rather than the
+// application database, whose schema is managed separately.
+func NewSQLiteRateLimitStore(path string) (*SQLiteRateLimitStore, error) {
+	db, err := database.New(path, sqliteRateLimitSchema)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLiteRateLimitStore{db: db, lastSweep: time.Now()}, nil
+}
+
+// Close closes the database.
+func (s *SQLiteRateLimitStore) Close() error {
+	return s.db.Close()
+}
+
+// Take implements RateLimitStore.
+func (s *SQLiteRateLimitStore) Take(ctx context.Context, key string, rate Rate) (RateLimitResult, error) {
+	now := time.Now()
+	if err := s.sweep(ctx, now); err != nil {
+		return RateLimitResult{}, err
+	}
+
+	var result RateLimitResult
+	err := s.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		var bucket struct {
+			Tokens    float64 `db:"tokens"`
+			UpdatedAt int64   `db:"updated_at"`
+		}
+		err := tx.GetContext(ctx, &bucket, `SELECT tokens, updated_at FROM rate_limits WHERE key = ?`, key)
+		if errors.Is(err, sql.ErrNoRows) {
+			bucket.Tokens = float64(rate.BurstSize())
+			bucket.UpdatedAt = now.UnixNano()
+		} else if err != nil {
+			return err
+		}
+
+		var tokens float64
+		tokens, result = takeToken(bucket.Tokens, now.Sub(time.Unix(0, bucket.UpdatedAt)), rate)
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO rate_limits (key, tokens, updated_at, full_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT(key) DO UPDATE SET tokens = excluded.tokens, updated_at = excluded.updated_at, full_at = excluded.full_at
+		`, key, tokens, now.UnixNano(), now.Add(result.Reset).UnixNano())
+		return err
+	})
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	return result, nil
+}
+
+// sweep drops full buckets at most once per sweepInterval.
+func (s *SQLiteRateLimitStore) sweep(ctx context.Context, now time.Time) error {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) <= sweepInterval {
+		s.mu.Unlock()
+		return nil
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+
+	_, err := s.db.ExecContext(ctx, `DELETE FROM rate_limits WHERE full_at < ?`, now.UnixNano())
+	return err
+}
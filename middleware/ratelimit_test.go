@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRateLimitBucketExhaustion(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	handler := RateLimit(RateLimitConfig{
+		Name:       "test",
+		Rate:       Rate{Requests: 2, Period: time.Minute},
+		Registerer: reg,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/items", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i, wantRemaining := range []string{"1", "0"} {
+		rec := request("192.0.2.1:1234")
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("request %d: status %d, want %d", i+1, rec.Code, http.StatusNoContent)
+		}
+		if got := rec.Header().Get("RateLimit-Remaining"); got != wantRemaining {
+			t.Errorf("request %d: RateLimit-Remaining = %q, want %q", i+1, got, wantRemaining)
+		}
+		if got := rec.Header().Get("Retry-After"); got != "" {
+			t.Errorf("request %d: unexpected Retry-After %q", i+1, got)
+		}
+	}
+
+	rec := request("192.0.2.1:5678")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("exhausted bucket: status %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	for header, want := range map[string]string{
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Policy":    "2;w=60",
+	} {
+		if got := rec.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+	// One token is added every 30s.
+	if got, err := strconv.Atoi(rec.Header().Get("Retry-After")); err != nil || got < 1 || got > 30 {
+		t.Errorf("Retry-After = %q, want 1 to 30 seconds", rec.Header().Get("Retry-After"))
+	}
+	if got, err := strconv.Atoi(rec.Header().Get("RateLimit-Reset")); err != nil || got < 30 || got > 60 {
+		t.Errorf("RateLimit-Reset = %q, want 30 to 60 seconds", rec.Header().Get("RateLimit-Reset"))
+	}
+
+	if got := rejectedCount(t, reg, "test"); got != 1 {
+		t.Errorf("http_rate_limited_total = %v, want 1", got)
+	}
+
+	if rec := request("192.0.2.2:1234"); rec.Code != http.StatusNoContent {
+		t.Errorf("other client: status %d, want %d", rec.Code, http.StatusNoContent)
+	}
+}
+
+func TestRateLimitIgnoresForwardedForWithoutResolver(t *testing.T) {
+	handler := RateLimit(RateLimitConfig{
+		Rate:       Rate{Requests: 1, Period: time.Minute},
+		Registerer: prometheus.NewRegistry(),
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// Rotating X-Forwarded-For must not give a client fresh buckets.
+	for i, forwarded := range []string{"198.51.100.1", "198.51.100.2"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("X-Forwarded-For", forwarded)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		want := http.StatusOK
+		if i > 0 {
+			want = http.StatusTooManyRequests
+		}
+		if rec.Code != want {
+			t.Errorf("request %d: status %d, want %d", i+1, rec.Code, want)
+		}
+	}
+}
+
+// rejectedCount returns the http_rate_limited_total value of limiter.
+func rejectedCount(t *testing.T, reg *prometheus.Registry, limiter string) float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("gathering metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "http_rate_limited_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "limiter" && label.GetValue() == limiter {
+					return metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestSQLiteRateLimitStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimit.db")
+	rate := Rate{Requests: 2, Period: time.Minute}
+	ctx := context.Background()
+
+	store, err := NewSQLiteRateLimitStore(path)
+	if err != nil {
+		t.Fatalf("opening store: %v", err)
+	}
+	for i, wantRemaining := range []int{1, 0} {
+		result, err := store.Take(ctx, "login:192.0.2.1", rate)
+		if err != nil {
+			t.Fatalf("take %d: %v", i+1, err)
+		}
+		if !result.Allowed || result.Remaining != wantRemaining {
+			t.Errorf("take %d = %+v, want allowed with %d remaining", i+1, result, wantRemaining)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("closing store: %v", err)
+	}
+
+	// Buckets survive reopening the store.
+	store, err = NewSQLiteRateLimitStore(path)
+	if err != nil {
+		t.Fatalf("reopening store: %v", err)
+	}
+	defer store.Close()
+
+	result, err := store.Take(ctx, "login:192.0.2.1", rate)
+	if err != nil {
+		t.Fatalf("take after reopen: %v", err)
+	}
+	if result.Allowed || result.RetryAfter <= 0 {
+		t.Errorf("exhausted bucket = %+v, want rejected with Retry-After", result)
+	}
+
+	result, err = store.Take(ctx, "login:192.0.2.2", rate)
+	if err != nil {
+		t.Fatalf("take for other key: %v", err)
+	}
+	if !result.Allowed {
+		t.Errorf("other key = %+v, want allowed", result)
+	}
+}
+
+func TestSQLiteRateLimitStoreSweep(t *testing.T) {
+	store, err := NewSQLiteRateLimitStore(filepath.Join(t.TempDir(), "ratelimit.db"))
+	if err != nil {
+		t.Fatalf("opening store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	if _, err := store.Take(ctx, "fast", Rate{Requests: 1000, Period: time.Second}); err != nil {
+		t.Fatalf("take: %v", err)
+	}
+	if _, err := store.Take(ctx, "slow", Rate{Requests: 1, Period: time.Hour}); err != nil {
+		t.Fatalf("take: %v", err)
+	}
+
+	// The "fast" bucket is full again long before the next sweep.
+	if err := store.sweep(ctx, time.Now().Add(2*sweepInterval)); err != nil {
+		t.Fatalf("sweeping: %v", err)
+	}
+
+	var keys []string
+	if err := store.db.SelectContext(ctx, &keys, `SELECT key FROM rate_limits`); err != nil {
+		t.Fatalf("listing buckets: %v", err)
+	}
+	if len(keys) != 1 || keys[0] != "slow" {
+		t.Errorf("buckets after sweep = %v, want [slow]", keys)
+	}
+}
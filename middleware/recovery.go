@@ -21,7 +21,7 @@ func RecoveryWithLogger(logger zerolog.Logger) func(http.Handler) http.Handler {
 				if err := recover(); err != nil {
 					stack := debug.Stack()
 
-					logger.Error().
+					withRequestFields(logger.Error(), w, r).
 						Interface("panic", err).
 						Str("method", r.Method).
 						Str("path", r.URL.Path).
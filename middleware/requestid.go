@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/juanfont/juango/types"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Request correlation headers.
+const (
+	RequestIDHeader   = "X-Request-ID"
+	TraceParentHeader = "traceparent"
+)
+
+// maxRequestIDLength bounds client-supplied request IDs.
+const maxRequestIDLength = 128
+
+// RequestID returns a middleware that correlates a request across logs,
+// errors, audit entries and background tasks.
+//
+// It accepts an incoming X-Request-ID (or generates a UUID) and a W3C
+// traceparent header (or starts a new trace), stores both in the request
+// context, echoes them in the response headers and attaches them to a
+// zerolog logger available through zerolog.Ctx. Register it before any
+// other middleware; only telemetry.Middleware goes first, so that the IDs
+// match the exported spans when tracing is enabled.
+func RequestID() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(RequestIDHeader)
+			if !validRequestID(requestID) {
+				requestID = uuid.NewString()
+			}
+
+			// Use the active OpenTelemetry span when tracing is enabled.
+			// Otherwise this server's span is a child of the caller's, if any.
+			tc := types.TraceContextFromSpanContext(trace.SpanContextFromContext(r.Context()))
+			if !tc.IsValid() {
+				parent, err := types.ParseTraceParent(r.Header.Get(TraceParentHeader))
+				if err != nil {
+					tc = types.NewTraceContext()
+				} else {
+					tc = parent.Child()
+				}
+			}
+
+			w.Header().Set(RequestIDHeader, requestID)
+			w.Header().Set(TraceParentHeader, tc.String())
+
+			logger := log.Logger.With().
+				Str("request_id", requestID).
+				Str("trace_id", tc.TraceID).
+				Logger()
+
+			ctx := types.ContextWithRequestID(r.Context(), requestID)
+			ctx = types.ContextWithTraceContext(ctx, tc)
+			ctx = logger.WithContext(ctx)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// validRequestID reports whether a client-supplied request ID is safe to
+// log and echo: non-empty, bounded and printable ASCII.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// withRequestFields adds the request and trace IDs to a log event. They
+// are read from the request context, or from the response headers set by
+// RequestID when it runs inside the calling middleware.
+func withRequestFields(event *zerolog.Event, w http.ResponseWriter, r *http.Request) *zerolog.Event {
+	requestID := types.RequestIDFromContext(r.Context())
+	if requestID == "" {
+		requestID = w.Header().Get(RequestIDHeader)
+	}
+	if requestID != "" {
+		event = event.Str("request_id", requestID)
+	}
+
+	tc, ok := types.TraceContextFromContext(r.Context())
+	if !ok {
+		tc, _ = types.ParseTraceParent(w.Header().Get(TraceParentHeader))
+	}
+	if tc.IsValid() {
+		event = event.Str("trace_id", tc.TraceID)
+	}
+	return event
+}
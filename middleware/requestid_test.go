@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/juanfont/juango/types"
+)
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func serveRequestID(t *testing.T, header http.Header) (*httptest.ResponseRecorder, string, types.TraceContext) {
+	t.Helper()
+
+	var requestID string
+	var tc types.TraceContext
+	h := RequestID()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID = types.RequestIDFromContext(r.Context())
+		tc, _ = types.TraceContextFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec, requestID, tc
+}
+
+func TestRequestIDIncoming(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{name: "valid", incoming: "abc-123_DEF.456", keep: true},
+		{name: "max length", incoming: strings.Repeat("a", maxRequestIDLength), keep: true},
+		{name: "missing", incoming: ""},
+		{name: "too long", incoming: strings.Repeat("a", maxRequestIDLength+1)},
+		{name: "space", incoming: "abc 123"},
+		{name: "control character", incoming: "abc\x01123"},
+		{name: "non-ASCII", incoming: "abcé"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.incoming != "" {
+				header.Set(RequestIDHeader, tt.incoming)
+			}
+			rec, requestID, _ := serveRequestID(t, header)
+
+			if tt.keep {
+				if requestID != tt.incoming {
+					t.Errorf("request ID = %q, want %q", requestID, tt.incoming)
+				}
+			} else if _, err := uuid.Parse(requestID); err != nil {
+				t.Errorf("request ID = %q, want a generated UUID", requestID)
+			}
+			if got := rec.Header().Get(RequestIDHeader); got != requestID {
+				t.Errorf("%s header = %q, want %q", RequestIDHeader, got, requestID)
+			}
+		})
+	}
+}
+
+func TestRequestIDGeneratesUniqueIDs(t *testing.T) {
+	_, first, _ := serveRequestID(t, nil)
+	_, second, _ := serveRequestID(t, nil)
+	if first == second {
+		t.Errorf("generated request IDs are equal: %q", first)
+	}
+}
+
+func TestRequestIDTraceParent(t *testing.T) {
+	parent, err := types.ParseTraceParent(testTraceParent)
+	if err != nil {
+		t.Fatalf("parsing traceparent: %v", err)
+	}
+
+	t.Run("continues incoming trace", func(t *testing.T) {
+		header := http.Header{}
+		header.Set(TraceParentHeader, testTraceParent)
+		rec, _, tc := serveRequestID(t, header)
+
+		if tc.TraceID != parent.TraceID {
+			t.Errorf("trace ID = %q, want %q", tc.TraceID, parent.TraceID)
+		}
+		if tc.SpanID == parent.SpanID {
+			t.Errorf("span ID = %q, want a new child span", tc.SpanID)
+		}
+		if got := rec.Header().Get(TraceParentHeader); got != tc.String() {
+			t.Errorf("%s header = %q, want %q", TraceParentHeader, got, tc.String())
+		}
+	})
+
+	t.Run("starts new trace on invalid header", func(t *testing.T) {
+		header := http.Header{}
+		header.Set(TraceParentHeader, "garbage")
+		_, _, tc := serveRequestID(t, header)
+
+		if !tc.IsValid() {
+			t.Fatalf("trace context %+v is not valid", tc)
+		}
+		if tc.TraceID == parent.TraceID {
+			t.Errorf("trace ID reused from an invalid header")
+		}
+	})
+}
@@ -0,0 +1,234 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/juanfont/juango/types"
+	"github.com/rs/zerolog"
+)
+
+// CSPNoncePlaceholder is replaced in SecurityHeadersConfig.ContentSecurityPolicy
+// by a per-request 'nonce-...' source.
+const CSPNoncePlaceholder = "{nonce}"
+
+// SecurityHeadersConfig holds security headers middleware configuration.
+// Empty fields omit the corresponding header.
+type SecurityHeadersConfig struct {
+	// HSTSMaxAge is the Strict-Transport-Security max-age. Zero disables
+	// HSTS. Browsers ignore the header on plain HTTP responses.
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+
+	// ReferrerPolicy is the Referrer-Policy header value.
+	ReferrerPolicy string
+
+	// PermissionsPolicy is the Permissions-Policy header value.
+	PermissionsPolicy string
+
+	// FrameAncestors is the CSP frame-ancestors source list. 'none' and
+	// 'self' also set the equivalent X-Frame-Options for older browsers.
+	FrameAncestors string
+
+	// ContentSecurityPolicy is the CSP, without frame-ancestors and
+	// reporting directives. CSPNoncePlaceholder is replaced by a nonce
+	// that is also stored in the request context for
+	// types.CSPNonceFromContext.
+	ContentSecurityPolicy string
+
+	// ReportOnly sends the CSP as Content-Security-Policy-Report-Only, so
+	// violations are reported but not blocked.
+	ReportOnly bool
+
+	// ReportURI is where browsers send CSP violation reports, e.g. a route
+	// served by CSPReportHandler. The Reporting-Endpoints header needs an
+	// absolute URL, so a path is resolved against the request's host;
+	// behind a TLS-terminating proxy, set the absolute public URL instead.
+	ReportURI string
+}
+
+// DefaultSecurityHeadersConfig returns a strict configuration for an SPA
+// served from the same origin as its API.
+func DefaultSecurityHeadersConfig() *SecurityHeadersConfig {
+	return &SecurityHeadersConfig{
+		HSTSMaxAge:            365 * 24 * time.Hour,
+		HSTSIncludeSubdomains: true,
+		ReferrerPolicy:        "strict-origin-when-cross-origin",
+		PermissionsPolicy:     "camera=(), microphone=(), geolocation=(), payment=(), usb=()",
+		FrameAncestors:        "'none'",
+		ContentSecurityPolicy: "default-src 'self'; " +
+			"script-src 'self' " + CSPNoncePlaceholder + "; " +
+			"style-src 'self' 'unsafe-inline'; " +
+			"img-src 'self' data: https:; " +
+			"font-src 'self' data:; " +
+			"connect-src 'self'; " +
+			"object-src 'none'; " +
+			"base-uri 'self'; " +
+			"form-action 'self'",
+	}
+}
+
+// SecurityHeaders returns a middleware that sets HSTS,
+// X-Content-Type-Options, Referrer-Policy, Permissions-Policy and
+// Content-Security-Policy headers.
+func SecurityHeaders(cfg *SecurityHeadersConfig) func(http.Handler) http.Handler {
+	if cfg == nil {
+		cfg = DefaultSecurityHeadersConfig()
+	}
+
+	var hsts string
+	if cfg.HSTSMaxAge > 0 {
+		hsts = fmt.Sprintf("max-age=%d", int(cfg.HSTSMaxAge.Seconds()))
+		if cfg.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if cfg.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+
+	var frameOptions string
+	switch cfg.FrameAncestors {
+	case "'none'":
+		frameOptions = "DENY"
+	case "'self'":
+		frameOptions = "SAMEORIGIN"
+	}
+
+	var directives []string
+	if cfg.ContentSecurityPolicy != "" {
+		directives = append(directives, strings.TrimRight(strings.TrimSpace(cfg.ContentSecurityPolicy), ";"))
+	}
+	if cfg.FrameAncestors != "" {
+		directives = append(directives, "frame-ancestors "+cfg.FrameAncestors)
+	}
+	if cfg.ReportURI != "" {
+		directives = append(directives, "report-uri "+cfg.ReportURI, "report-to csp-endpoint")
+	}
+	csp := strings.Join(directives, "; ")
+	useNonce := strings.Contains(csp, CSPNoncePlaceholder)
+
+	cspHeader := "Content-Security-Policy"
+	if cfg.ReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("X-Content-Type-Options", "nosniff")
+			if hsts != "" {
+				h.Set("Strict-Transport-Security", hsts)
+			}
+			if cfg.ReferrerPolicy != "" {
+				h.Set("Referrer-Policy", cfg.ReferrerPolicy)
+			}
+			if cfg.PermissionsPolicy != "" {
+				h.Set("Permissions-Policy", cfg.PermissionsPolicy)
+			}
+			if frameOptions != "" {
+				h.Set("X-Frame-Options", frameOptions)
+			}
+			if cfg.ReportURI != "" {
+				h.Set("Reporting-Endpoints", fmt.Sprintf("csp-endpoint=%q", absoluteURL(r, cfg.ReportURI)))
+			}
+
+			if csp != "" {
+				policy := csp
+				if useNonce {
+					nonce := newCSPNonce()
+					policy = strings.ReplaceAll(csp, CSPNoncePlaceholder, "'nonce-"+nonce+"'")
+					r = r.WithContext(types.ContextWithCSPNonce(r.Context(), nonce))
+				}
+				h.Set(cspHeader, policy)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// absoluteURL resolves ref against the URL the request was made to.
+func absoluteURL(r *http.Request, ref string) string {
+	u, err := url.Parse(ref)
+	if err != nil || u.IsAbs() {
+		return ref
+	}
+	base := &url.URL{Scheme: "http", Host: r.Host, Path: r.URL.Path}
+	if r.TLS != nil {
+		base.Scheme = "https"
+	}
+	return base.ResolveReference(u).String()
+}
+
+func newCSPNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// maxCSPReportSize bounds the size of CSP report bodies.
+const maxCSPReportSize = 64 << 10
+
+// CSPReportHandler returns a handler that logs CSP violation reports sent to
+// SecurityHeadersConfig.ReportURI, in both the report-uri
+// (application/csp-report) and Reporting API (application/reports+json)
+// formats.
+func CSPReportHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxCSPReportSize))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		logger := zerolog.Ctx(r.Context())
+		for _, report := range parseCSPReports(body) {
+			logger.Warn().
+				RawJSON("report", report).
+				Str("user_agent", r.UserAgent()).
+				Msg("CSP violation")
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// parseCSPReports returns the violation bodies in a report-uri or
+// Reporting API payload.
+func parseCSPReports(body []byte) []json.RawMessage {
+	var legacy struct {
+		Report json.RawMessage `json:"csp-report"`
+	}
+	if err := json.Unmarshal(body, &legacy); err == nil && legacy.Report != nil {
+		return []json.RawMessage{legacy.Report}
+	}
+
+	var reports []struct {
+		Type string          `json:"type"`
+		Body json.RawMessage `json:"body"`
+	}
+	if err := json.Unmarshal(body, &reports); err != nil {
+		return nil
+	}
+	var out []json.RawMessage
+	for _, report := range reports {
+		if report.Type == "csp-violation" && report.Body != nil {
+			out = append(out, report.Body)
+		}
+	}
+	return out
+}
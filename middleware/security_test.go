@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/juanfont/juango/types"
+)
+
+func TestSecurityHeadersCSPNonce(t *testing.T) {
+	var ctxNonce string
+	handler := SecurityHeaders(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctxNonce = types.CSPNonceFromContext(r.Context())
+	}))
+
+	seen := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		csp := rec.Header().Get("Content-Security-Policy")
+		if ctxNonce == "" {
+			t.Fatalf("no nonce in the request context")
+		}
+		if !strings.Contains(csp, "script-src 'self' 'nonce-"+ctxNonce+"'") {
+			t.Errorf("CSP %q doesn't carry the context nonce %q", csp, ctxNonce)
+		}
+		if strings.Contains(csp, CSPNoncePlaceholder) {
+			t.Errorf("CSP %q still has the placeholder", csp)
+		}
+		if seen[ctxNonce] {
+			t.Errorf("nonce %q reused", ctxNonce)
+		}
+		seen[ctxNonce] = true
+	}
+}
+
+func TestSecurityHeadersDefaults(t *testing.T) {
+	rec := httptest.NewRecorder()
+	SecurityHeaders(nil)(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	for header, want := range map[string]string{
+		"X-Content-Type-Options":    "nosniff",
+		"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+		"Referrer-Policy":           "strict-origin-when-cross-origin",
+		"X-Frame-Options":           "DENY",
+		"Reporting-Endpoints":       "",
+	} {
+		if got := rec.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+	if csp := rec.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "frame-ancestors 'none'") || strings.Contains(csp, "report-uri") {
+		t.Errorf("Content-Security-Policy = %q", csp)
+	}
+}
+
+func TestSecurityHeadersReporting(t *testing.T) {
+	tests := []struct {
+		name      string
+		reportURI string
+		tls       bool
+		want      string
+	}{
+		{
+			name:      "path over HTTP",
+			reportURI: "/api/csp-report",
+			want:      `csp-endpoint="http://app.example.com/api/csp-report"`,
+		},
+		{
+			name:      "path over HTTPS",
+			reportURI: "/api/csp-report",
+			tls:       true,
+			want:      `csp-endpoint="https://app.example.com/api/csp-report"`,
+		},
+		{
+			name:      "absolute URL",
+			reportURI: "https://reports.example.com/csp",
+			want:      `csp-endpoint="https://reports.example.com/csp"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultSecurityHeadersConfig()
+			cfg.ReportURI = tt.reportURI
+			cfg.ReportOnly = true
+
+			req := httptest.NewRequest(http.MethodGet, "http://app.example.com/some/page", nil)
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			rec := httptest.NewRecorder()
+			SecurityHeaders(cfg)(http.NotFoundHandler()).ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Reporting-Endpoints"); got != tt.want {
+				t.Errorf("Reporting-Endpoints = %q, want %q", got, tt.want)
+			}
+			if rec.Header().Get("Content-Security-Policy") != "" {
+				t.Errorf("report-only policy also sent as enforcing")
+			}
+			csp := rec.Header().Get("Content-Security-Policy-Report-Only")
+			if !strings.Contains(csp, "report-uri "+tt.reportURI) || !strings.Contains(csp, "report-to csp-endpoint") {
+				t.Errorf("Content-Security-Policy-Report-Only = %q", csp)
+			}
+		})
+	}
+}
+
+func TestCSPReportHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		want        int
+		wantReports int
+	}{
+		{
+			name:        "report-uri format",
+			contentType: "application/csp-report",
+			body:        `{"csp-report":{"document-uri":"https://app.example.com/","violated-directive":"script-src"}}`,
+			want:        http.StatusNoContent,
+			wantReports: 1,
+		},
+		{
+			name:        "Reporting API format",
+			contentType: "application/reports+json",
+			body: `[{"type":"csp-violation","body":{"blockedURL":"inline"}},` +
+				`{"type":"deprecation","body":{}},` +
+				`{"type":"csp-violation","body":{"blockedURL":"eval"}}]`,
+			want:        http.StatusNoContent,
+			wantReports: 2,
+		},
+		{
+			name:        "garbage",
+			contentType: "application/json",
+			body:        `not json`,
+			want:        http.StatusNoContent,
+		},
+		{
+			name:   "GET",
+			method: http.MethodGet,
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := len(parseCSPReports([]byte(tt.body))); got != tt.wantReports {
+				t.Errorf("parsed %d reports, want %d", got, tt.wantReports)
+			}
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req := httptest.NewRequest(method, "/api/csp-report", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rec := httptest.NewRecorder()
+			CSPReportHandler()(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
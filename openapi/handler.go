@@ -0,0 +1,170 @@
+package openapi
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+
+	"github.com/juanfont/juango/types"
+	"github.com/rs/zerolog/log"
+)
+
+// Handler returns a handler serving doc as JSON. It panics if doc can't be
+// encoded, e.g. because a Schemer returned a function.
+func Handler(doc *Document) http.Handler {
+	body, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		panic("openapi: encoding document: " + err.Error())
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(body)
+	})
+}
+
+// DocsHandler returns a handler serving a self-contained page that renders
+// the document at specURL. It loads no external assets, and its script
+// carries the request's CSP nonce, so it works under the default
+// SecurityHeaders policy.
+func DocsHandler(specURL string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		err := docsTemplate.Execute(w, struct {
+			SpecURL string
+			Nonce   string
+		}{specURL, types.CSPNonceFromContext(r.Context())})
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to render API docs")
+		}
+	})
+}
+
+// docsTemplate lists the operations by tag, with their parameters and
+// schemas. References to component schemas link to their definitions.
+var docsTemplate = template.Must(template.New("docs").Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>API documentation</title>
+<style>
+body { margin: 0; font: 14px/1.5 system-ui, sans-serif; color: #1f2328; background: #f6f8fa; }
+main { max-width: 960px; margin: 0 auto; padding: 24px; }
+h1 { margin: 0 0 4px; font-size: 24px; }
+h2 { margin: 32px 0 8px; font-size: 18px; border-bottom: 1px solid #d0d7de; }
+details { margin: 8px 0; background: #fff; border: 1px solid #d0d7de; border-radius: 6px; }
+summary { padding: 8px 12px; cursor: pointer; font-family: ui-monospace, monospace; }
+.op-body { padding: 0 12px 12px; }
+.method { display: inline-block; min-width: 64px; font-weight: 600; text-transform: uppercase; }
+.get { color: #0969da; } .post { color: #1a7f37; } .put, .patch { color: #9a6700; } .delete { color: #cf222e; }
+.auth { margin-left: 8px; padding: 0 6px; border-radius: 10px; background: #ddf4ff; font-size: 12px; }
+.muted { color: #656d76; font-family: system-ui, sans-serif; }
+pre { padding: 8px; overflow-x: auto; background: #f6f8fa; border-radius: 4px; }
+table { border-collapse: collapse; }
+td, th { padding: 2px 12px 2px 0; text-align: left; }
+</style>
+</head>
+<body>
+<main id="docs"><p>Loading {{.SpecURL}}…</p></main>
+<script nonce="{{.Nonce}}">
+(async function () {
+  const root = document.getElementById("docs");
+  const el = (tag, attrs, ...children) => {
+    const node = document.createElement(tag);
+    Object.assign(node, attrs || {});
+    node.append(...children.filter((c) => c !== null && c !== undefined));
+    return node;
+  };
+
+  let spec;
+  try {
+    const res = await fetch({{.SpecURL}}, { credentials: "same-origin" });
+    if (!res.ok) throw new Error(res.status + " " + res.statusText);
+    spec = await res.json();
+  } catch (err) {
+    root.replaceChildren(el("p", {}, "Failed to load the API document: " + err.message));
+    return;
+  }
+
+  const schemaBlock = (schema) => {
+    const pre = el("pre");
+    const text = JSON.stringify(schema, null, 2);
+    let last = 0;
+    for (const m of text.matchAll(/"#\/components\/schemas\/([^"]+)"/g)) {
+      pre.append(text.slice(last, m.index), el("a", { href: "#schema-" + m[1] }, m[0]));
+      last = m.index + m[0].length;
+    }
+    pre.append(text.slice(last));
+    return pre;
+  };
+
+  const byTag = new Map();
+  for (const [path, item] of Object.entries(spec.paths || {})) {
+    for (const [method, op] of Object.entries(item)) {
+      for (const tag of op.tags && op.tags.length ? op.tags : ["default"]) {
+        if (!byTag.has(tag)) byTag.set(tag, []);
+        byTag.get(tag).push({ path, method, op });
+      }
+    }
+  }
+
+  const sections = [];
+  for (const [tag, ops] of byTag) {
+    sections.push(el("h2", {}, tag));
+    for (const { path, method, op } of ops) {
+      const body = el("div", { className: "op-body" });
+      if (op.summary) body.append(el("p", {}, op.summary));
+      if (op.parameters && op.parameters.length) {
+        const table = el("table", {}, el("tr", {}, el("th", {}, "Parameter"), el("th", {}, "In"), el("th", {}, "Schema")));
+        for (const p of op.parameters) {
+          table.append(el("tr", {}, el("td", {}, p.name + (p.required ? "" : "?")), el("td", {}, p.in), el("td", {}, JSON.stringify(p.schema))));
+        }
+        body.append(el("h4", {}, "Parameters"), table);
+      }
+      if (op.requestBody) {
+        body.append(el("h4", {}, "Request body"), schemaBlock(op.requestBody.content["application/json"].schema));
+      }
+      for (const [status, res] of Object.entries(op.responses || {})) {
+        body.append(el("h4", {}, "Response " + status + " ", el("span", { className: "muted" }, res.description)));
+        if (res.content) body.append(schemaBlock(Object.values(res.content)[0].schema));
+      }
+      sections.push(el("details", {},
+        el("summary", {},
+          el("span", { className: "method " + method }, method), path,
+          op["x-juango-auth"] ? el("span", { className: "auth" }, op["x-juango-auth"]) : null,
+          " ", el("span", { className: "muted" }, op.operationId)),
+        body));
+    }
+  }
+
+  const schemas = Object.entries((spec.components && spec.components.schemas) || {});
+  if (schemas.length) {
+    sections.push(el("h2", {}, "Schemas"));
+    for (const [name, schema] of schemas.sort(([a], [b]) => a.localeCompare(b))) {
+      sections.push(el("details", { id: "schema-" + name }, el("summary", {}, name), el("div", { className: "op-body" }, schemaBlock(schema))));
+    }
+  }
+
+  const info = spec.info || {};
+  root.replaceChildren(
+    el("h1", {}, info.title || "API"),
+    el("p", { className: "muted" }, "Version " + (info.version || "unknown") + " · OpenAPI " + spec.openapi + " · ", el("a", { href: {{.SpecURL}} }, "JSON")),
+    info.description ? el("p", {}, info.description) : null,
+    ...sections,
+  );
+
+  if (location.hash.startsWith("#schema-")) {
+    const target = document.getElementById(location.hash.slice(1));
+    if (target) target.open = true;
+  }
+  window.addEventListener("hashchange", () => {
+    const target = document.getElementById(location.hash.slice(1));
+    if (target && target.tagName === "DETAILS") target.open = true;
+  });
+})();
+</script>
+</body>
+</html>
+`))
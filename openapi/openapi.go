@@ -0,0 +1,241 @@
+// Package openapi generates an OpenAPI 3.1 document from a route table and
+// serves it, along with an optional documentation page.
+//
+// Request, query and response types are described with JSON Schema derived
+// by reflection, following encoding/json like package tsgen: json tags
+// rename fields, omitempty makes them optional, embedded structs are
+// flattened and non-omitempty pointers are nullable. Named struct types
+// become component schemas. Types whose JSON form reflection can't see
+// implement Schemer.
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/juanfont/juango/routes"
+	"github.com/juanfont/juango/types"
+)
+
+// Version is the OpenAPI version of generated documents.
+const Version = "3.1.0"
+
+// sessionScheme is the name of the session cookie security scheme.
+const sessionScheme = "session"
+
+// Config describes the API in the generated document.
+type Config struct {
+	Title       string
+	Version     string
+	Description string
+
+	// SessionCookie is the name of the session cookie, documented as the
+	// security scheme of routes that require authorization.
+	SessionCookie string
+}
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Tags       []Tag               `json:"tags,omitempty"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info is the API metadata.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Tag groups operations.
+type Tag struct {
+	Name string `json:"name"`
+}
+
+// PathItem holds the operations of a path, keyed by lowercase method.
+type PathItem map[string]*Operation
+
+// Operation describes a route.
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+
+	// Auth is the route's authorization requirement, e.g. "admin_mode",
+	// which the security scheme alone can't express.
+	Auth string `json:"x-juango-auth,omitempty"`
+}
+
+// Parameter is a path or query parameter.
+type Parameter struct {
+	Name     string `json:"name"`
+	In       string `json:"in"`
+	Required bool   `json:"required,omitempty"`
+	Schema   Schema `json:"schema"`
+}
+
+// RequestBody is a JSON request body.
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// MediaType holds the schema of a body.
+type MediaType struct {
+	Schema Schema `json:"schema"`
+}
+
+// Response is a response of an operation.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Components holds the schemas and security schemes referenced by
+// operations.
+type Components struct {
+	Schemas         map[string]Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how requests are authenticated.
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Generate returns the OpenAPI document of rs. It fails if two routes share
+// a method and path, or two Go types map to the same schema name.
+func Generate(cfg Config, rs ...routes.Route) (*Document, error) {
+	doc := &Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:       cfg.Title,
+			Version:     cfg.Version,
+			Description: cfg.Description,
+		},
+		Paths: make(map[string]PathItem),
+	}
+	b := newSchemaBuilder()
+	errorContent := map[string]MediaType{
+		types.ProblemContentType: {Schema: b.schemaOf(types.Problem{})},
+	}
+	errorResponse := func(description string) Response {
+		return Response{Description: description, Content: errorContent}
+	}
+	tags := make(map[string]bool)
+	usesAuth := false
+
+	for _, route := range rs {
+		path := route.ReplacePathParams(func(name string) string { return "{" + name + "}" })
+		method := strings.ToLower(route.Method)
+		item := doc.Paths[path]
+		if item == nil {
+			item = make(PathItem)
+			doc.Paths[path] = item
+		}
+		if _, ok := item[method]; ok {
+			return nil, fmt.Errorf("openapi: duplicate operation %s %s", route.Method, path)
+		}
+
+		op := &Operation{
+			OperationID: route.Name,
+			Summary:     route.Summary,
+			Tags:        route.Tags,
+			Responses:   make(map[string]Response),
+		}
+		for _, tag := range route.Tags {
+			tags[tag] = true
+		}
+
+		for _, name := range route.PathParams() {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   Schema{"type": "string"},
+			})
+		}
+		if route.Query != nil {
+			op.Parameters = append(op.Parameters, b.queryParameters(route.Query)...)
+		}
+
+		if route.Request != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  jsonContent(b.schemaOf(route.Request)),
+			}
+			op.Responses["400"] = errorResponse("Invalid request")
+		}
+
+		if route.Response != nil {
+			op.Responses["200"] = Response{
+				Description: http.StatusText(http.StatusOK),
+				Content:     jsonContent(b.schemaOf(route.Response)),
+			}
+		} else {
+			op.Responses["200"] = Response{Description: http.StatusText(http.StatusOK)}
+		}
+
+		switch route.Auth {
+		case routes.AuthNone:
+		case routes.AuthUser:
+			op.Responses["401"] = errorResponse("Not authenticated")
+		case routes.AuthAdmin:
+			op.Responses["401"] = errorResponse("Not authenticated")
+			op.Responses["403"] = errorResponse("Admin privileges required")
+		case routes.AuthAdminMode:
+			op.Responses["401"] = errorResponse("Not authenticated")
+			op.Responses["403"] = errorResponse("Admin mode must be enabled")
+		}
+		op.Responses["default"] = errorResponse("Error")
+		if route.Auth != routes.AuthNone {
+			op.Security = []map[string][]string{{sessionScheme: {}}}
+			op.Auth = route.Auth.String()
+			usesAuth = true
+		}
+
+		item[method] = op
+	}
+
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	names := make([]string, 0, len(tags))
+	for tag := range tags {
+		names = append(names, tag)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		doc.Tags = append(doc.Tags, Tag{Name: name})
+	}
+
+	doc.Components.Schemas = b.schemas
+	if usesAuth {
+		doc.Components.SecuritySchemes = map[string]SecurityScheme{
+			sessionScheme: {
+				Type:        "apiKey",
+				In:          "cookie",
+				Name:        cfg.SessionCookie,
+				Description: "Session cookie set by the OIDC login",
+			},
+		}
+	}
+	return doc, nil
+}
+
+func jsonContent(schema Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
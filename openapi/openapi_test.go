@@ -0,0 +1,261 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/juanfont/juango/routes"
+	"github.com/juanfont/juango/types"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+type testItem struct {
+	ID      int64             `json:"id"`
+	Name    string            `json:"name"`
+	Note    *string           `json:"note"`
+	Parent  *testItem         `json:"parent,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	OwnerID types.NullUUID    `json:"owner_id"`
+}
+
+type testFilter struct {
+	Query string `json:"q,omitempty"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+type testRename struct {
+	Name string `json:"name"`
+}
+
+// testRoutes covers each kind of parameter, body and auth requirement, and
+// the juango types of the scaffolded app's API.
+var testRoutes = []routes.Route{
+	{Name: "getSession", Method: http.MethodGet, Path: "/api/auth/session",
+		Response: types.SessionResponse{}, Summary: "Get the current session", Tags: []string{"auth"}},
+	{Name: "enableAdminMode", Method: http.MethodPost, Path: "/api/admin/mode/enable",
+		Request: types.AdminModeRequest{}, Response: types.AdminModeEnableResponse{}, Auth: routes.AuthAdmin,
+		Summary: "Enable admin mode", Tags: []string{"admin"}},
+	{Name: "listAuditLogs", Method: http.MethodGet, Path: "/api/admin/audit-logs",
+		Query: types.AuditLogFilter{}, Response: []types.AuditLogEntry{}, Auth: routes.AuthAdminMode,
+		Summary: "List audit log entries", Tags: []string{"audit"}},
+	{Name: "listItems", Method: http.MethodGet, Path: "/api/items",
+		Query: testFilter{}, Response: []testItem{}, Auth: routes.AuthUser, Tags: []string{"items"}},
+	{Name: "getItem", Method: http.MethodGet, Path: "/api/items/{id:[0-9]+}",
+		Response: testItem{}, Auth: routes.AuthUser, Tags: []string{"items"}},
+	{Name: "renameItem", Method: http.MethodPost, Path: "/api/items/{id}/rename",
+		Request: testRename{}, Auth: routes.AuthUser, Tags: []string{"items"}},
+}
+
+var testConfig = Config{
+	Title:         "Test API",
+	Version:       "1.2.3",
+	Description:   "API for tests",
+	SessionCookie: "test_session",
+}
+
+func TestGenerateGolden(t *testing.T) {
+	doc, err := Generate(testConfig, testRoutes...)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	got, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		t.Fatalf("encoding document: %v", err)
+	}
+	got = append(got, '\n')
+
+	validateDocument(t, got)
+
+	// The document is stable across runs.
+	again, _ := Generate(testConfig, testRoutes...)
+	if b, _ := json.MarshalIndent(again, "", "  "); !bytes.Equal(append(b, '\n'), got) {
+		t.Error("Generate output differs between runs")
+	}
+
+	path := filepath.Join("testdata", "openapi.json")
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("document differs from %s (run with -update to accept):\n%s", path, got)
+	}
+}
+
+func TestGenerateDuplicateOperation(t *testing.T) {
+	_, err := Generate(testConfig,
+		routes.Route{Name: "a", Method: http.MethodGet, Path: "/api/items/{id}"},
+		routes.Route{Name: "b", Method: http.MethodGet, Path: "/api/items/{id:[0-9]+}"},
+	)
+	if err == nil || !strings.Contains(err.Error(), "duplicate operation") {
+		t.Errorf("Generate() error = %v, want a duplicate operation", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	doc, err := Generate(testConfig, testRoutes...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	Handler(doc).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var served Document
+	if err := json.Unmarshal(rec.Body.Bytes(), &served); err != nil {
+		t.Fatalf("decoding served document: %v", err)
+	}
+	if served.OpenAPI != Version || len(served.Paths) != len(doc.Paths) {
+		t.Errorf("served document %+v doesn't match the generated one", served.Info)
+	}
+}
+
+func TestDocsHandlerUsesNonce(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/docs", nil)
+	req = req.WithContext(types.ContextWithCSPNonce(req.Context(), "abc123"))
+	rec := httptest.NewRecorder()
+	DocsHandler("/api/openapi.json").ServeHTTP(rec, req)
+
+	body := rec.Body.String()
+	if !strings.Contains(body, `<script nonce="abc123"`) {
+		t.Errorf("docs page script has no nonce:\n%s", body)
+	}
+	if !strings.Contains(body, "/api/openapi.json") {
+		t.Errorf("docs page doesn't load the spec:\n%s", body)
+	}
+}
+
+var pathTemplateRe = regexp.MustCompile(`\{([^}]+)\}`)
+
+// validateDocument checks the structural rules of OpenAPI 3.1 that a
+// generator bug could break: required fields, unique operation IDs, path
+// templates matching path parameters, and references and security
+// requirements resolving to components.
+func validateDocument(t *testing.T, data []byte) {
+	t.Helper()
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Info    struct {
+			Title   string `json:"title"`
+			Version string `json:"version"`
+		} `json:"info"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas         map[string]any `json:"schemas"`
+			SecuritySchemes map[string]struct {
+				Type string `json:"type"`
+				In   string `json:"in"`
+				Name string `json:"name"`
+			} `json:"securitySchemes"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("document is not valid JSON: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.1.") {
+		t.Errorf("openapi = %q, want 3.1.x", doc.OpenAPI)
+	}
+	if doc.Info.Title == "" || doc.Info.Version == "" {
+		t.Error("info.title and info.version are required")
+	}
+	for name, scheme := range doc.Components.SecuritySchemes {
+		if scheme.Type == "apiKey" && (scheme.In == "" || scheme.Name == "") {
+			t.Errorf("apiKey security scheme %q needs in and name", name)
+		}
+	}
+
+	methods := map[string]bool{"get": true, "put": true, "post": true, "delete": true,
+		"options": true, "head": true, "patch": true, "trace": true}
+	operationIDs := make(map[string]bool)
+	for path, item := range doc.Paths {
+		if !strings.HasPrefix(path, "/") {
+			t.Errorf("path %q doesn't start with /", path)
+		}
+		var templated []string
+		for _, m := range pathTemplateRe.FindAllStringSubmatch(path, -1) {
+			templated = append(templated, m[1])
+		}
+
+		for method, raw := range item {
+			if !methods[method] {
+				t.Errorf("%s: unknown operation %q", path, method)
+				continue
+			}
+			var op struct {
+				OperationID string `json:"operationId"`
+				Parameters  []struct {
+					Name     string         `json:"name"`
+					In       string         `json:"in"`
+					Required bool           `json:"required"`
+					Schema   map[string]any `json:"schema"`
+				} `json:"parameters"`
+				Responses map[string]json.RawMessage `json:"responses"`
+				Security  []map[string][]string      `json:"security"`
+			}
+			if err := json.Unmarshal(raw, &op); err != nil {
+				t.Errorf("%s %s: %v", method, path, err)
+				continue
+			}
+			if op.OperationID == "" || operationIDs[op.OperationID] {
+				t.Errorf("%s %s: operationId %q is empty or not unique", method, path, op.OperationID)
+			}
+			operationIDs[op.OperationID] = true
+			if len(op.Responses) == 0 {
+				t.Errorf("%s %s: no responses", method, path)
+			}
+
+			var pathParams []string
+			for _, p := range op.Parameters {
+				switch p.In {
+				case "path":
+					if !p.Required {
+						t.Errorf("%s %s: path parameter %q must be required", method, path, p.Name)
+					}
+					pathParams = append(pathParams, p.Name)
+				case "query", "header", "cookie":
+				default:
+					t.Errorf("%s %s: parameter %q in %q", method, path, p.Name, p.In)
+				}
+				if p.Schema == nil {
+					t.Errorf("%s %s: parameter %q has no schema", method, path, p.Name)
+				}
+			}
+			if strings.Join(pathParams, ",") != strings.Join(templated, ",") {
+				t.Errorf("%s %s: path parameters %v, want %v", method, path, pathParams, templated)
+			}
+
+			for _, req := range op.Security {
+				for name := range req {
+					if _, ok := doc.Components.SecuritySchemes[name]; !ok {
+						t.Errorf("%s %s: unknown security scheme %q", method, path, name)
+					}
+				}
+			}
+		}
+	}
+
+	for _, ref := range regexp.MustCompile(`"\$ref": "([^"]*)"`).FindAllSubmatch(data, -1) {
+		name, ok := strings.CutPrefix(string(ref[1]), "#/components/schemas/")
+		if _, exists := doc.Components.Schemas[name]; !ok || !exists {
+			t.Errorf("unresolved reference %s", ref[1])
+		}
+	}
+}
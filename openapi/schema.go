@@ -0,0 +1,291 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Schema is a JSON Schema.
+type Schema map[string]any
+
+// Schemer is implemented by types whose JSON form reflection can't see,
+// typically json.Marshaler implementations, e.g.
+//
+//	func (NullUUID) OpenAPISchema() map[string]any {
+//		return map[string]any{"type": []string{"string", "null"}, "format": "uuid"}
+//	}
+type Schemer interface {
+	OpenAPISchema() map[string]any
+}
+
+var (
+	schemerType       = reflect.TypeOf((*Schemer)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+	uuidType          = reflect.TypeOf(uuid.UUID{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemaBuilder derives schemas from Go types, collecting named struct
+// types as component schemas.
+type schemaBuilder struct {
+	schemas map[string]Schema
+	types   map[reflect.Type]string
+	names   map[string]reflect.Type
+	err     error
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{
+		schemas: make(map[string]Schema),
+		types:   make(map[reflect.Type]string),
+		names:   make(map[string]reflect.Type),
+	}
+}
+
+// schemaOf returns the schema of v's type.
+func (b *schemaBuilder) schemaOf(v any) Schema {
+	return b.schema(reflect.TypeOf(v))
+}
+
+// schema returns the schema of t, referencing named struct types.
+func (b *schemaBuilder) schema(t reflect.Type) Schema {
+	switch {
+	case t.Kind() != reflect.Pointer && t.Implements(schemerType):
+		return Schema(reflect.Zero(t).Interface().(Schemer).OpenAPISchema())
+	case t == timeType:
+		return Schema{"type": "string", "format": "date-time"}
+	case t == uuidType:
+		return Schema{"type": "string", "format": "uuid"}
+	case t == rawMessageType:
+		return Schema{}
+	case implements(t, jsonMarshalerType):
+		if implements(t, textMarshalerType) {
+			return Schema{"type": "string"}
+		}
+		return Schema{}
+	case implements(t, textMarshalerType):
+		return Schema{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Pointer:
+		return b.schema(t.Elem())
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return Schema{"type": "string", "contentEncoding": "base64"}
+		}
+		return Schema{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		return Schema{"$ref": "#/components/schemas/" + b.register(t)}
+	}
+	return Schema{}
+}
+
+// register adds a named struct type to the component schemas.
+func (b *schemaBuilder) register(t reflect.Type) string {
+	if name, ok := b.types[t]; ok {
+		return name
+	}
+
+	name := typeName(t)
+	if other, ok := b.names[name]; ok {
+		if b.err == nil {
+			b.err = fmt.Errorf("openapi: %s and %s both map to the schema name %s", other, t, name)
+		}
+		return name
+	}
+	// Record the name before walking the fields so recursive types
+	// terminate.
+	b.types[t] = name
+	b.names[name] = t
+	b.schemas[name] = b.object(t)
+	return name
+}
+
+// object returns the object schema of struct type t.
+func (b *schemaBuilder) object(t reflect.Type) Schema {
+	props := make(map[string]Schema)
+	var required []string
+	for _, f := range b.fields(t) {
+		props[f.name] = f.schema
+		if !f.optional {
+			required = append(required, f.name)
+		}
+	}
+	s := Schema{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// queryParameters returns a query parameter per JSON field of v's struct
+// type.
+func (b *schemaBuilder) queryParameters(v any) []Parameter {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var params []Parameter
+	for _, f := range b.fields(t) {
+		params = append(params, Parameter{
+			Name:     f.name,
+			In:       "query",
+			Required: !f.optional,
+			Schema:   f.schema,
+		})
+	}
+	return params
+}
+
+// field is a JSON object member.
+type field struct {
+	name     string
+	schema   Schema
+	optional bool
+	depth    int
+}
+
+// fields returns the JSON members of struct type t in declaration order,
+// with embedded structs flattened. As in encoding/json, a field shadows
+// fields of the same name nested deeper.
+func (b *schemaBuilder) fields(t reflect.Type) []field {
+	var all []field
+	depths := make(map[string]int)
+	b.collectFields(t, 0, &all, depths)
+
+	out := all[:0]
+	for _, f := range all {
+		if f.depth == depths[f.name] {
+			out = append(out, f)
+			depths[f.name] = -1 // keep the first at that depth only
+		}
+	}
+	return out
+}
+
+func (b *schemaBuilder) collectFields(t reflect.Type, depth int, out *[]field, depths map[string]int) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		ft := sf.Type
+		if sf.Anonymous && name == "" {
+			et := ft
+			if et.Kind() == reflect.Pointer {
+				et = et.Elem()
+			}
+			if et.Kind() == reflect.Struct {
+				b.collectFields(et, depth+1, out, depths)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if d, ok := depths[name]; !ok || depth < d {
+			depths[name] = depth
+		}
+
+		f := field{name: name, depth: depth, optional: hasOption(opts, "omitempty") || hasOption(opts, "omitzero")}
+		switch {
+		case hasOption(opts, "string") && isStringable(ft):
+			f.schema = Schema{"type": "string"}
+		default:
+			f.schema = b.schema(ft)
+			if ft.Kind() == reflect.Pointer && !f.optional {
+				f.schema = nullable(f.schema)
+			}
+		}
+		*out = append(*out, f)
+	}
+}
+
+// nullable returns a schema that also allows null.
+func nullable(s Schema) Schema {
+	if typ, ok := s["type"].(string); ok {
+		out := make(Schema, len(s))
+		for k, v := range s {
+			out[k] = v
+		}
+		out["type"] = []string{typ, "null"}
+		return out
+	}
+	return Schema{"anyOf": []Schema{s, {"type": "null"}}}
+}
+
+// implements reports whether t or *t implements iface.
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PointerTo(t).Implements(iface)
+}
+
+// typeName returns the schema name of a named Go type. Type arguments of
+// generic types are folded into the name.
+func typeName(t reflect.Type) string {
+	name := t.Name()
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		args := name[i+1 : len(name)-1]
+		name = name[:i]
+		for _, arg := range strings.Split(args, ",") {
+			arg = arg[strings.LastIndexAny(arg, "./")+1:]
+			name += strings.ToUpper(arg[:1]) + arg[1:]
+		}
+	}
+	return name
+}
+
+func hasOption(opts, name string) bool {
+	for _, opt := range strings.Split(opts, ",") {
+		if opt == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isStringable reports whether the json string option applies to t.
+func isStringable(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
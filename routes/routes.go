@@ -0,0 +1,225 @@
+// Package routes describes JSON API routes as data, so that tools such as
+// `juango gen ts` can list them without starting the application, and binds
+// them to handlers on a gorilla/mux router.
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/gorilla/mux"
+)
+
+// Route describes an API route.
+type Route struct {
+	// Name identifies the route and names its generated client function,
+	// e.g. "getSession".
+	Name string
+
+	// Method is the HTTP method.
+	Method string
+
+	// Path is a gorilla/mux path template, e.g. "/api/items/{id}".
+	Path string
+
+	// Request is a value of the JSON request body type, or nil.
+	Request any
+
+	// Query is a value of a struct type whose JSON fields are the query
+	// parameters, or nil.
+	Query any
+
+	// Response is a value of the JSON response body type, or nil.
+	Response any
+
+	// Auth is the authorization the route requires. A Binder with an
+	// Authorizer enforces it.
+	Auth Auth
+
+	// Summary is a short description for API documentation.
+	Summary string
+
+	// Tags group the route in API documentation.
+	Tags []string
+}
+
+// Auth is the authorization a route requires.
+type Auth int
+
+const (
+	// AuthNone allows anonymous requests.
+	AuthNone Auth = iota
+	// AuthUser requires a logged-in user (RequireAuth).
+	AuthUser
+	// AuthAdmin requires an admin (RequireAuth and RequireAdmin).
+	AuthAdmin
+	// AuthAdminMode requires an admin with admin mode enabled (RequireAuth
+	// and RequireAdminMode).
+	AuthAdminMode
+)
+
+// String returns the name of the requirement, e.g. "admin_mode".
+func (a Auth) String() string {
+	switch a {
+	case AuthNone:
+		return "none"
+	case AuthUser:
+		return "user"
+	case AuthAdmin:
+		return "admin"
+	case AuthAdminMode:
+		return "admin_mode"
+	}
+	return fmt.Sprintf("Auth(%d)", int(a))
+}
+
+// Authorizer provides the middleware that enforces route authorization,
+// e.g. *auth.SessionMiddleware.
+type Authorizer interface {
+	RequireAuth(next http.HandlerFunc) http.HandlerFunc
+	RequireAdmin(next http.HandlerFunc) http.HandlerFunc
+	RequireAdminMode(next http.HandlerFunc) http.HandlerFunc
+}
+
+// PathParams returns the names of the variables in the route's path.
+func (r Route) PathParams() []string {
+	var params []string
+	for _, m := range pathParamRe.FindAllStringSubmatch(r.Path, -1) {
+		params = append(params, m[1])
+	}
+	return params
+}
+
+// ReplacePathParams returns the route's path with each variable, including
+// any pattern, replaced by fn(name).
+func (r Route) ReplacePathParams(fn func(name string) string) string {
+	return pathParamRe.ReplaceAllStringFunc(r.Path, func(m string) string {
+		return fn(pathParamRe.FindStringSubmatch(m)[1])
+	})
+}
+
+// pathParamRe matches "{name}" and "{name:pattern}" path variables.
+var pathParamRe = regexp.MustCompile(`\{([^}:]+)(?::[^}]*)?\}`)
+
+// Table is an ordered set of routes with unique names.
+type Table struct {
+	routes []Route
+	byName map[string]int
+}
+
+// NewTable creates a route table. It panics if a route has no name, method
+// or path, or if two routes share a name, like http.ServeMux does for
+// conflicting patterns.
+func NewTable(routes ...Route) *Table {
+	t := &Table{byName: make(map[string]int, len(routes))}
+	for _, route := range routes {
+		t.Add(route)
+	}
+	return t
+}
+
+// Add appends a route. It panics under the same conditions as NewTable, or
+// if the route's Auth is unknown.
+func (t *Table) Add(route Route) {
+	if route.Name == "" || route.Method == "" || route.Path == "" {
+		panic(fmt.Sprintf("routes: route %+v needs a name, method and path", route))
+	}
+	if route.Auth < AuthNone || route.Auth > AuthAdminMode {
+		panic(fmt.Sprintf("routes: route %q has unknown auth %v", route.Name, route.Auth))
+	}
+	if _, ok := t.byName[route.Name]; ok {
+		panic(fmt.Sprintf("routes: duplicate route name %q", route.Name))
+	}
+	t.byName[route.Name] = len(t.routes)
+	t.routes = append(t.routes, route)
+}
+
+// Routes returns the routes in the order they were added.
+func (t *Table) Routes() []Route {
+	return append([]Route(nil), t.routes...)
+}
+
+// Route returns the route with the given name.
+func (t *Table) Route(name string) (Route, bool) {
+	i, ok := t.byName[name]
+	if !ok {
+		return Route{}, false
+	}
+	return t.routes[i], true
+}
+
+// Bind returns a Binder that registers the table's routes on router.
+func (t *Table) Bind(router *mux.Router) *Binder {
+	return &Binder{
+		table:  t,
+		router: router,
+		bound:  make(map[string]bool, len(t.routes)),
+	}
+}
+
+// Binder registers the routes of a Table on a router.
+type Binder struct {
+	table  *Table
+	router *mux.Router
+	auth   Authorizer
+	bound  map[string]bool
+}
+
+// WithAuth makes the binder wrap each handler in the middleware for its
+// route's Auth requirement, so that the table is the single source of truth
+// for both enforcement and documentation.
+func (b *Binder) WithAuth(auth Authorizer) *Binder {
+	b.auth = auth
+	return b
+}
+
+// Handle registers handler for the named route, restricted to the route's
+// method. It panics if the table has no such route, it is already bound, or
+// it requires authorization and the binder has no Authorizer.
+func (b *Binder) Handle(name string, handler http.Handler) *mux.Route {
+	route, ok := b.table.Route(name)
+	if !ok {
+		panic(fmt.Sprintf("routes: unknown route %q", name))
+	}
+	if b.bound[name] {
+		panic(fmt.Sprintf("routes: route %q bound twice", name))
+	}
+	if route.Auth != AuthNone {
+		if b.auth == nil {
+			panic(fmt.Sprintf("routes: route %q requires %v auth but the binder has no Authorizer", name, route.Auth))
+		}
+		handler = b.authorize(route.Auth, handler)
+	}
+	b.bound[name] = true
+	return b.router.Handle(route.Path, handler).Methods(route.Method).Name(route.Name)
+}
+
+// authorize wraps handler in the middleware enforcing auth.
+func (b *Binder) authorize(auth Auth, handler http.Handler) http.Handler {
+	next := handler.ServeHTTP
+	switch auth {
+	case AuthAdmin:
+		next = b.auth.RequireAdmin(next)
+	case AuthAdminMode:
+		next = b.auth.RequireAdminMode(next)
+	}
+	return b.auth.RequireAuth(next)
+}
+
+// HandleFunc registers handler for the named route, like Handle.
+func (b *Binder) HandleFunc(name string, handler http.HandlerFunc) *mux.Route {
+	return b.Handle(name, handler)
+}
+
+// Unbound returns the names of the routes without a handler, e.g. to fail
+// startup when a described route was never implemented.
+func (b *Binder) Unbound() []string {
+	var names []string
+	for _, route := range b.table.routes {
+		if !b.bound[route.Name] {
+			names = append(names, route.Name)
+		}
+	}
+	return names
+}
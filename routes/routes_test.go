@@ -0,0 +1,247 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+	"github.com/juanfont/juango/auth"
+)
+
+func testTable() *Table {
+	return NewTable(
+		Route{Name: "listItems", Method: http.MethodGet, Path: "/api/items"},
+		Route{Name: "getItem", Method: http.MethodGet, Path: "/api/items/{id:[0-9]+}"},
+		Route{Name: "deleteItem", Method: http.MethodDelete, Path: "/api/items/{id:[0-9]+}", Auth: AuthAdmin},
+	)
+}
+
+func TestPathParams(t *testing.T) {
+	route := Route{Path: "/api/{org}/items/{id:[0-9]+}/{rest:.*}"}
+	if got, want := route.PathParams(), []string{"org", "id", "rest"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PathParams() = %v, want %v", got, want)
+	}
+	got := route.ReplacePathParams(func(name string) string { return ":" + name })
+	if want := "/api/:org/items/:id/:rest"; got != want {
+		t.Errorf("ReplacePathParams() = %q, want %q", got, want)
+	}
+}
+
+func TestTableRejectsInvalidRoutes(t *testing.T) {
+	tests := []struct {
+		name   string
+		routes []Route
+	}{
+		{name: "no name", routes: []Route{{Method: http.MethodGet, Path: "/a"}}},
+		{name: "no method", routes: []Route{{Name: "a", Path: "/a"}}},
+		{name: "no path", routes: []Route{{Name: "a", Method: http.MethodGet}}},
+		{name: "unknown auth", routes: []Route{{Name: "a", Method: http.MethodGet, Path: "/a", Auth: AuthAdminMode + 1}}},
+		{name: "duplicate name", routes: []Route{
+			{Name: "a", Method: http.MethodGet, Path: "/a"},
+			{Name: "a", Method: http.MethodPost, Path: "/b"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("NewTable did not panic")
+				}
+			}()
+			NewTable(tt.routes...)
+		})
+	}
+}
+
+func TestBinderUnbound(t *testing.T) {
+	b := testTable().Bind(mux.NewRouter())
+	if got, want := b.Unbound(), []string{"listItems", "getItem", "deleteItem"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Unbound() = %v, want %v", got, want)
+	}
+
+	b.HandleFunc("getItem", func(http.ResponseWriter, *http.Request) {})
+	if got, want := b.Unbound(), []string{"listItems", "deleteItem"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Unbound() = %v, want %v", got, want)
+	}
+
+	b.HandleFunc("listItems", func(http.ResponseWriter, *http.Request) {})
+	if got, want := b.Unbound(), []string{"deleteItem"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Unbound() = %v, want %v", got, want)
+	}
+
+	b.WithAuth(&recordingAuthorizer{}).HandleFunc("deleteItem", func(http.ResponseWriter, *http.Request) {})
+	if got := b.Unbound(); len(got) != 0 {
+		t.Errorf("Unbound() = %v, want none", got)
+	}
+}
+
+func TestBinderHandlePanics(t *testing.T) {
+	tests := []struct {
+		name  string
+		bind  []string
+		route string
+	}{
+		{name: "unknown route", route: "createItem"},
+		{name: "bound twice", bind: []string{"getItem"}, route: "getItem"},
+		{name: "auth without authorizer", route: "deleteItem"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := testTable().Bind(mux.NewRouter())
+			for _, name := range tt.bind {
+				b.HandleFunc(name, func(http.ResponseWriter, *http.Request) {})
+			}
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Handle(%q) did not panic", tt.route)
+				}
+			}()
+			b.HandleFunc(tt.route, func(http.ResponseWriter, *http.Request) {})
+		})
+	}
+}
+
+func TestBinderRestrictsMethod(t *testing.T) {
+	router := mux.NewRouter()
+	b := testTable().Bind(router)
+	b.HandleFunc("getItem", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("item " + mux.Vars(r)["id"]))
+	})
+
+	tests := []struct {
+		method, path string
+		wantStatus   int
+	}{
+		{http.MethodGet, "/api/items/42", http.StatusOK},
+		{http.MethodDelete, "/api/items/42", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/api/items/abc", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, rec.Code, tt.wantStatus)
+		}
+	}
+	if name := router.Get("getItem").GetName(); name != "getItem" {
+		t.Errorf("route name = %q, want getItem", name)
+	}
+}
+
+// recordingAuthorizer records the middleware a request passes through and
+// rejects requests without an X-User header.
+type recordingAuthorizer struct {
+	calls []string
+}
+
+func (a *recordingAuthorizer) wrap(name string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a.calls = append(a.calls, name)
+		if r.Header.Get("X-User") == "" {
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (a *recordingAuthorizer) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return a.wrap("auth", next)
+}
+
+func (a *recordingAuthorizer) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return a.wrap("admin", next)
+}
+
+func (a *recordingAuthorizer) RequireAdminMode(next http.HandlerFunc) http.HandlerFunc {
+	return a.wrap("admin_mode", next)
+}
+
+func TestBinderWithAuth(t *testing.T) {
+	table := NewTable(
+		Route{Name: "public", Method: http.MethodGet, Path: "/public"},
+		Route{Name: "user", Method: http.MethodGet, Path: "/user", Auth: AuthUser},
+		Route{Name: "admin", Method: http.MethodGet, Path: "/admin", Auth: AuthAdmin},
+		Route{Name: "adminMode", Method: http.MethodGet, Path: "/admin-mode", Auth: AuthAdminMode},
+	)
+
+	tests := []struct {
+		path      string
+		wantCalls []string
+	}{
+		{path: "/public"},
+		{path: "/user", wantCalls: []string{"auth"}},
+		{path: "/admin", wantCalls: []string{"auth", "admin"}},
+		{path: "/admin-mode", wantCalls: []string{"auth", "admin_mode"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			authz := &recordingAuthorizer{}
+			router := mux.NewRouter()
+			b := table.Bind(router).WithAuth(authz)
+			for _, route := range table.Routes() {
+				b.HandleFunc(route.Name, func(w http.ResponseWriter, r *http.Request) {})
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("X-User", "alice")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Errorf("status %d, want 200", rec.Code)
+			}
+			if !reflect.DeepEqual(authz.calls, tt.wantCalls) {
+				t.Errorf("middleware = %v, want %v", authz.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+// TestBinderWithAuthRejectsAnonymous binds every kind of route with the real
+// session middleware and checks that anonymous requests never reach the
+// handlers of routes that require authorization.
+func TestBinderWithAuthRejectsAnonymous(t *testing.T) {
+	store := sessions.NewCookieStore([]byte("0123456789abcdef0123456789abcdef"))
+	sessionMiddleware := auth.NewSessionMiddleware(store, "session", nil, nil, time.Hour)
+
+	table := NewTable(
+		Route{Name: "public", Method: http.MethodGet, Path: "/public"},
+		Route{Name: "user", Method: http.MethodGet, Path: "/user", Auth: AuthUser},
+		Route{Name: "admin", Method: http.MethodPost, Path: "/admin", Auth: AuthAdmin},
+		Route{Name: "adminMode", Method: http.MethodGet, Path: "/admin-mode/{id}", Auth: AuthAdminMode},
+	)
+	router := mux.NewRouter()
+	b := table.Bind(router).WithAuth(sessionMiddleware)
+	reached := make(map[string]bool)
+	for _, route := range table.Routes() {
+		b.HandleFunc(route.Name, func(w http.ResponseWriter, r *http.Request) {
+			reached[route.Name] = true
+		})
+	}
+
+	for _, route := range table.Routes() {
+		t.Run(route.Name, func(t *testing.T) {
+			path := route.ReplacePathParams(func(string) string { return "1" })
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(route.Method, path, nil))
+
+			if route.Auth == AuthNone {
+				if rec.Code != http.StatusOK || !reached[route.Name] {
+					t.Errorf("public route: status %d, reached %v, want 200 and reached", rec.Code, reached[route.Name])
+				}
+				return
+			}
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("status %d, want 401", rec.Code)
+			}
+			if reached[route.Name] {
+				t.Error("anonymous request reached the handler")
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/hibiken/asynq"
+	"github.com/juanfont/juango/types"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of task spans.
+const tracerName = "github.com/juanfont/juango/tasks"
+
+// MetadataKey is the envelope key holding task metadata. Payloads enqueued
+// with metadata are wrapped as {"_meta": {...}, "payload": <payload>}; the
+// Server unwraps them before calling handlers, so handlers always see the
+// original payload.
+const MetadataKey = "_meta"
+
+// Metadata carries request correlation data from the enqueuing request to
+// the task handler.
+type Metadata struct {
+	RequestID   string `json:"request_id,omitempty"`
+	TraceParent string `json:"traceparent,omitempty"`
+}
+
+// MetadataFromContext builds task metadata from the request ID and trace
+// context stored in ctx. An active OpenTelemetry span takes precedence
+// over the trace context set by the request ID middleware.
+func MetadataFromContext(ctx context.Context) Metadata {
+	meta := Metadata{RequestID: types.RequestIDFromContext(ctx)}
+	if tc := types.TraceContextFromSpanContext(trace.SpanContextFromContext(ctx)); tc.IsValid() {
+		meta.TraceParent = tc.String()
+	} else if tc, ok := types.TraceContextFromContext(ctx); ok {
+		meta.TraceParent = tc.String()
+	}
+	return meta
+}
+
+// IsZero reports whether the metadata is empty.
+func (m Metadata) IsZero() bool {
+	return m == Metadata{}
+}
+
+// Context returns a copy of ctx carrying the metadata's request ID and a
+// child of its trace context. The trace context is also set as the remote
+// parent for OpenTelemetry spans.
+func (m Metadata) Context(ctx context.Context) context.Context {
+	if m.RequestID != "" {
+		ctx = types.ContextWithRequestID(ctx, m.RequestID)
+	}
+	if tc, err := types.ParseTraceParent(m.TraceParent); err == nil {
+		ctx = trace.ContextWithRemoteSpanContext(ctx, tc.SpanContext())
+		ctx = types.ContextWithTraceContext(ctx, tc.Child())
+	}
+	return ctx
+}
+
+func (m Metadata) logFields() map[string]interface{} {
+	fields := make(map[string]interface{}, 2)
+	if m.RequestID != "" {
+		fields["request_id"] = m.RequestID
+	}
+	if tc, err := types.ParseTraceParent(m.TraceParent); err == nil {
+		fields["trace_id"] = tc.TraceID
+	}
+	return fields
+}
+
+// contextLogFields returns the request_id and trace_id log fields from ctx.
+func contextLogFields(ctx context.Context) map[string]interface{} {
+	fields := make(map[string]interface{}, 2)
+	if id := types.RequestIDFromContext(ctx); id != "" {
+		fields["request_id"] = id
+	}
+	if tc, ok := types.TraceContextFromContext(ctx); ok {
+		fields["trace_id"] = tc.TraceID
+	}
+	return fields
+}
+
+// envelope is the wire format of a payload carrying metadata.
+type envelope struct {
+	Meta    *Metadata       `json:"_meta"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// wrapPayload wraps data in an envelope carrying meta. Metadata already
+// present in data (e.g. a payload re-enqueued as-is) is replaced. Empty
+// metadata leaves the payload unwrapped.
+func wrapPayload(data []byte, meta Metadata) ([]byte, error) {
+	_, data = unwrapPayload(data)
+	if meta.IsZero() {
+		return data, nil
+	}
+	return json.Marshal(envelope{Meta: &meta, Payload: data})
+}
+
+// unwrapPayload returns the metadata and inner payload of an envelope. Data
+// that isn't an envelope is returned unchanged with empty metadata.
+func unwrapPayload(data []byte) (Metadata, []byte) {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte(`{"`+MetadataKey+`"`)) {
+		return Metadata{}, data
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var env envelope
+	if err := dec.Decode(&env); err != nil || dec.More() {
+		return Metadata{}, data
+	}
+	if env.Meta == nil || env.Payload == nil {
+		return Metadata{}, data
+	}
+	return *env.Meta, env.Payload
+}
+
+// metadataMiddleware unwraps enveloped payloads, restores the request ID
+// and trace context into the handler's context and records a consumer span
+// for the task. Unwrapped tasks are rebuilt from their type and inner
+// payload, so handlers of tasks enqueued with metadata get a nil
+// ResultWriter.
+func metadataMiddleware(next asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		meta, payload := unwrapPayload(task.Payload())
+		if !meta.IsZero() {
+			task = asynq.NewTask(task.Type(), payload)
+		}
+		ctx = meta.Context(ctx)
+
+		ctx, span := otel.Tracer(tracerName).Start(ctx, "process "+task.Type(),
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(
+				semconv.MessagingSystemKey.String("asynq"),
+				semconv.MessagingOperationTypeProcess,
+				semconv.MessagingDestinationName(task.Type()),
+			),
+		)
+		defer span.End()
+		if tc := types.TraceContextFromSpanContext(span.SpanContext()); tc.IsValid() {
+			ctx = types.ContextWithTraceContext(ctx, tc)
+		}
+
+		ctx = log.Logger.With().Fields(contextLogFields(ctx)).Logger().WithContext(ctx)
+
+		err := next.ProcessTask(ctx, task)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return err
+	})
+}
@@ -0,0 +1,171 @@
+package tasks
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hibiken/asynq"
+	"github.com/juanfont/juango/types"
+)
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestPayloadMetadataRoundTrip(t *testing.T) {
+	meta := Metadata{RequestID: "req-1", TraceParent: testTraceParent}
+
+	tests := []struct {
+		name    string
+		payload string
+	}{
+		{name: "object", payload: `{"to":"a@example.com"}`},
+		{name: "empty object", payload: `{}`},
+		{name: "array", payload: `[1,2,3]`},
+		{name: "string", payload: `"hello"`},
+		{name: "number", payload: `42`},
+		{name: "null", payload: `null`},
+		{name: "object with payload field", payload: `{"payload":"x"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := wrapPayload([]byte(tt.payload), meta)
+			if err != nil {
+				t.Fatalf("wrapping payload: %v", err)
+			}
+
+			got, rest := unwrapPayload(data)
+			if got != meta {
+				t.Errorf("metadata = %+v, want %+v (wire %s)", got, meta, data)
+			}
+			if string(rest) != tt.payload {
+				t.Errorf("payload = %s, want %s", rest, tt.payload)
+			}
+		})
+	}
+}
+
+func TestWrapPayloadWithoutMetadata(t *testing.T) {
+	payload := `{"to":"a@example.com"}`
+	data, err := wrapPayload([]byte(payload), Metadata{})
+	if err != nil {
+		t.Fatalf("wrapping payload: %v", err)
+	}
+	if string(data) != payload {
+		t.Errorf("payload = %s, want it unchanged", data)
+	}
+}
+
+func TestWrapPayloadReplacesExisting(t *testing.T) {
+	stale, err := wrapPayload([]byte(`{"to":"a@example.com"}`), Metadata{RequestID: "stale"})
+	if err != nil {
+		t.Fatalf("wrapping payload: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		meta     Metadata
+		want     Metadata
+		wantWire string
+	}{
+		{
+			name:     "new metadata",
+			meta:     Metadata{RequestID: "new"},
+			want:     Metadata{RequestID: "new"},
+			wantWire: `{"_meta":{"request_id":"new"},"payload":{"to":"a@example.com"}}`,
+		},
+		{
+			name:     "no metadata",
+			meta:     Metadata{},
+			want:     Metadata{},
+			wantWire: `{"to":"a@example.com"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := wrapPayload(stale, tt.meta)
+			if err != nil {
+				t.Fatalf("wrapping payload: %v", err)
+			}
+			if string(data) != tt.wantWire {
+				t.Errorf("wire = %s, want %s", data, tt.wantWire)
+			}
+
+			meta, rest := unwrapPayload(data)
+			if meta != tt.want {
+				t.Errorf("metadata = %+v, want %+v", meta, tt.want)
+			}
+			if string(rest) != `{"to":"a@example.com"}` {
+				t.Errorf("payload = %s", rest)
+			}
+		})
+	}
+}
+
+func TestUnwrapPayloadIgnoresNonEnvelopes(t *testing.T) {
+	tests := []string{
+		`{"to":"a@example.com"}`,
+		`{"_meta":{"request_id":"x"},"to":"a@example.com"}`,
+		`{"_meta":{"request_id":"x"}}`,
+		`{"_meta":"x","payload":{}}`,
+		`{"_meta":{"request_id":"x"},"payload":{}} {}`,
+		`not json`,
+	}
+	for _, payload := range tests {
+		t.Run(payload, func(t *testing.T) {
+			meta, rest := unwrapPayload([]byte(payload))
+			if !meta.IsZero() {
+				t.Errorf("metadata = %+v, want none", meta)
+			}
+			if string(rest) != payload {
+				t.Errorf("payload = %s, want it unchanged", rest)
+			}
+		})
+	}
+}
+
+func TestMetadataMiddleware(t *testing.T) {
+	ctx := types.ContextWithRequestID(context.Background(), "req-1")
+	tc, err := types.ParseTraceParent(testTraceParent)
+	if err != nil {
+		t.Fatalf("parsing traceparent: %v", err)
+	}
+	ctx = types.ContextWithTraceContext(ctx, tc)
+
+	tests := []struct {
+		name          string
+		ctx           context.Context
+		wantRequestID string
+	}{
+		{name: "with metadata", ctx: ctx, wantRequestID: "req-1"},
+		{name: "without metadata", ctx: context.Background()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := wrapPayload([]byte(`{"to":"a@example.com"}`), MetadataFromContext(tt.ctx))
+			if err != nil {
+				t.Fatalf("wrapping payload: %v", err)
+			}
+
+			var gotPayload, gotRequestID string
+			var gotTrace types.TraceContext
+			h := metadataMiddleware(asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+				gotPayload = string(task.Payload())
+				gotRequestID = types.RequestIDFromContext(ctx)
+				gotTrace, _ = types.TraceContextFromContext(ctx)
+				return nil
+			}))
+			if err := h.ProcessTask(context.Background(), asynq.NewTask("test", data)); err != nil {
+				t.Fatalf("processing task: %v", err)
+			}
+
+			if gotPayload != `{"to":"a@example.com"}` {
+				t.Errorf("handler payload = %s", gotPayload)
+			}
+			if gotRequestID != tt.wantRequestID {
+				t.Errorf("request ID = %q, want %q", gotRequestID, tt.wantRequestID)
+			}
+			if tt.wantRequestID != "" && gotTrace.TraceID != tc.TraceID {
+				t.Errorf("trace ID = %q, want %q", gotTrace.TraceID, tc.TraceID)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package tasks
+
+import (
+	"context"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// Metrics receives task lifecycle events for monitoring. The juango/metrics
+// package provides a Prometheus implementation.
+type Metrics interface {
+	// TaskEnqueued is called after each enqueue attempt; err is nil on
+	// success.
+	TaskEnqueued(taskType string, err error)
+	// TaskProcessed is called after a handler returns.
+	TaskProcessed(taskType string, duration time.Duration, err error)
+}
+
+// SetMetrics sets where enqueue events are reported.
+func (c *Client) SetMetrics(m Metrics) {
+	c.metrics = m
+}
+
+// SetMetrics sets where task processing events are reported.
+func (s *Server) SetMetrics(m Metrics) {
+	s.metrics = m
+}
+
+// metricsMiddleware reports the outcome and duration of each task to the
+// server's Metrics, if any.
+func (s *Server) metricsMiddleware(next asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		if s.metrics == nil {
+			return next.ProcessTask(ctx, task)
+		}
+
+		start := time.Now()
+		err := next.ProcessTask(ctx, task)
+		s.metrics.TaskProcessed(task.Type(), time.Since(start), err)
+		return err
+	})
+}
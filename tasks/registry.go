@@ -9,11 +9,16 @@ import (
 
 	"github.com/hibiken/asynq"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Client wraps an Asynq client for enqueuing tasks.
 type Client struct {
-	client *asynq.Client
+	client  *asynq.Client
+	metrics Metrics
 }
 
 // NewClient creates a new task client.
@@ -31,43 +36,109 @@ func (c *Client) Close() error {
 	return c.client.Close()
 }
 
-// Enqueue enqueues a task with the given type and payload.
+// Ping checks the connection to Redis.
+func (c *Client) Ping() error {
+	return c.client.Ping()
+}
+
+// Enqueue enqueues a task with the given type and payload. It doesn't
+// propagate a request ID or trace context, so the payload is stored as-is;
+// use EnqueueContext in request handlers.
 func (c *Client) Enqueue(taskType string, payload interface{}, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	return c.EnqueueContext(context.Background(), taskType, payload, opts...)
+}
+
+// EnqueueContext enqueues a task with the given type and payload. The
+// request ID and trace context stored in ctx are sent along with the
+// payload and restored in the handler's context by the Server, so that
+// task processing spans continue the enqueuing trace. Since they differ
+// per request, tasks relying on asynq.Unique should be enqueued with
+// Enqueue instead.
+func (c *Client) EnqueueContext(ctx context.Context, taskType string, payload interface{}, opts ...asynq.Option) (info *asynq.TaskInfo, err error) {
+	if c.metrics != nil {
+		defer func() { c.metrics.TaskEnqueued(taskType, err) }()
+	}
+
+	// Only propagate metadata the caller's context carries; the producer
+	// span alone must not turn every payload into an envelope.
+	propagate := !MetadataFromContext(ctx).IsZero()
+
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "enqueue "+taskType,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("asynq"),
+			semconv.MessagingOperationTypeSend,
+			semconv.MessagingDestinationName(taskType),
+		),
+	)
+	defer span.End()
+
 	data, err := json.Marshal(payload)
 	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
 		return nil, fmt.Errorf("marshaling task payload: %w", err)
 	}
 
+	var meta Metadata
+	if propagate {
+		meta = MetadataFromContext(ctx)
+	}
+	data, err = wrapPayload(data, meta)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("adding task metadata: %w", err)
+	}
+
 	task := asynq.NewTask(taskType, data)
-	info, err := c.client.Enqueue(task, opts...)
+	info, err = c.client.EnqueueContext(ctx, task, opts...)
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return nil, fmt.Errorf("enqueuing task: %w", err)
 	}
+	span.SetAttributes(semconv.MessagingMessageID(info.ID))
 
 	log.Info().
 		Str("task_type", taskType).
 		Str("task_id", info.ID).
+		Fields(meta.logFields()).
 		Msg("Task enqueued")
 
 	return info, nil
 }
 
 // EnqueueIn enqueues a task to be processed after the specified delay.
+// Like Enqueue, it doesn't propagate request metadata; see EnqueueInContext.
 func (c *Client) EnqueueIn(taskType string, payload interface{}, delay time.Duration, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	return c.EnqueueInContext(context.Background(), taskType, payload, delay, opts...)
+}
+
+// EnqueueInContext enqueues a task to be processed after the specified
+// delay, carrying the request ID and trace context in ctx like
+// EnqueueContext.
+func (c *Client) EnqueueInContext(ctx context.Context, taskType string, payload interface{}, delay time.Duration, opts ...asynq.Option) (*asynq.TaskInfo, error) {
 	opts = append(opts, asynq.ProcessIn(delay))
-	return c.Enqueue(taskType, payload, opts...)
+	return c.EnqueueContext(ctx, taskType, payload, opts...)
 }
 
 // EnqueueAt enqueues a task to be processed at the specified time.
+// Like Enqueue, it doesn't propagate request metadata; see EnqueueAtContext.
 func (c *Client) EnqueueAt(taskType string, payload interface{}, processAt time.Time, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	return c.EnqueueAtContext(context.Background(), taskType, payload, processAt, opts...)
+}
+
+// EnqueueAtContext enqueues a task to be processed at the specified time,
+// carrying the request ID and trace context in ctx like EnqueueContext.
+func (c *Client) EnqueueAtContext(ctx context.Context, taskType string, payload interface{}, processAt time.Time, opts ...asynq.Option) (*asynq.TaskInfo, error) {
 	opts = append(opts, asynq.ProcessAt(processAt))
-	return c.Enqueue(taskType, payload, opts...)
+	return c.EnqueueContext(ctx, taskType, payload, opts...)
 }
 
 // Server wraps an Asynq server for processing tasks.
 type Server struct {
-	server *asynq.Server
-	mux    *asynq.ServeMux
+	server  *asynq.Server
+	mux     *asynq.ServeMux
+	metrics Metrics
 }
 
 // ServerConfig holds configuration for the task server.
@@ -106,19 +177,24 @@ func NewServer(cfg *ServerConfig) *Server {
 			Concurrency: cfg.Concurrency,
 			Queues:      cfg.Queues,
 			ErrorHandler: asynq.ErrorHandlerFunc(func(ctx context.Context, task *asynq.Task, err error) {
+				meta, payload := unwrapPayload(task.Payload())
 				log.Error().
 					Err(err).
+					Fields(meta.logFields()).
 					Str("task_type", task.Type()).
-					Bytes("payload", task.Payload()).
+					Bytes("payload", payload).
 					Msg("Task failed")
 			}),
 		},
 	)
 
-	return &Server{
+	s := &Server{
 		server: server,
 		mux:    asynq.NewServeMux(),
 	}
+	s.mux.Use(metadataMiddleware, s.metricsMiddleware)
+
+	return s
 }
 
 // HandleFunc registers a handler function for the given task type.
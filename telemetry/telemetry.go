@@ -0,0 +1,89 @@
+// Package telemetry provides optional OpenTelemetry tracing.
+//
+// Instrumentation in the auth, database and tasks packages uses the global
+// tracer provider, so it records nothing until Setup (or Install) replaces
+// the default no-op provider.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/juanfont/juango/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+)
+
+// ScopeName is the instrumentation scope of spans created by this package.
+const ScopeName = "github.com/juanfont/juango/telemetry"
+
+// Setup configures tracing from cfg. When tracing is enabled it creates an
+// OTLP/HTTP exporter and installs a tracer provider globally. The returned
+// function flushes and shuts down the provider; it is a no-op when tracing
+// is disabled.
+func Setup(ctx context.Context, cfg config.TracingConfig) (func(context.Context) error, error) {
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+	}
+
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP trace exporter: %w", err)
+	}
+
+	tp, err := NewTracerProvider(cfg, exporter)
+	if err != nil {
+		exporter.Shutdown(ctx)
+		return nil, err
+	}
+	Install(tp)
+
+	return tp.Shutdown, nil
+}
+
+// NewTracerProvider creates a tracer provider that batches spans to
+// exporter, sampling according to cfg. Tests can pass an in-memory
+// exporter from go.opentelemetry.io/otel/sdk/trace/tracetest.
+func NewTracerProvider(cfg config.TracingConfig, exporter sdktrace.SpanExporter) (*sdktrace.TracerProvider, error) {
+	attrs := resource.Default()
+	if cfg.ServiceName != "" {
+		var err error
+		attrs, err = resource.Merge(attrs, resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(cfg.ServiceName),
+		))
+		if err != nil {
+			return nil, fmt.Errorf("creating trace resource: %w", err)
+		}
+	}
+
+	ratio := cfg.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(attrs),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	), nil
+}
+
+// Install sets tp as the global tracer provider and enables W3C Trace
+// Context propagation.
+func Install(tp *sdktrace.TracerProvider) {
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+}
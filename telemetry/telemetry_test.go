@@ -0,0 +1,153 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/juanfont/juango/config"
+	"github.com/juanfont/juango/database"
+	"github.com/juanfont/juango/middleware"
+	"github.com/juanfont/juango/tasks"
+	"github.com/juanfont/juango/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setupTracing(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp, err := NewTracerProvider(config.TracingConfig{ServiceName: "test"}, exporter)
+	if err != nil {
+		t.Fatalf("creating tracer provider: %v", err)
+	}
+
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	Install(tp)
+	t.Cleanup(func() {
+		tp.Shutdown(context.Background())
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+
+	return exporter
+}
+
+func flush(t *testing.T) {
+	t.Helper()
+	if tp, ok := otel.GetTracerProvider().(interface{ ForceFlush(context.Context) error }); ok {
+		tp.ForceFlush(context.Background())
+	}
+}
+
+func TestMiddlewareSpans(t *testing.T) {
+	exporter := setupTracing(t)
+
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"), database.BaseSchema())
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	defer db.Close()
+
+	var (
+		handlerTC types.TraceContext
+		taskMeta  tasks.Metadata
+		queryErr  error
+		userCount int
+	)
+
+	router := mux.NewRouter()
+	router.Use(Middleware())
+	router.Use(middleware.RequestID())
+	router.HandleFunc("/api/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlerTC, _ = types.TraceContextFromContext(r.Context())
+		taskMeta = tasks.MetadataFromContext(r.Context())
+		queryErr = db.GetContext(r.Context(), &userCount, "SELECT COUNT(*) FROM users")
+	})
+
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest(http.MethodGet, "/api/items/42", nil)
+	req.Header.Set("traceparent", parent)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if queryErr != nil {
+		t.Fatalf("query failed: %v", queryErr)
+	}
+
+	flush(t)
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+
+	// Spans are exported in end order: the query ends before the request.
+	query, server := spans[0], spans[1]
+
+	if server.Name != "GET /api/items/{id}" {
+		t.Errorf("server span name = %q", server.Name)
+	}
+	if server.SpanKind != trace.SpanKindServer {
+		t.Errorf("server span kind = %v", server.SpanKind)
+	}
+	if got := server.Parent.SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("server span parent = %s, want incoming traceparent", got)
+	}
+	if got := server.SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("server span trace ID = %s", got)
+	}
+
+	if query.Name != "SELECT" {
+		t.Errorf("query span name = %q", query.Name)
+	}
+	if query.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Errorf("query span is not a child of the server span")
+	}
+
+	// The request ID middleware and task metadata use the server span.
+	if handlerTC.SpanID != server.SpanContext.SpanID().String() {
+		t.Errorf("request trace context span = %s, want %s", handlerTC.SpanID, server.SpanContext.SpanID())
+	}
+	if got := rec.Header().Get("traceparent"); got != handlerTC.String() {
+		t.Errorf("response traceparent = %q, want %q", got, handlerTC.String())
+	}
+	if taskMeta.TraceParent != handlerTC.String() {
+		t.Errorf("task metadata traceparent = %q, want %q", taskMeta.TraceParent, handlerTC.String())
+	}
+	if taskMeta.RequestID == "" || taskMeta.RequestID != rec.Header().Get("X-Request-ID") {
+		t.Errorf("task metadata request ID = %q", taskMeta.RequestID)
+	}
+}
+
+func TestTaskMetadataContinuesTrace(t *testing.T) {
+	exporter := setupTracing(t)
+
+	ctx, producer := otel.Tracer("test").Start(context.Background(), "enqueue")
+	meta := tasks.MetadataFromContext(types.ContextWithRequestID(ctx, "req-1"))
+	producer.End()
+
+	ctx = meta.Context(context.Background())
+	if got := types.RequestIDFromContext(ctx); got != "req-1" {
+		t.Errorf("request ID = %q, want req-1", got)
+	}
+
+	_, consumer := otel.Tracer("test").Start(ctx, "process")
+	consumer.End()
+
+	flush(t)
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	if spans[1].Parent.SpanID() != spans[0].SpanContext.SpanID() {
+		t.Errorf("consumer span is not a child of the producer span")
+	}
+	if !spans[1].Parent.IsRemote() {
+		t.Errorf("consumer span parent should be remote")
+	}
+}
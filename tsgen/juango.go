@@ -0,0 +1,31 @@
+package tsgen
+
+import "github.com/juanfont/juango/types"
+
+// JuangoTypes returns zero values of the API types in the juango types
+// package, for Generator.Add.
+func JuangoTypes() []any {
+	return []any{
+		types.User{},
+		types.SessionResponse{},
+		types.Bootstrap{},
+		types.CSRFTokenResponse{},
+		types.AdminModeState{},
+		types.AdminModeRequest{},
+		types.AdminModeStatusResponse{},
+		types.AdminModeEnableResponse{},
+		types.AdminModeDisableResponse{},
+		types.ImpersonationState{},
+		types.ImpersonationStartRequest{},
+		types.ImpersonationStartResponse{},
+		types.ImpersonationStopResponse{},
+		types.ImpersonationStatusResponse{},
+		types.Problem{},
+		types.AuditLogEntry{},
+		types.AuditLogFilter{},
+		types.Notification{},
+		types.NotificationCreateRequest{},
+		types.NotificationListResponse{},
+		types.UnreadCountResponse{},
+	}
+}
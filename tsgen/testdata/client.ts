@@ -0,0 +1,58 @@
+// Code generated by juango gen ts. DO NOT EDIT.
+
+export interface testFilter {
+  q?: string
+  limit?: number
+}
+
+export interface testItem {
+  id: number
+  created_at: string
+  name: string
+  note: string | null
+  parent?: testItem
+  tags: string[]
+  labels?: Record<string, string>
+  count: string
+  data: string
+  raw: unknown
+  kinds: testKind[]
+  Untagged: boolean
+  "x-dashed": string
+  maybe: number[]
+}
+
+export interface testKind {
+  kind: string
+}
+
+export interface testPageTestItem {
+  items: testItem[]
+  next: `item_${string}`
+}
+
+// Requester performs an API request and decodes the JSON response.
+export type Requester = <T>(method: string, path: string, body?: unknown) => Promise<T>
+
+function queryString(query?: object): string {
+  const params = new URLSearchParams()
+  for (const [key, value] of Object.entries(query ?? {})) {
+    if (value !== undefined && value !== null && value !== "") {
+      params.set(key, String(value))
+    }
+  }
+  const qs = params.toString()
+  return qs ? "?" + qs : ""
+}
+
+// createClient returns a typed function for each API route.
+export function createClient(request: Requester) {
+  return {
+    listItems: (query?: testFilter) =>
+      request<testPageTestItem>("GET", "/api/items" + queryString(query)),
+    getItem: (params: { id: string }) =>
+      request<testItem>("GET", `/api/items/${encodeURIComponent(params.id)}`),
+    renameItem: (params: { id: string; "new-name": string }, body: Record<string, string>) =>
+      request<void>("POST", `/api/items/${encodeURIComponent(params.id)}/rename/${encodeURIComponent(params["new-name"])}`, body),
+  }
+}
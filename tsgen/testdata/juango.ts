@@ -0,0 +1,175 @@
+// Code generated by juango gen ts. DO NOT EDIT.
+
+export interface AdminModeDisableResponse {
+  message: string
+}
+
+export interface AdminModeEnableResponse {
+  message: string
+  state: AdminModeState | null
+}
+
+export interface AdminModeRequest {
+  reason: string
+}
+
+export interface AdminModeState {
+  enabled: boolean
+  since: string
+  reason: string
+  ip_address: string
+}
+
+export interface AdminModeStatusResponse {
+  is_admin: boolean
+  admin_mode?: AdminModeState
+}
+
+export interface AuditLogEntry {
+  id: number
+  timestamp: string
+  actor_user_id: string | null
+  action: string
+  resource_type: string
+  resource_id: string
+  changes: Record<string, unknown>
+  ip_address?: NullString
+  user_agent?: NullString
+  impersonated_user_id: string | null
+  session_id?: NullString
+  request_id?: NullString
+  trace_id?: NullString
+  actor_name: string
+}
+
+export interface AuditLogFilter {
+  actor_user_id?: string
+  impersonated_user_id?: string
+  action?: string
+  resource_type?: string
+  resource_id?: string
+  session_id?: string
+  request_id?: string
+  trace_id?: string
+  start_time?: string
+  end_time?: string
+  limit?: number
+  offset?: number
+}
+
+export interface Bootstrap {
+  session: SessionResponse | null
+  features: Record<string, boolean>
+  csrf_token?: string
+  nonce?: string
+  version?: string
+}
+
+export interface CSRFTokenResponse {
+  token: string
+}
+
+export interface FieldError {
+  field: string
+  message: string
+}
+
+export interface ImpersonationStartRequest {
+  target_user_id: string
+  reason: string
+}
+
+export interface ImpersonationStartResponse {
+  message: string
+  impersonation: ImpersonationState | null
+}
+
+export interface ImpersonationState {
+  enabled: boolean
+  since: string
+  reason: string
+  target_user_id: string
+  target_user_email: string
+  target_user_name: string
+  original_admin_id: string
+  ip_address: string
+}
+
+export interface ImpersonationStatusResponse {
+  active: boolean
+  impersonation?: ImpersonationState
+}
+
+export interface ImpersonationStopResponse {
+  message: string
+}
+
+export interface Notification {
+  id: string
+  user_id: string
+  type: string
+  title: string
+  message: string
+  link?: NullString
+  read: boolean
+  read_at?: NullTime
+  created_at: string
+}
+
+export interface NotificationCreateRequest {
+  user_id: string
+  type: string
+  title: string
+  message: string
+  link?: string
+}
+
+export interface NotificationListResponse {
+  notifications: Notification[]
+  unread_count: number
+}
+
+export interface NullString {
+  String: string
+  Valid: boolean
+}
+
+export interface NullTime {
+  Time: string
+  Valid: boolean
+}
+
+export interface Problem {
+  type: string
+  title: string
+  status: number
+  detail?: string
+  instance?: string
+  code: string
+  fields?: FieldError[]
+  request_id?: string
+}
+
+export interface SessionResponse {
+  authenticated: boolean
+  user?: User
+  reason?: string
+  impersonation?: ImpersonationState
+}
+
+export interface UnreadCountResponse {
+  count: number
+}
+
+export interface User {
+  id: string
+  email: string
+  name: string
+  last_login?: string
+  display_name: string
+  profile_pic_url: string
+  is_admin: boolean
+  created_at: string
+  modified_at: string
+  deleted_at?: NullTime
+}
@@ -0,0 +1,418 @@
+// Package tsgen generates TypeScript interfaces and a typed fetch client
+// from Go types and API routes, so that the frontend stays in sync with the
+// JSON the backend produces.
+//
+// Struct types become interfaces named after the Go type, following
+// encoding/json: json tags rename fields, "-" skips them, omitempty makes
+// them optional, the string option makes them strings and embedded structs
+// are flattened. Other named types are replaced by their underlying
+// TypeScript type. time.Time and encoding.TextMarshaler implementations are
+// strings; other json.Marshaler implementations are unknown unless they
+// implement Typer.
+package tsgen
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/juanfont/juango/routes"
+)
+
+// Typer is implemented by types whose JSON form reflection can't see,
+// typically json.Marshaler implementations, e.g.
+//
+//	func (NullUUID) TypeScriptType() string { return "string | null" }
+type Typer interface {
+	TypeScriptType() string
+}
+
+var (
+	typerType         = reflect.TypeOf((*Typer)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Generator collects Go types and routes and writes them as TypeScript.
+type Generator struct {
+	types  map[reflect.Type]string
+	names  map[string]reflect.Type
+	routes []routes.Route
+	err    error
+}
+
+// New creates an empty generator.
+func New() *Generator {
+	return &Generator{
+		types: make(map[reflect.Type]string),
+		names: make(map[string]reflect.Type),
+	}
+}
+
+// Add registers the types of values, and the struct types they reference,
+// for generation. Pass zero values, e.g. types.User{}.
+func (g *Generator) Add(values ...any) {
+	for _, v := range values {
+		if v != nil {
+			g.tsType(reflect.TypeOf(v))
+		}
+	}
+}
+
+// AddRoutes registers routes for the generated client, along with their
+// request, query and response types.
+func (g *Generator) AddRoutes(rs ...routes.Route) {
+	for _, route := range rs {
+		g.Add(route.Request, route.Query, route.Response)
+		g.routes = append(g.routes, route)
+	}
+}
+
+// Generate writes the interfaces and, if routes were added, a createClient
+// function returning one typed function per route.
+func (g *Generator) Generate(w io.Writer) error {
+	if g.err != nil {
+		return g.err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by juango gen ts. DO NOT EDIT.\n")
+
+	names := make([]string, 0, len(g.names))
+	for name := range g.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		buf.WriteString("\n")
+		g.writeInterface(&buf, name, g.names[name])
+	}
+
+	if len(g.routes) > 0 {
+		if err := g.writeClient(&buf); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// tsType returns the TypeScript type of t, registering struct types.
+func (g *Generator) tsType(t reflect.Type) string {
+	switch {
+	case t.Kind() != reflect.Pointer && t.Implements(typerType):
+		return reflect.Zero(t).Interface().(Typer).TypeScriptType()
+	case t == timeType:
+		return "string"
+	case t == rawMessageType:
+		return "unknown"
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		if t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) {
+			return "string"
+		}
+		return "unknown"
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return "string"
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Pointer:
+		return g.tsType(t.Elem())
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return "string" // base64
+		}
+		return arrayOf(g.tsType(t.Elem()))
+	case reflect.Map:
+		return "Record<string, " + g.tsType(t.Elem()) + ">"
+	case reflect.Struct:
+		if t.Name() == "" {
+			var buf bytes.Buffer
+			buf.WriteString("{ ")
+			for _, f := range g.fields(t) {
+				fmt.Fprintf(&buf, "%s: %s; ", f.tsName(), f.typ)
+			}
+			buf.WriteString("}")
+			return buf.String()
+		}
+		return g.register(t)
+	}
+	return "unknown"
+}
+
+// register names a struct type and walks its fields.
+func (g *Generator) register(t reflect.Type) string {
+	if name, ok := g.types[t]; ok {
+		return name
+	}
+
+	name := typeName(t)
+	if other, ok := g.names[name]; ok {
+		if g.err == nil {
+			g.err = fmt.Errorf("tsgen: %s and %s both map to the TypeScript name %s", other, t, name)
+		}
+		return name
+	}
+	g.types[t] = name
+	g.names[name] = t
+
+	// Register the field types now so that errors surface in Generate.
+	g.fields(t)
+	return name
+}
+
+// field is a JSON object member.
+type field struct {
+	name     string
+	typ      string
+	optional bool
+	depth    int
+}
+
+func (f field) tsName() string {
+	if identRe.MatchString(f.name) {
+		if f.optional {
+			return f.name + "?"
+		}
+		return f.name
+	}
+	quoted, _ := json.Marshal(f.name)
+	if f.optional {
+		return string(quoted) + "?"
+	}
+	return string(quoted)
+}
+
+var identRe = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// fields returns the JSON members of struct type t in declaration order,
+// with embedded structs flattened. As in encoding/json, a field shadows
+// fields of the same name nested deeper.
+func (g *Generator) fields(t reflect.Type) []field {
+	var all []field
+	depths := make(map[string]int)
+	g.collectFields(t, 0, &all, depths)
+
+	out := all[:0]
+	for _, f := range all {
+		if f.depth == depths[f.name] {
+			out = append(out, f)
+			depths[f.name] = -1 // keep the first at that depth only
+		}
+	}
+	return out
+}
+
+func (g *Generator) collectFields(t reflect.Type, depth int, out *[]field, depths map[string]int) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		ft := sf.Type
+		if sf.Anonymous && name == "" {
+			et := ft
+			if et.Kind() == reflect.Pointer {
+				et = et.Elem()
+			}
+			if et.Kind() == reflect.Struct {
+				g.collectFields(et, depth+1, out, depths)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if d, ok := depths[name]; !ok || depth < d {
+			depths[name] = depth
+		}
+
+		f := field{name: name, depth: depth, optional: hasOption(opts, "omitempty") || hasOption(opts, "omitzero")}
+		switch {
+		case hasOption(opts, "string") && isStringable(ft):
+			f.typ = "string"
+		default:
+			f.typ = g.tsType(ft)
+			if ft.Kind() == reflect.Pointer && !f.optional {
+				f.typ += " | null"
+			}
+		}
+		*out = append(*out, f)
+	}
+}
+
+func (g *Generator) writeInterface(buf *bytes.Buffer, name string, t reflect.Type) {
+	fmt.Fprintf(buf, "export interface %s {\n", name)
+	for _, f := range g.fields(t) {
+		fmt.Fprintf(buf, "  %s: %s\n", f.tsName(), f.typ)
+	}
+	buf.WriteString("}\n")
+}
+
+// writeClient writes the Requester type and createClient.
+func (g *Generator) writeClient(buf *bytes.Buffer) error {
+	buf.WriteString(`
+// Requester performs an API request and decodes the JSON response.
+export type Requester = <T>(method: string, path: string, body?: unknown) => Promise<T>
+`)
+
+	usesQuery := false
+	var fns bytes.Buffer
+	for _, route := range g.routes {
+		if !identRe.MatchString(route.Name) {
+			return fmt.Errorf("tsgen: route name %q is not a valid identifier", route.Name)
+		}
+
+		var args []string
+		params := route.PathParams()
+		if len(params) > 0 {
+			fields := make([]string, len(params))
+			for i, p := range params {
+				fields[i] = field{name: p, typ: "string"}.tsName() + ": string"
+			}
+			args = append(args, "params: { "+strings.Join(fields, "; ")+" }")
+		}
+		if route.Request != nil {
+			args = append(args, "body: "+g.tsType(reflect.TypeOf(route.Request)))
+		}
+		if route.Query != nil {
+			args = append(args, "query?: "+g.tsType(reflect.TypeOf(route.Query)))
+		}
+
+		resp := "void"
+		if route.Response != nil {
+			resp = g.tsType(reflect.TypeOf(route.Response))
+		}
+
+		path := pathExpr(route)
+		if route.Query != nil {
+			path += " + queryString(query)"
+			usesQuery = true
+		}
+		call := fmt.Sprintf("request<%s>(%q, %s", resp, route.Method, path)
+		if route.Request != nil {
+			call += ", body"
+		}
+		call += ")"
+
+		fmt.Fprintf(&fns, "    %s: (%s) =>\n      %s,\n", route.Name, strings.Join(args, ", "), call)
+	}
+
+	if usesQuery {
+		buf.WriteString(`
+function queryString(query?: object): string {
+  const params = new URLSearchParams()
+  for (const [key, value] of Object.entries(query ?? {})) {
+    if (value !== undefined && value !== null && value !== "") {
+      params.set(key, String(value))
+    }
+  }
+  const qs = params.toString()
+  return qs ? "?" + qs : ""
+}
+`)
+	}
+
+	buf.WriteString("\n// createClient returns a typed function for each API route.\n")
+	buf.WriteString("export function createClient(request: Requester) {\n  return {\n")
+	buf.Write(fns.Bytes())
+	buf.WriteString("  }\n}\n")
+	return nil
+}
+
+// pathExpr returns a TypeScript expression for the route's path, with
+// path variables taken from params.
+func pathExpr(route routes.Route) string {
+	if len(route.PathParams()) == 0 {
+		b, _ := json.Marshal(route.Path)
+		return string(b)
+	}
+
+	// Escape the literal parts, then substitute placeholders that contain
+	// no characters needing escapes.
+	var params []string
+	path := route.ReplacePathParams(func(name string) string {
+		params = append(params, name)
+		return "\x00"
+	})
+	escaped := strings.NewReplacer("`", "\\`", "\\", "\\\\", "${", "\\${").Replace(path)
+	for _, name := range params {
+		ref := "params." + name
+		if !identRe.MatchString(name) {
+			quoted, _ := json.Marshal(name)
+			ref = "params[" + string(quoted) + "]"
+		}
+		escaped = strings.Replace(escaped, "\x00", "${encodeURIComponent("+ref+")}", 1)
+	}
+	return "`" + escaped + "`"
+}
+
+// typeName returns the TypeScript name of a named Go type. Type arguments
+// of generic types are folded into the name.
+func typeName(t reflect.Type) string {
+	name := t.Name()
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		args := name[i+1 : len(name)-1]
+		name = name[:i]
+		for _, arg := range strings.Split(args, ",") {
+			arg = arg[strings.LastIndexAny(arg, "./")+1:]
+			name += strings.ToUpper(arg[:1]) + arg[1:]
+		}
+	}
+	return name
+}
+
+func arrayOf(elem string) string {
+	if strings.ContainsAny(elem, " |") {
+		return "(" + elem + ")[]"
+	}
+	return elem + "[]"
+}
+
+func hasOption(opts, name string) bool {
+	for _, opt := range strings.Split(opts, ",") {
+		if opt == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isStringable reports whether the json string option applies to t.
+func isStringable(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package tsgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/juanfont/juango/routes"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+type testBase struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Shadowed  string    `json:"name"`
+}
+
+type testItem struct {
+	testBase
+	Name     string            `json:"name"`
+	Note     *string           `json:"note"`
+	Parent   *testItem         `json:"parent,omitempty"`
+	Tags     []string          `json:"tags"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Count    int64             `json:"count,string"`
+	Data     []byte            `json:"data"`
+	Raw      json.RawMessage   `json:"raw"`
+	Kinds    []testKind        `json:"kinds"`
+	Ignored  string            `json:"-"`
+	Untagged bool
+	hidden   string
+	Dashed   string   `json:"x-dashed"`
+	Maybe    []*int64 `json:"maybe"`
+}
+
+type testKind struct {
+	Kind string `json:"kind"`
+}
+
+type testID string
+
+func (testID) TypeScriptType() string { return "`item_${string}`" }
+
+type testFilter struct {
+	Query string `json:"q,omitempty"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+type testPage[T any] struct {
+	Items []T    `json:"items"`
+	Next  testID `json:"next"`
+}
+
+func TestGenerateGolden(t *testing.T) {
+	g := New()
+	g.Add(testItem{})
+	g.AddRoutes(
+		routes.Route{Name: "listItems", Method: http.MethodGet, Path: "/api/items",
+			Query: testFilter{}, Response: testPage[testItem]{}},
+		routes.Route{Name: "getItem", Method: http.MethodGet, Path: "/api/items/{id:[0-9]+}",
+			Response: testItem{}},
+		routes.Route{Name: "renameItem", Method: http.MethodPost, Path: "/api/items/{id}/rename/{new-name}",
+			Request: map[string]string{}},
+	)
+
+	var buf bytes.Buffer
+	if err := g.Generate(&buf); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	checkGolden(t, "client.ts", buf.Bytes())
+
+	// Output is deterministic.
+	var again bytes.Buffer
+	g.Generate(&again)
+	if !bytes.Equal(buf.Bytes(), again.Bytes()) {
+		t.Error("Generate output differs between runs")
+	}
+}
+
+func TestGenerateJuangoTypes(t *testing.T) {
+	g := New()
+	g.Add(JuangoTypes()...)
+
+	var buf bytes.Buffer
+	if err := g.Generate(&buf); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	checkGolden(t, "juango.ts", buf.Bytes())
+}
+
+func TestGenerateNameConflict(t *testing.T) {
+	type testFilter struct{ Other string }
+
+	g := New()
+	g.Add(testFilter{}, struct{ F testFilterAlias }{})
+	if err := g.Generate(new(bytes.Buffer)); err == nil || !strings.Contains(err.Error(), "testFilter") {
+		t.Errorf("Generate() error = %v, want a name conflict", err)
+	}
+}
+
+type testFilterAlias = testFilter
+
+func TestGenerateInvalidRouteName(t *testing.T) {
+	g := New()
+	g.AddRoutes(routes.Route{Name: "list-items", Method: http.MethodGet, Path: "/api/items"})
+	if err := g.Generate(new(bytes.Buffer)); err == nil {
+		t.Error("Generate() succeeded with a route name that isn't an identifier")
+	}
+}
+
+// checkGolden compares got with testdata/name, rewriting it with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s (run with -update to accept):\n%s", path, got)
+	}
+}
@@ -12,7 +12,7 @@ type AdminModeState struct {
 
 // AdminModeRequest is the request body for enabling admin mode.
 type AdminModeRequest struct {
-	Reason string `json:"reason"`
+	Reason string `json:"reason" validate:"trim,required"`
 }
 
 // AdminModeStatusResponse is the response for the admin mode status endpoint.
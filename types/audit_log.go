@@ -1,8 +1,11 @@
 package types
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
+	"encoding/json"
+	"reflect"
 	"time"
 
 	"github.com/google/uuid"
@@ -42,6 +45,44 @@ func (n NullUUID) Value() (driver.Value, error) {
 	return n.UUID.String(), nil
 }
 
+// TypeScriptType returns the TypeScript type of the JSON form, for
+// juango gen ts.
+func (NullUUID) TypeScriptType() string {
+	return "string | null"
+}
+
+// OpenAPISchema returns the JSON Schema of the JSON form, for the OpenAPI
+// document.
+func (NullUUID) OpenAPISchema() map[string]any {
+	return map[string]any{"type": []string{"string", "null"}, "format": "uuid"}
+}
+
+// MarshalJSON encodes the UUID as a string, or null when invalid.
+func (n NullUUID) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(n.UUID.String())
+}
+
+// UnmarshalJSON decodes a UUID string or null.
+func (n *NullUUID) UnmarshalJSON(data []byte) error {
+	var s *string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == nil || *s == "" {
+		n.UUID, n.Valid = uuid.UUID{}, false
+		return nil
+	}
+	id, err := uuid.Parse(*s)
+	if err != nil {
+		return err
+	}
+	n.UUID, n.Valid = id, true
+	return nil
+}
+
 // AuditLog represents a log entry for tracking changes in the system.
 type AuditLog struct {
 	ID           int64          `db:"id" json:"id"`
@@ -53,6 +94,37 @@ type AuditLog struct {
 	Changes      JSONMap        `db:"changes" json:"changes"`
 	IPAddress    sql.NullString `db:"ip_address" json:"ip_address,omitempty"`
 	UserAgent    sql.NullString `db:"user_agent" json:"user_agent,omitempty"`
+
+	// Request correlation. ImpersonatedUserID is set when the actor was an
+	// admin impersonating another user.
+	ImpersonatedUserID NullUUID       `db:"impersonated_user_id" json:"impersonated_user_id"`
+	SessionID          sql.NullString `db:"session_id" json:"session_id,omitempty"`
+	RequestID          sql.NullString `db:"request_id" json:"request_id,omitempty"`
+	TraceID            sql.NullString `db:"trace_id" json:"trace_id,omitempty"`
+}
+
+// AuditLogEntry is an audit log as returned by queries, with the actor's
+// display name resolved.
+type AuditLogEntry struct {
+	AuditLog
+	ActorName string `db:"actor_name" json:"actor_name"`
+}
+
+// AuditLogFilter holds query filters for listing audit logs.
+// Zero values are ignored.
+type AuditLogFilter struct {
+	ActorUserID        uuid.UUID `json:"actor_user_id,omitempty"`
+	ImpersonatedUserID uuid.UUID `json:"impersonated_user_id,omitempty"`
+	Action             string    `json:"action,omitempty"`
+	ResourceType       string    `json:"resource_type,omitempty"`
+	ResourceID         string    `json:"resource_id,omitempty"`
+	SessionID          string    `json:"session_id,omitempty"`
+	RequestID          string    `json:"request_id,omitempty"`
+	TraceID            string    `json:"trace_id,omitempty"`
+	StartTime          time.Time `json:"start_time,omitempty"`
+	EndTime            time.Time `json:"end_time,omitempty"`
+	Limit              int       `json:"limit,omitempty"`
+	Offset             int       `json:"offset,omitempty"`
 }
 
 // Audit log action constants.
@@ -112,13 +184,14 @@ func (a *AuditLog) WithChanges(changes map[string]interface{}) *AuditLog {
 	return a
 }
 
-// WithBeforeAfter adds before/after state to the audit log.
+// WithBeforeAfter adds a field-level diff between two versions of a struct
+// to the audit log. Only changed fields are recorded, and fields tagged
+// `audit:"redact"` are listed without their values. See DiffFields.
 func (a *AuditLog) WithBeforeAfter(before, after interface{}) *AuditLog {
 	if a.Changes == nil {
 		a.Changes = make(map[string]interface{})
 	}
-	a.Changes["before"] = before
-	a.Changes["after"] = after
+	a.Changes[AuditDiffKey] = diffValues(reflect.ValueOf(before), reflect.ValueOf(after))
 	return a
 }
 
@@ -138,6 +211,48 @@ func (a *AuditLog) WithUserAgent(ua string) *AuditLog {
 	return a
 }
 
+// WithImpersonatedUserID records the user being impersonated by the actor.
+func (a *AuditLog) WithImpersonatedUserID(id uuid.UUID) *AuditLog {
+	if id != uuid.Nil {
+		a.ImpersonatedUserID = NullUUID{UUID: id, Valid: true}
+	}
+	return a
+}
+
+// WithSessionID adds the session ID to the audit log.
+func (a *AuditLog) WithSessionID(id string) *AuditLog {
+	if id != "" {
+		a.SessionID = sql.NullString{String: id, Valid: true}
+	}
+	return a
+}
+
+// WithRequestID adds the request ID to the audit log.
+func (a *AuditLog) WithRequestID(id string) *AuditLog {
+	if id != "" {
+		a.RequestID = sql.NullString{String: id, Valid: true}
+	}
+	return a
+}
+
+// WithTraceID adds the W3C trace ID to the audit log.
+func (a *AuditLog) WithTraceID(id string) *AuditLog {
+	if id != "" {
+		a.TraceID = sql.NullString{String: id, Valid: true}
+	}
+	return a
+}
+
+// WithRequestContext adds the request ID and trace ID stored in ctx by the
+// request ID middleware.
+func (a *AuditLog) WithRequestContext(ctx context.Context) *AuditLog {
+	a.WithRequestID(RequestIDFromContext(ctx))
+	if tc, ok := TraceContextFromContext(ctx); ok {
+		a.WithTraceID(tc.TraceID)
+	}
+	return a
+}
+
 // AddDetail adds a key-value detail to the changes map.
 func (a *AuditLog) AddDetail(key string, value interface{}) *AuditLog {
 	if a.Changes == nil {
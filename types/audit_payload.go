@@ -0,0 +1,314 @@
+package types
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Audit payload errors.
+var (
+	ErrUnknownAuditAction = errors.New("unknown audit action")
+	ErrInvalidAuditChange = errors.New("invalid audit changes")
+)
+
+// AuditDiffKey is the Changes key holding the field-level diff written by
+// WithBeforeAfter. It is accepted for every registered action.
+const AuditDiffKey = "diff"
+
+// UserLoggedInChanges is the payload for ActionUserLoggedIn.
+type UserLoggedInChanges struct {
+	Email       string `json:"email"`
+	DisplayName string `json:"display_name"`
+}
+
+// UserCreatedChanges is the payload for ActionUserCreated.
+type UserCreatedChanges struct {
+	Email       string `json:"email"`
+	DisplayName string `json:"display_name"`
+}
+
+// UserStatusChanges is the payload for ActionUserDeactivated and ActionUserReactivated.
+type UserStatusChanges struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// AdminModeEnabledChanges is the payload for ActionAdminModeEnabled.
+type AdminModeEnabledChanges struct {
+	Reason  string `json:"reason"`
+	Timeout string `json:"timeout"`
+}
+
+// AdminModeEndedChanges is the payload for ActionAdminModeDisabled and ActionAdminModeExpired.
+type AdminModeEndedChanges struct {
+	PreviousReason string `json:"previous_reason"`
+	Duration       string `json:"duration"`
+}
+
+// ImpersonationStartedChanges is the payload for ActionImpersonationStarted.
+type ImpersonationStartedChanges struct {
+	AdminID         string `json:"admin_id"`
+	AdminEmail      string `json:"admin_email"`
+	TargetUserID    string `json:"target_user_id"`
+	TargetUserEmail string `json:"target_user_email"`
+	TargetUserName  string `json:"target_user_name"`
+	Reason          string `json:"reason"`
+	Timeout         string `json:"timeout"`
+}
+
+// ImpersonationEndedChanges is the payload for ActionImpersonationStopped and ActionImpersonationExpired.
+type ImpersonationEndedChanges struct {
+	AdminID         string `json:"admin_id"`
+	TargetUserID    string `json:"target_user_id"`
+	TargetUserEmail string `json:"target_user_email"`
+	Reason          string `json:"reason"`
+	Duration        string `json:"duration"`
+}
+
+// TaskChanges is the payload for task actions.
+type TaskChanges struct {
+	TaskType string `json:"task_type"`
+	TaskID   string `json:"task_id,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// EmptyChanges is the payload for actions that carry no details.
+type EmptyChanges struct{}
+
+var auditRegistry = struct {
+	sync.RWMutex
+	actions map[string]reflect.Type
+}{actions: make(map[string]reflect.Type)}
+
+func init() {
+	RegisterAuditAction(ActionUserCreated, UserCreatedChanges{})
+	RegisterAuditAction(ActionUserUpdated, EmptyChanges{})
+	RegisterAuditAction(ActionUserDeactivated, UserStatusChanges{})
+	RegisterAuditAction(ActionUserReactivated, UserStatusChanges{})
+	RegisterAuditAction(ActionUserLoggedIn, UserLoggedInChanges{})
+	RegisterAuditAction(ActionUserLoggedOut, EmptyChanges{})
+	RegisterAuditAction(ActionAdminModeEnabled, AdminModeEnabledChanges{})
+	RegisterAuditAction(ActionAdminModeDisabled, AdminModeEndedChanges{})
+	RegisterAuditAction(ActionAdminModeExpired, AdminModeEndedChanges{})
+	RegisterAuditAction(ActionImpersonationStarted, ImpersonationStartedChanges{})
+	RegisterAuditAction(ActionImpersonationStopped, ImpersonationEndedChanges{})
+	RegisterAuditAction(ActionImpersonationExpired, ImpersonationEndedChanges{})
+	RegisterAuditAction(ActionTaskCreated, TaskChanges{})
+	RegisterAuditAction(ActionTaskStarted, TaskChanges{})
+	RegisterAuditAction(ActionTaskCompleted, TaskChanges{})
+	RegisterAuditAction(ActionTaskFailed, TaskChanges{})
+}
+
+// RegisterAuditAction registers an audit action together with the struct
+// type describing its Changes. Fields without `omitempty` in their json tag
+// are required. Applications must register their own actions before
+// writing audit logs for them; registering an action twice replaces it.
+func RegisterAuditAction(action string, payload any) {
+	t := reflect.TypeOf(payload)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("audit payload for %q must be a struct, got %T", action, payload))
+	}
+
+	auditRegistry.Lock()
+	defer auditRegistry.Unlock()
+	auditRegistry.actions[action] = t
+}
+
+// AuditPayloadType returns the registered payload type for an action.
+func AuditPayloadType(action string) (reflect.Type, bool) {
+	auditRegistry.RLock()
+	defer auditRegistry.RUnlock()
+	t, ok := auditRegistry.actions[action]
+	return t, ok
+}
+
+// WithPayload sets the changes from a typed payload struct. If the payload
+// can't be encoded as a JSON object, a is returned unchanged with the error,
+// so the event can still be recorded.
+func (a *AuditLog) WithPayload(payload any) (*AuditLog, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return a, fmt.Errorf("%w: %s: encoding payload: %w", ErrInvalidAuditChange, a.Action, err)
+	}
+
+	var changes map[string]interface{}
+	if err := json.Unmarshal(data, &changes); err != nil {
+		return a, fmt.Errorf("%w: %s: payload is not an object: %w", ErrInvalidAuditChange, a.Action, err)
+	}
+	return a.WithChanges(changes), nil
+}
+
+// Validate checks that the action is registered and that Changes matches
+// its payload schema: no unknown keys, all required keys present and
+// values of the right type. Audit loggers store entries that fail
+// validation anyway and report the error afterwards, so that a schema
+// mistake never loses an audit record (see IsAuditValidationError).
+func (a *AuditLog) Validate() error {
+	t, ok := AuditPayloadType(a.Action)
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownAuditAction, a.Action)
+	}
+
+	payload := make(map[string]interface{}, len(a.Changes))
+	for k, v := range a.Changes {
+		if k == AuditDiffKey {
+			continue
+		}
+		payload[k] = v
+	}
+
+	for _, name := range requiredJSONFields(t) {
+		if _, ok := payload[name]; !ok {
+			return fmt.Errorf("%w: %s: missing field %q", ErrInvalidAuditChange, a.Action, name)
+		}
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidAuditChange, a.Action, err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(reflect.New(t).Interface()); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidAuditChange, a.Action, err)
+	}
+
+	return nil
+}
+
+// IsAuditValidationError reports whether err is a Validate error, which
+// audit loggers return after storing the entry.
+func IsAuditValidationError(err error) bool {
+	return errors.Is(err, ErrUnknownAuditAction) || errors.Is(err, ErrInvalidAuditChange)
+}
+
+// requiredJSONFields returns the json names of fields without omitempty.
+func requiredJSONFields(t reflect.Type) []string {
+	var names []string
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if strings.Contains(opts, "omitempty") || strings.Contains(opts, "omitzero") {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// FieldChange describes a change to a single field between two versions
+// of a struct. Before and After are omitted for redacted fields.
+type FieldChange struct {
+	Field    string `json:"field"`
+	Before   any    `json:"before,omitempty"`
+	After    any    `json:"after,omitempty"`
+	Redacted bool   `json:"redacted,omitempty"`
+}
+
+// DiffFields computes a field-level diff between two values of the same
+// struct type. Fields are named after their json tag; fields tagged
+// `json:"-"` are skipped and fields tagged `audit:"redact"` are reported
+// as changed without their values.
+func DiffFields[T any](before, after T) []FieldChange {
+	return diffValues(reflect.ValueOf(before), reflect.ValueOf(after))
+}
+
+func diffValues(before, after reflect.Value) []FieldChange {
+	before, after = indirect(before), indirect(after)
+
+	var t reflect.Type
+	switch {
+	case before.IsValid():
+		t = before.Type()
+	case after.IsValid():
+		t = after.Type()
+	default:
+		return nil
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	if before.IsValid() && after.IsValid() && before.Type() != after.Type() {
+		return nil
+	}
+
+	var changes []FieldChange
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		var bv, av any
+		if before.IsValid() {
+			bv = fieldValue(before.FieldByIndex(f.Index))
+		}
+		if after.IsValid() {
+			av = fieldValue(after.FieldByIndex(f.Index))
+		}
+		if reflect.DeepEqual(bv, av) {
+			continue
+		}
+
+		change := FieldChange{Field: name}
+		if f.Tag.Get("audit") == "redact" {
+			change.Redacted = true
+		} else {
+			change.Before = bv
+			change.After = av
+		}
+		changes = append(changes, change)
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+// indirect dereferences pointers, returning an invalid Value for nil.
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// fieldValue returns a loggable value, unwrapping sql null types.
+func fieldValue(v reflect.Value) any {
+	val := v.Interface()
+	if valuer, ok := val.(driver.Valuer); ok {
+		if rv := indirect(v); !rv.IsValid() {
+			return nil
+		}
+		if dv, err := valuer.Value(); err == nil {
+			return dv
+		}
+	}
+	return val
+}
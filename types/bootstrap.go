@@ -0,0 +1,16 @@
+package types
+
+// CSRFTokenResponse is the response of auth.CSRFProtection.TokenHandler.
+type CSRFTokenResponse struct {
+	Token string `json:"token"`
+}
+
+// Bootstrap is the data embedded in the SPA's index.html so the frontend can
+// render without first calling the session API.
+type Bootstrap struct {
+	Session   *SessionResponse `json:"session"`
+	Features  map[string]bool  `json:"features"`
+	CSRFToken string           `json:"csrf_token,omitempty"`
+	Nonce     string           `json:"nonce,omitempty"`
+	Version   string           `json:"version,omitempty"`
+}
@@ -0,0 +1,166 @@
+package types
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// contextKey is an unexported type for context keys defined in this package.
+type contextKey string
+
+const (
+	contextKeyRequestID    contextKey = "request_id"
+	contextKeyTraceContext contextKey = "trace_context"
+	contextKeyCSPNonce     contextKey = "csp_nonce"
+)
+
+// ContextWithRequestID returns a copy of ctx carrying the request ID.
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, contextKeyRequestID, requestID)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, or "".
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(contextKeyRequestID).(string)
+	return requestID
+}
+
+// ContextWithCSPNonce returns a copy of ctx carrying the Content Security
+// Policy nonce of the response.
+func ContextWithCSPNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, contextKeyCSPNonce, nonce)
+}
+
+// CSPNonceFromContext returns the CSP nonce stored in ctx, or "".
+func CSPNonceFromContext(ctx context.Context) string {
+	nonce, _ := ctx.Value(contextKeyCSPNonce).(string)
+	return nonce
+}
+
+// TraceContext is a W3C Trace Context (https://www.w3.org/TR/trace-context/)
+// as carried by the traceparent header.
+type TraceContext struct {
+	TraceID string // 32 lowercase hex characters
+	SpanID  string // 16 lowercase hex characters
+	Flags   byte
+}
+
+// TraceFlagSampled is the sampled bit of the trace flags.
+const TraceFlagSampled byte = 0x01
+
+// NewTraceContext starts a new sampled trace with random IDs.
+func NewTraceContext() TraceContext {
+	return TraceContext{
+		TraceID: randomHex(16),
+		SpanID:  randomHex(8),
+		Flags:   TraceFlagSampled,
+	}
+}
+
+// ParseTraceParent parses a traceparent header value.
+func ParseTraceParent(header string) (TraceContext, error) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 {
+		return TraceContext{}, fmt.Errorf("invalid traceparent %q", header)
+	}
+
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	// Version ff is forbidden; version 00 has exactly four fields. Later
+	// versions may append fields, which we ignore.
+	if !isLowerHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return TraceContext{}, fmt.Errorf("invalid traceparent version %q", version)
+	}
+	if !isLowerHex(traceID, 32) || traceID == strings.Repeat("0", 32) {
+		return TraceContext{}, fmt.Errorf("invalid trace ID %q", traceID)
+	}
+	if !isLowerHex(spanID, 16) || spanID == strings.Repeat("0", 16) {
+		return TraceContext{}, fmt.Errorf("invalid parent ID %q", spanID)
+	}
+	if !isLowerHex(flags, 2) {
+		return TraceContext{}, fmt.Errorf("invalid trace flags %q", flags)
+	}
+
+	b, _ := hex.DecodeString(flags)
+	return TraceContext{TraceID: traceID, SpanID: spanID, Flags: b[0]}, nil
+}
+
+// IsValid reports whether the trace context has a trace and span ID.
+func (t TraceContext) IsValid() bool {
+	return t.TraceID != "" && t.SpanID != ""
+}
+
+// Sampled reports whether the sampled flag is set.
+func (t TraceContext) Sampled() bool {
+	return t.Flags&TraceFlagSampled != 0
+}
+
+// Child returns a trace context in the same trace with a new span ID.
+func (t TraceContext) Child() TraceContext {
+	t.SpanID = randomHex(8)
+	return t
+}
+
+// String formats the trace context as a version 00 traceparent header value.
+func (t TraceContext) String() string {
+	return fmt.Sprintf("00-%s-%s-%02x", t.TraceID, t.SpanID, t.Flags)
+}
+
+// SpanContext converts the trace context to a remote OpenTelemetry span
+// context, suitable as the parent of a new span.
+func (t TraceContext) SpanContext() trace.SpanContext {
+	traceID, _ := trace.TraceIDFromHex(t.TraceID)
+	spanID, _ := trace.SpanIDFromHex(t.SpanID)
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.TraceFlags(t.Flags),
+		Remote:     true,
+	})
+}
+
+// TraceContextFromSpanContext converts an OpenTelemetry span context.
+func TraceContextFromSpanContext(sc trace.SpanContext) TraceContext {
+	if !sc.IsValid() {
+		return TraceContext{}
+	}
+	return TraceContext{
+		TraceID: sc.TraceID().String(),
+		SpanID:  sc.SpanID().String(),
+		Flags:   byte(sc.TraceFlags()),
+	}
+}
+
+// ContextWithTraceContext returns a copy of ctx carrying the trace context.
+func ContextWithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, contextKeyTraceContext, tc)
+}
+
+// TraceContextFromContext returns the trace context stored in ctx.
+func TraceContextFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(contextKeyTraceContext).(TraceContext)
+	return tc, ok && tc.IsValid()
+}
+
+func isLowerHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -1,14 +1,19 @@
 package types
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/rs/zerolog/log"
 )
 
-// Common errors.
+// Common errors. WriteError maps them, when not wrapped in an HTTPError, to
+// their status and problem type.
 var (
 	ErrNotFound       = errors.New("not found")
 	ErrUnauthorized   = errors.New("unauthorized")
@@ -17,13 +22,67 @@ var (
 	ErrInternalServer = errors.New("internal server error")
 )
 
+// sentinelStatus maps the common errors to their HTTP status.
+var sentinelStatus = []struct {
+	err    error
+	status int
+}{
+	{ErrNotFound, http.StatusNotFound},
+	{ErrUnauthorized, http.StatusUnauthorized},
+	{ErrForbidden, http.StatusForbidden},
+	{ErrBadRequest, http.StatusBadRequest},
+	{ErrInternalServer, http.StatusInternalServerError},
+}
+
 // HTTPError represents an error that is surfaced to the user via HTTP.
 type HTTPError struct {
-	Code int    // HTTP response code to send to client; 0 means 500
-	Msg  string // Response body to send to client
-	Err  error  // Detailed error to log on the server
+	Code   int          // HTTP response code to send to client; 0 means 500
+	Msg    string       // Message to send to client, the problem detail
+	Err    error        // Detailed error to log on the server
+	Fields []FieldError // Invalid request fields, if any
+	Type   string       // Problem type URI; defaults from Code and Fields
 }
 
+// ProblemContentType is the media type of Problem responses (RFC 9457).
+const ProblemContentType = "application/problem+json"
+
+// ProblemTypeBase prefixes the default problem types, e.g.
+// "/problems/not-found". Set it to where the application documents its
+// errors, such as "https://example.com/docs/errors/".
+var ProblemTypeBase = "/problems/"
+
+// Problem is an RFC 9457 problem details object, the body of error
+// responses. Code, Fields and RequestID are extension members.
+type Problem struct {
+	// Type is a URI reference identifying the problem type.
+	Type string `json:"type"`
+	// Title is a short summary of the problem type.
+	Title string `json:"title"`
+	// Status is the HTTP status code.
+	Status int `json:"status"`
+	// Detail explains this occurrence of the problem.
+	Detail string `json:"detail,omitempty"`
+	// Instance is the path of the request that failed.
+	Instance string `json:"instance,omitempty"`
+
+	// Code is a machine-readable error code, e.g. "not_found" or
+	// "validation_failed".
+	Code      string       `json:"code"`
+	Fields    []FieldError `json:"fields,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+// FieldError describes an invalid request field.
+type FieldError struct {
+	// Field is the JSON path of the field, e.g. "reason" or "address.city".
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ErrorCodeValidationFailed is the error code of responses with field
+// errors.
+const ErrorCodeValidationFailed = "validation_failed"
+
 func (e HTTPError) Error() string {
 	return fmt.Sprintf("http error[%d]: %s, %s", e.Code, e.Msg, e.Err)
 }
@@ -37,16 +96,157 @@ func NewHTTPError(code int, msg string, err error) HTTPError {
 	return HTTPError{Code: code, Msg: msg, Err: err}
 }
 
-// WriteHTTPError writes an HTTPError to the response writer.
-func WriteHTTPError(w http.ResponseWriter, err error) {
+// NewValidationError creates a 400 HTTPError listing invalid fields.
+func NewValidationError(fields ...FieldError) HTTPError {
+	return HTTPError{Code: http.StatusBadRequest, Msg: "Invalid request", Fields: fields}
+}
+
+// Status returns the HTTP status code, defaulting to 500.
+func (e HTTPError) Status() int {
+	if e.Code == 0 {
+		return http.StatusInternalServerError
+	}
+	return e.Code
+}
+
+// Problem returns the problem details of the error. instance and requestID
+// may be empty.
+func (e HTTPError) Problem(instance, requestID string) Problem {
+	status := e.Status()
+	code := ErrorCodeValidationFailed
+	title := "Validation failed"
+	if len(e.Fields) == 0 {
+		code = errorCode(status)
+		title = http.StatusText(status)
+		if title == "" {
+			title = "Unknown error"
+		}
+	}
+	typ := e.Type
+	if typ == "" {
+		typ = ProblemTypeBase + strings.ReplaceAll(code, "_", "-")
+	}
+	return Problem{
+		Type:      typ,
+		Title:     title,
+		Status:    status,
+		Detail:    e.Msg,
+		Instance:  instance,
+		Code:      code,
+		Fields:    e.Fields,
+		RequestID: requestID,
+	}
+}
+
+// errorCode derives an error code from the status text, e.g. "not_found".
+func errorCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ReplaceAll(strings.ToLower(strings.ReplaceAll(text, "-", " ")), " ", "_")
+}
+
+// AsHTTPError converts err to the HTTPError it reports to clients: an
+// HTTPError in its chain, a common error such as ErrNotFound with its
+// status, or a generic 500.
+func AsHTTPError(err error) HTTPError {
 	var herr HTTPError
 	if errors.As(err, &herr) {
-		http.Error(w, herr.Msg, herr.Code)
-		log.Error().Err(herr.Err).Int("code", herr.Code).Msgf("user msg: %s", herr.Msg)
-	} else {
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-		log.Error().Err(err).Int("code", http.StatusInternalServerError).Msg("http internal server error")
+		return herr
+	}
+	for _, s := range sentinelStatus {
+		if errors.Is(err, s.err) {
+			return HTTPError{Code: s.status, Msg: s.err.Error(), Err: err}
+		}
+	}
+	return HTTPError{Code: http.StatusInternalServerError, Msg: "internal server error", Err: err}
+}
+
+// WriteError writes err as an error response for r: problem details as
+// application/problem+json, or the detail as plain text for clients that
+// prefer text over JSON (see WantsJSON). Errors are converted with
+// AsHTTPError. The request and trace IDs echoed by the request ID
+// middleware are added to the log entry, and the request ID to the body.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	herr := AsHTTPError(err)
+	requestID := logHTTPError(w, herr)
+
+	if !WantsJSON(r) {
+		http.Error(w, herr.Msg, herr.Status())
+		return
+	}
+	writeProblem(w, herr.Problem(r.URL.Path, requestID))
+}
+
+// WriteHTTPError writes err as application/problem+json, like WriteError
+// for a JSON client. Prefer WriteError where the request is available.
+func WriteHTTPError(w http.ResponseWriter, err error) {
+	herr := AsHTTPError(err)
+	requestID := logHTTPError(w, herr)
+	writeProblem(w, herr.Problem("", requestID))
+}
+
+// logHTTPError logs herr with the correlation IDs of the response and
+// returns the request ID.
+func logHTTPError(w http.ResponseWriter, herr HTTPError) string {
+	fields := responseCorrelationFields(w)
+	logger := log.Logger.With().Fields(fields).Logger()
+	logger.Error().Err(herr.Err).Int("code", herr.Status()).Msgf("user msg: %s", herr.Msg)
+	requestID, _ := fields["request_id"].(string)
+	return requestID
+}
+
+func writeProblem(w http.ResponseWriter, p Problem) {
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", ProblemContentType)
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// WantsJSON reports whether r's Accept header prefers a JSON response over
+// text. Requests without a preference, such as API clients sending no
+// Accept header or "*/*", get JSON; browsers navigating to a URL prefer
+// text/html and get text.
+func WantsJSON(r *http.Request) bool {
+	jsonQ, textQ := -1.0, -1.0
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		switch {
+		case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+			jsonQ = max(jsonQ, q)
+		case strings.HasPrefix(mediaType, "text/"):
+			textQ = max(textQ, q)
+		}
+	}
+	if jsonQ < 0 && textQ < 0 {
+		return true
+	}
+	return jsonQ >= textQ && jsonQ > 0
+}
+
+// responseCorrelationFields returns the request_id and trace_id log fields
+// from the response headers.
+func responseCorrelationFields(w http.ResponseWriter) map[string]interface{} {
+	fields := make(map[string]interface{}, 2)
+	if id := w.Header().Get("X-Request-ID"); id != "" {
+		fields["request_id"] = id
+	}
+	if tc, err := ParseTraceParent(w.Header().Get("traceparent")); err == nil {
+		fields["trace_id"] = tc.TraceID
 	}
+	return fields
 }
 
 // HTTPErrorFromStatus creates an HTTPError from an HTTP status code.
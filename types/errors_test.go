@@ -0,0 +1,238 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWantsJSON(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{accept: "", want: true},
+		{accept: "*/*", want: true},
+		{accept: "application/json", want: true},
+		{accept: "application/problem+json", want: true},
+		{accept: "application/json, text/plain, */*", want: true},
+		{accept: "text/plain", want: false},
+		{accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", want: false},
+		{accept: "text/html;q=0.5, application/json", want: true},
+		{accept: "text/plain, application/json;q=0.9", want: false},
+		{accept: "application/json, text/plain", want: true},
+		{accept: "application/json;q=0", want: false},
+		{accept: "application/json;q=0, text/plain;q=0", want: false},
+		{accept: "image/png", want: true},
+		{accept: "application/json;q=bogus, text/plain", want: false},
+		{accept: "not a media type", want: true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		if got := WantsJSON(r); got != tt.want {
+			t.Errorf("WantsJSON(Accept: %q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestAsHTTPError(t *testing.T) {
+	cause := errors.New("no rows")
+	herr := NewHTTPError(http.StatusConflict, "Item exists", cause)
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantMsg    string
+	}{
+		{name: "http error", err: herr, wantStatus: http.StatusConflict, wantMsg: "Item exists"},
+		{name: "wrapped http error", err: fmt.Errorf("creating item: %w", herr),
+			wantStatus: http.StatusConflict, wantMsg: "Item exists"},
+		{name: "http error wrapping a sentinel", err: NewHTTPError(http.StatusGone, "Item deleted", ErrNotFound),
+			wantStatus: http.StatusGone, wantMsg: "Item deleted"},
+		{name: "not found", err: ErrNotFound, wantStatus: http.StatusNotFound, wantMsg: "not found"},
+		{name: "wrapped not found", err: fmt.Errorf("item 1: %w", ErrNotFound),
+			wantStatus: http.StatusNotFound, wantMsg: "not found"},
+		{name: "unauthorized", err: ErrUnauthorized, wantStatus: http.StatusUnauthorized, wantMsg: "unauthorized"},
+		{name: "forbidden", err: ErrForbidden, wantStatus: http.StatusForbidden, wantMsg: "forbidden"},
+		{name: "bad request", err: ErrBadRequest, wantStatus: http.StatusBadRequest, wantMsg: "bad request"},
+		{name: "internal", err: ErrInternalServer, wantStatus: http.StatusInternalServerError,
+			wantMsg: "internal server error"},
+		{name: "other", err: cause, wantStatus: http.StatusInternalServerError, wantMsg: "internal server error"},
+		{name: "zero code", err: HTTPError{Msg: "Oops"}, wantStatus: http.StatusInternalServerError, wantMsg: "Oops"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AsHTTPError(tt.err)
+			if got.Status() != tt.wantStatus || got.Msg != tt.wantMsg {
+				t.Errorf("AsHTTPError() = %d %q, want %d %q", got.Status(), got.Msg, tt.wantStatus, tt.wantMsg)
+			}
+			for _, target := range []error{cause, ErrNotFound, ErrUnauthorized} {
+				if errors.Is(got, target) != errors.Is(tt.err, target) {
+					t.Errorf("errors.Is(AsHTTPError(), %v) = %v, want %v", target, !errors.Is(tt.err, target), errors.Is(tt.err, target))
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPErrorProblem(t *testing.T) {
+	tests := []struct {
+		name string
+		err  HTTPError
+		want Problem
+	}{
+		{
+			name: "not found",
+			err:  AsHTTPError(ErrNotFound),
+			want: Problem{Type: "/problems/not-found", Title: "Not Found", Status: 404,
+				Detail: "not found", Instance: "/api/items/1", Code: "not_found", RequestID: "req-1"},
+		},
+		{
+			name: "validation",
+			err:  NewValidationError(FieldError{Field: "name", Message: "is required"}),
+			want: Problem{Type: "/problems/validation-failed", Title: "Validation failed", Status: 400,
+				Detail: "Invalid request", Instance: "/api/items/1", Code: ErrorCodeValidationFailed,
+				Fields: []FieldError{{Field: "name", Message: "is required"}}, RequestID: "req-1"},
+		},
+		{
+			name: "custom type",
+			err:  HTTPError{Code: http.StatusPaymentRequired, Msg: "Upgrade your plan", Type: "https://example.com/errors/quota"},
+			want: Problem{Type: "https://example.com/errors/quota", Title: "Payment Required", Status: 402,
+				Detail: "Upgrade your plan", Instance: "/api/items/1", Code: "payment_required", RequestID: "req-1"},
+		},
+		{
+			name: "unknown status",
+			err:  HTTPError{Code: 599, Msg: "Odd"},
+			want: Problem{Type: "/problems/error", Title: "Unknown error", Status: 599,
+				Detail: "Odd", Instance: "/api/items/1", Code: "error", RequestID: "req-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Problem("/api/items/1", "req-1"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Problem() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name       string
+		accept     string
+		err        error
+		wantStatus int
+		wantType   string
+		wantBody   string
+	}{
+		{
+			name:       "json client",
+			accept:     "application/json",
+			err:        ErrNotFound,
+			wantStatus: http.StatusNotFound,
+			wantType:   ProblemContentType,
+		},
+		{
+			name:       "no accept",
+			err:        NewValidationError(FieldError{Field: "name", Message: "is required"}),
+			wantStatus: http.StatusBadRequest,
+			wantType:   ProblemContentType,
+		},
+		{
+			name:       "browser",
+			accept:     "text/html,application/xhtml+xml,*/*;q=0.8",
+			err:        ErrForbidden,
+			wantStatus: http.StatusForbidden,
+			wantType:   "text/plain; charset=utf-8",
+			wantBody:   "forbidden\n",
+		},
+		{
+			name:       "text client hides internal errors",
+			accept:     "text/plain",
+			err:        errors.New("dial tcp: connection refused"),
+			wantStatus: http.StatusInternalServerError,
+			wantType:   "text/plain; charset=utf-8",
+			wantBody:   "internal server error\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/items/1", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			w.Header().Set("X-Request-ID", "req-1")
+			w.Header().Set("Content-Length", "3")
+			WriteError(w, r, tt.err)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d", w.Code, tt.wantStatus)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", ct, tt.wantType)
+			}
+			if w.Header().Get("X-Content-Type-Options") != "nosniff" {
+				t.Error("X-Content-Type-Options isn't nosniff")
+			}
+			if w.Header().Get("Content-Length") != "" {
+				t.Error("stale Content-Length kept")
+			}
+			if tt.wantBody != "" {
+				if w.Body.String() != tt.wantBody {
+					t.Errorf("body %q, want %q", w.Body, tt.wantBody)
+				}
+				return
+			}
+
+			var p Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+				t.Fatalf("body %q isn't a problem: %v", w.Body, err)
+			}
+			if p.Status != tt.wantStatus || p.Instance != "/api/items/1" || p.RequestID != "req-1" {
+				t.Errorf("problem %+v, want status %d, instance and request ID", p, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestWriteHTTPError(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.Header().Set("X-Request-ID", "req-2")
+	WriteHTTPError(w, fmt.Errorf("loading: %w", ErrUnauthorized))
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status %d, want 401", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != ProblemContentType {
+		t.Errorf("Content-Type = %q, want %s", ct, ProblemContentType)
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"type":       "/problems/unauthorized",
+		"title":      "Unauthorized",
+		"status":     float64(401),
+		"detail":     "unauthorized",
+		"code":       "unauthorized",
+		"request_id": "req-2",
+	}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("body = %v, want %v", body, want)
+	}
+	if strings.Contains(w.Body.String(), "loading") {
+		t.Errorf("wrapped error leaked: %s", w.Body)
+	}
+}
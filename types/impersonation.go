@@ -20,8 +20,8 @@ type ImpersonationState struct {
 
 // ImpersonationStartRequest is the request body for starting impersonation.
 type ImpersonationStartRequest struct {
-	TargetUserID string `json:"target_user_id"`
-	Reason       string `json:"reason"`
+	TargetUserID string `json:"target_user_id" validate:"required,uuid"`
+	Reason       string `json:"reason" validate:"trim,required"`
 }
 
 // ImpersonationStatusResponse is the response for the impersonation status endpoint.
@@ -26,6 +26,19 @@ type User struct {
 	DeletedAt  sql.NullTime `db:"deleted_at" json:"deleted_at,omitempty"`
 }
 
+// UserFilter holds query filters for listing users.
+// Zero values are ignored.
+type UserFilter struct {
+	// Search matches a substring of the email, name or display name.
+	Search string `json:"search,omitempty"`
+	// IsAdmin, when set, restricts the list to admins or non-admins.
+	IsAdmin *bool `json:"is_admin,omitempty"`
+	// IncludeInactive includes deactivated users.
+	IncludeInactive bool `json:"include_inactive,omitempty"`
+	Limit           int  `json:"limit,omitempty"`
+	Offset          int  `json:"offset,omitempty"`
+}
+
 // SessionResponse represents the response from the session check API.
 type SessionResponse struct {
 	Authenticated bool                `json:"authenticated"`